@@ -0,0 +1,392 @@
+package codeccef
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "cef"
+
+// ErrorTag tag added to event when process module failed
+const ErrorTag = "gogstash_codec_cef_error"
+
+// header field names used when ecs_compatibility is disabled
+const (
+	FieldVersion       = "cefVersion"
+	FieldDeviceVendor  = "deviceVendor"
+	FieldDeviceProduct = "deviceProduct"
+	FieldDeviceVersion = "deviceVersion"
+	FieldSignature     = "deviceEventClassId"
+	FieldName          = "name"
+	FieldSeverity      = "severity"
+	FieldSyslog        = "syslog"
+)
+
+var headerFields = []string{
+	FieldVersion,
+	FieldDeviceVendor,
+	FieldDeviceProduct,
+	FieldDeviceVersion,
+	FieldSignature,
+	FieldName,
+	FieldSeverity,
+}
+
+// errors
+var (
+	ErrorInvalidHeader1 = errutil.NewFactory("invalid CEF header: %q")
+)
+
+// Codec default struct for codec
+type Codec struct {
+	config.CodecConfig
+
+	// map header and extension keys to Elastic Common Schema field names, default: false
+	ECSCompatibility bool `json:"ecs_compatibility"`
+
+	// header values used by Encode, event fields can be used, e.g. "%{product}"
+	// when empty, the decoded header field of the event is used
+	Vendor    string `json:"vendor"`
+	Product   string `json:"product"`
+	Version   string `json:"version"`
+	Signature string `json:"signature"`
+	Name      string `json:"name"`
+	Severity  string `json:"severity"`
+
+	// event fields written to the extension by Encode, default: all event fields
+	Fields []string `json:"fields"`
+}
+
+// DefaultCodecConfig returns an Codec struct with default values
+func DefaultCodecConfig() Codec {
+	return Codec{
+		CodecConfig: config.CodecConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+	}
+}
+
+// InitHandler initialize the codec plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeCodecConfig, error) {
+	conf := DefaultCodecConfig()
+	if raw != nil {
+		if err := config.ReflectConfig(raw, &conf); err != nil {
+			return nil, err
+		}
+	}
+	return &conf, nil
+}
+
+// Decode returns an event from 'data' as CEF format, adding provided 'eventExtra'
+func (c *Codec) Decode(ctx context.Context, data interface{},
+	eventExtra map[string]interface{},
+	msgChan chan<- logevent.LogEvent) (ok bool, err error) {
+
+	if config.GetMutexInstance().GetPause() == true {
+		return false, errors.New("Pause input")
+	}
+
+	event := logevent.LogEvent{
+		Timestamp: time.Now(),
+		Extra:     eventExtra,
+	}
+
+	switch v := data.(type) {
+	case string:
+		err = c.decode(v, &event)
+	case []byte:
+		err = c.decode(string(v), &event)
+	default:
+		err = config.ErrDecodeData
+	}
+	if err != nil {
+		event.AddTag(ErrorTag)
+		goglog.Logger.Error(err)
+	}
+
+	msgChan <- event
+	ok = true
+
+	return
+}
+
+// DecodeEvent decodes 'data' as CEF format to event
+func (c *Codec) DecodeEvent(data []byte, v interface{}) error {
+	event := logevent.LogEvent{
+		Timestamp: time.Now(),
+	}
+
+	if err := c.decode(string(data), &event); err != nil {
+		event.AddTag(ErrorTag)
+		goglog.Logger.Error(err)
+	}
+
+	switch e := v.(type) {
+	case *interface{}:
+		*e = event
+	case *logevent.LogEvent:
+		*e = event
+	default:
+		return config.ErrorUnsupportedTargetEvent
+	}
+	return nil
+}
+
+func (c *Codec) decode(data string, event *logevent.LogEvent) (err error) {
+	data = strings.TrimRight(data, "\r\n")
+
+	pos := strings.Index(data, "CEF:")
+	if pos < 0 {
+		event.Message = data
+		return ErrorInvalidHeader1.New(nil, data)
+	}
+
+	headers, extension := SplitHeader(data[pos+len("CEF:"):], len(headerFields)-1)
+	if len(headers) < len(headerFields)-1 {
+		event.Message = data
+		return ErrorInvalidHeader1.New(nil, data)
+	}
+	// severity is the last header, the extension follows its delimiter
+	if severity, rest := SplitHeader(extension, 1); len(severity) > 0 {
+		headers = append(headers, severity[0])
+		extension = rest
+	} else {
+		headers = append(headers, UnescapeHeader(extension))
+		extension = ""
+	}
+
+	if pos > 0 {
+		c.setValue(event, FieldSyslog, strings.TrimSpace(data[:pos]))
+	}
+	for i, field := range headerFields {
+		c.setValue(event, field, headers[i])
+	}
+
+	for _, pair := range ParseExtension(extension) {
+		switch pair.Key {
+		case "msg", "message":
+			event.Message = pair.Value
+			continue
+		case "rt", "deviceReceiptTime":
+			if ts, err2 := ParseTime(pair.Value); err2 == nil {
+				event.Timestamp = ts
+				if c.ECSCompatibility {
+					continue
+				}
+			}
+		}
+		c.setValue(event, pair.Key, pair.Value)
+	}
+
+	return nil
+}
+
+func (c *Codec) setValue(event *logevent.LogEvent, key string, value string) {
+	if !c.ECSCompatibility {
+		event.SetValue(key, value)
+		return
+	}
+	field, ok := ecsMapping[key]
+	if !ok {
+		field = "cef.extensions." + key
+	}
+	event.SetValue(field, ecsValue(field, value))
+}
+
+// Encode serializes the event into a CEF record
+func (c *Codec) Encode(ctx context.Context, event logevent.LogEvent, dataChan chan<- []byte) (ok bool, err error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("CEF:0")
+	for _, header := range []struct{ format, field, def string }{
+		{c.Vendor, FieldDeviceVendor, "gogstash"},
+		{c.Product, FieldDeviceProduct, "gogstash"},
+		{c.Version, FieldDeviceVersion, "1.0"},
+		{c.Signature, FieldSignature, "gogstash"},
+		{c.Name, FieldName, "gogstash"},
+		{c.Severity, FieldSeverity, "6"},
+	} {
+		value := header.def
+		if header.format != "" {
+			value = event.Format(header.format)
+		} else if s := event.GetString(header.field); s != "" {
+			value = s
+		}
+		buf.WriteByte('|')
+		buf.WriteString(EscapeHeader(value))
+	}
+	buf.WriteByte('|')
+
+	var pairs []string
+	if event.Message != "" {
+		pairs = append(pairs, "msg="+EscapeValue(event.Message))
+	}
+	for _, field := range c.encodeFields(event) {
+		value, found := event.GetValue(field)
+		if !found || value == nil {
+			continue
+		}
+		pairs = append(pairs, field+"="+EscapeValue(fmt.Sprintf("%v", value)))
+	}
+	buf.WriteString(strings.Join(pairs, " "))
+
+	dataChan <- buf.Bytes()
+	return true, nil
+}
+
+func (c *Codec) encodeFields(event logevent.LogEvent) []string {
+	if len(c.Fields) > 0 {
+		return c.Fields
+	}
+	fields := make([]string, 0, len(event.Extra))
+	for field := range event.Extra {
+		switch field {
+		case FieldVersion, FieldDeviceVendor, FieldDeviceProduct, FieldDeviceVersion,
+			FieldSignature, FieldName, FieldSeverity, FieldSyslog:
+			continue
+		}
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Pair is a key/value pair from CEF or LEEF extension
+type Pair struct {
+	Key   string
+	Value string
+}
+
+// SplitHeader splits at most n '|' delimited header fields from s,
+// unescaping '\|' and '\\', and returns the remaining string
+func SplitHeader(s string, n int) (headers []string, rest string) {
+	start := 0
+	for i := 0; i < len(s) && len(headers) < n; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '|':
+			headers = append(headers, UnescapeHeader(s[start:i]))
+			start = i + 1
+		}
+	}
+	return headers, s[start:]
+}
+
+// ParseExtension parses space separated key=value pairs of CEF extension,
+// values may contain spaces and escaped '=' characters
+func ParseExtension(ext string) (pairs []Pair) {
+	key := ""
+	valueStart := -1
+	for i := 0; i < len(ext); i++ {
+		switch ext[i] {
+		case '\\':
+			i++
+			continue
+		case '=':
+		default:
+			continue
+		}
+		keyStart := i
+		for keyStart > 0 && ext[keyStart-1] != ' ' {
+			keyStart--
+		}
+		if !isValidKey(ext[keyStart:i]) {
+			continue
+		}
+		if valueStart >= 0 && keyStart > valueStart {
+			pairs = append(pairs, Pair{Key: key, Value: UnescapeValue(strings.TrimSpace(ext[valueStart:keyStart]))})
+		}
+		key = ext[keyStart:i]
+		valueStart = i + 1
+	}
+	if valueStart >= 0 {
+		pairs = append(pairs, Pair{Key: key, Value: UnescapeValue(strings.TrimSpace(ext[valueStart:]))})
+	}
+	return
+}
+
+func isValidKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_', r == '.', r == '-', r == '[', r == ']':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// UnescapeHeader unescapes '\|' and '\\' in a header field
+func UnescapeHeader(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	return strings.NewReplacer(`\|`, `|`, `\\`, `\`).Replace(s)
+}
+
+// EscapeHeader escapes '|' and '\' in a header field
+func EscapeHeader(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ").Replace(s)
+}
+
+var valueUnescaper = strings.NewReplacer(`\=`, `=`, `\\`, `\`, `\|`, `|`, `\n`, "\n", `\r`, "\r", `\t`, "\t")
+
+// UnescapeValue unescapes an extension value
+func UnescapeValue(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	return valueUnescaper.Replace(s)
+}
+
+var valueEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+
+// EscapeValue escapes '=', '\' and line breaks in an extension value
+func EscapeValue(s string) string {
+	return valueEscaper.Replace(s)
+}
+
+var timeLayouts = []string{
+	"Jan 02 2006 15:04:05.000 MST",
+	"Jan 02 2006 15:04:05 MST",
+	"Jan 02 2006 15:04:05.000",
+	"Jan 02 2006 15:04:05",
+	"Jan 02 15:04:05.000",
+	"Jan 02 15:04:05",
+	time.RFC3339Nano,
+}
+
+// ParseTime parses CEF and LEEF timestamps, either milliseconds since epoch
+// or one of the "MMM dd yyyy HH:mm:ss" variants
+func ParseTime(s string) (t time.Time, err error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(0, ms*int64(time.Millisecond)), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err = time.Parse(layout, s); err == nil {
+			if t.Year() == 0 {
+				t = t.AddDate(time.Now().Year(), 0, 0)
+			}
+			return
+		}
+	}
+	return
+}
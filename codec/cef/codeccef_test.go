@@ -0,0 +1,157 @@
+package codeccef
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+}
+
+func TestDecode(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, nil)
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 1)
+
+	ok, err := codec.Decode(ctx, []byte(`CEF:0|Security|threatmanager|1.0|100|detected a \| in message|10|src=10.0.0.1 act=blocked a \= dst=2.1.2.2 msg=User signed in from 10.0.0.1 spt=1232`), nil, msgChan)
+	require.NoError(err)
+	assert.True(ok)
+	require.Len(msgChan, 1)
+	event := <-msgChan
+	assert.Equal("User signed in from 10.0.0.1", event.Message)
+	assert.Equal(map[string]interface{}{
+		"cefVersion":         "0",
+		"deviceVendor":       "Security",
+		"deviceProduct":      "threatmanager",
+		"deviceVersion":      "1.0",
+		"deviceEventClassId": "100",
+		"name":               "detected a | in message",
+		"severity":           "10",
+		"src":                "10.0.0.1",
+		"act":                "blocked a =",
+		"dst":                "2.1.2.2",
+		"spt":                "1232",
+	}, event.Extra)
+
+	// syslog header is kept, receipt time is parsed
+	ok, err = codec.Decode(ctx, "<134>Feb 13 10:00:00 fw01 CEF:0|Vendor|Product|2|sig|name|Low|rt=1549965600000 cs1Label=rule cs1=allow all\n", nil, msgChan)
+	require.NoError(err)
+	assert.True(ok)
+	event = <-msgChan
+	assert.Equal("<134>Feb 13 10:00:00 fw01", event.Extra["syslog"])
+	assert.Equal("allow all", event.Extra["cs1"])
+	assert.True(time.Unix(1549965600, 0).Equal(event.Timestamp))
+
+	// escaped pipe in severity, the extension starts after the unescaped delimiter
+	ok, err = codec.Decode(ctx, []byte(`CEF:0|Vendor|Product|2|sig|name|Very\|High|src=10.0.0.1 msg=a \| b`), nil, msgChan)
+	require.NoError(err)
+	assert.True(ok)
+	event = <-msgChan
+	assert.Equal("Very|High", event.Extra["severity"])
+	assert.Equal("10.0.0.1", event.Extra["src"])
+	assert.Equal("a | b", event.Message)
+
+	// malformed data
+	ok, err = codec.Decode(ctx, []byte(`not a cef record`), nil, msgChan)
+	require.Error(err) // fail to decode
+	assert.True(ok)
+	event = <-msgChan
+	assert.Equal([]string{ErrorTag}, event.Tags)
+	assert.Equal("not a cef record", event.Message)
+}
+
+func TestDecodeECS(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, &config.ConfigRaw{"type": ModuleName, "ecs_compatibility": true})
+	require.NoError(err)
+
+	event := logevent.LogEvent{}
+	require.NoError(codec.DecodeEvent([]byte(`CEF:0|Vendor|Product|2|sig|name|5|src=10.0.0.1 spt=1232 customKey=x y`), &event))
+	assert.Equal(map[string]interface{}{
+		"cef": map[string]interface{}{
+			"version":    "0",
+			"name":       "name",
+			"extensions": map[string]interface{}{"customKey": "x y"},
+		},
+		"observer": map[string]interface{}{
+			"vendor":  "Vendor",
+			"product": "Product",
+			"version": "2",
+		},
+		"event": map[string]interface{}{
+			"code":     "sig",
+			"severity": int64(5),
+		},
+		"source": map[string]interface{}{
+			"ip":   "10.0.0.1",
+			"port": int64(1232),
+		},
+	}, event.Extra)
+}
+
+func TestEncode(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, &config.ConfigRaw{"type": ModuleName, "vendor": "Acme", "product": "%{product}"})
+	require.NoError(err)
+
+	dataChan := make(chan []byte, 1)
+	ok, err := codec.Encode(ctx, logevent.LogEvent{
+		Message: "a=b",
+		Extra: map[string]interface{}{
+			"product": "fire|wall",
+			"src":     "10.0.0.1",
+		},
+	}, dataChan)
+	require.NoError(err)
+	assert.True(ok)
+	assert.Equal(`CEF:0|Acme|fire\|wall|1.0|gogstash|gogstash|6|msg=a\=b product=fire|wall src=10.0.0.1`, string(<-dataChan))
+
+	// decoded events are encoded back with their header
+	msgChan := make(chan logevent.LogEvent, 1)
+	codec, err = InitHandler(ctx, nil)
+	require.NoError(err)
+	record := `CEF:0|Security|threatmanager|1.0|100|worm stopped|10|msg=multi word \= value dst=2.1.2.2 src=10.0.0.1`
+	_, err = codec.Decode(ctx, record, nil, msgChan)
+	require.NoError(err)
+	_, err = codec.Encode(ctx, <-msgChan, dataChan)
+	require.NoError(err)
+	assert.Equal(record, string(<-dataChan))
+}
+
+func TestParseExtension(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	assert.Equal([]Pair{
+		{Key: "filePath", Value: `C:\Program Files`},
+		{Key: "url", Value: "http://x/?a=b"},
+		{Key: "msg", Value: "line1\nline2"},
+	}, ParseExtension(`filePath=C:\\Program Files url=http://x/?a\=b msg=line1\nline2`))
+	assert.Empty(ParseExtension(""))
+}
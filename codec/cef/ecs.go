@@ -0,0 +1,123 @@
+package codeccef
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ecsMapping maps CEF header names, extension short names and full names
+// to Elastic Common Schema fields
+var ecsMapping = map[string]string{
+	FieldVersion:       "cef.version",
+	FieldDeviceVendor:  "observer.vendor",
+	FieldDeviceProduct: "observer.product",
+	FieldDeviceVersion: "observer.version",
+	FieldSignature:     "event.code",
+	FieldName:          "cef.name",
+	FieldSeverity:      "event.severity",
+	FieldSyslog:        "log.syslog.header",
+
+	"act":                          "event.action",
+	"deviceAction":                 "event.action",
+	"app":                          "network.protocol",
+	"applicationProtocol":          "network.protocol",
+	"cat":                          "cef.category",
+	"deviceEventCategory":          "cef.category",
+	"cnt":                          "event.count",
+	"baseEventCount":               "event.count",
+	"deviceDirection":              "network.direction",
+	"deviceExternalId":             "observer.name",
+	"deviceInboundInterface":       "observer.ingress.interface.name",
+	"deviceOutboundInterface":      "observer.egress.interface.name",
+	"dhost":                        "destination.domain",
+	"destinationHostName":          "destination.domain",
+	"dmac":                         "destination.mac",
+	"destinationMacAddress":        "destination.mac",
+	"dntdom":                       "destination.registered_domain",
+	"destinationNtDomain":          "destination.registered_domain",
+	"dpid":                         "destination.process.pid",
+	"destinationProcessId":         "destination.process.pid",
+	"dpriv":                        "destination.user.group.name",
+	"destinationUserPrivileges":    "destination.user.group.name",
+	"dproc":                        "destination.process.name",
+	"destinationProcessName":       "destination.process.name",
+	"dpt":                          "destination.port",
+	"destinationPort":              "destination.port",
+	"dst":                          "destination.ip",
+	"destinationAddress":           "destination.ip",
+	"destinationTranslatedAddress": "destination.nat.ip",
+	"destinationTranslatedPort":    "destination.nat.port",
+	"duid":                         "destination.user.id",
+	"destinationUserId":            "destination.user.id",
+	"duser":                        "destination.user.name",
+	"destinationUserName":          "destination.user.name",
+	"dvc":                          "observer.ip",
+	"deviceAddress":                "observer.ip",
+	"dvchost":                      "observer.hostname",
+	"deviceHostName":               "observer.hostname",
+	"dvcmac":                       "observer.mac",
+	"deviceMacAddress":             "observer.mac",
+	"dvcpid":                       "process.pid",
+	"deviceProcessId":              "process.pid",
+	"end":                          "event.end",
+	"endTime":                      "event.end",
+	"externalId":                   "event.id",
+	"fname":                        "file.name",
+	"fileName":                     "file.name",
+	"filePath":                     "file.path",
+	"fsize":                        "file.size",
+	"fileSize":                     "file.size",
+	"in":                           "source.bytes",
+	"bytesIn":                      "source.bytes",
+	"out":                          "destination.bytes",
+	"bytesOut":                     "destination.bytes",
+	"outcome":                      "event.outcome",
+	"eventOutcome":                 "event.outcome",
+	"proto":                        "network.transport",
+	"transportProtocol":            "network.transport",
+	"reason":                       "event.reason",
+	"request":                      "url.original",
+	"requestUrl":                   "url.original",
+	"requestClientApplication":     "user_agent.original",
+	"requestMethod":                "http.request.method",
+	"rt":                           "event.created",
+	"deviceReceiptTime":            "event.created",
+	"shost":                        "source.domain",
+	"sourceHostName":               "source.domain",
+	"smac":                         "source.mac",
+	"sourceMacAddress":             "source.mac",
+	"sntdom":                       "source.registered_domain",
+	"sourceNtDomain":               "source.registered_domain",
+	"spid":                         "source.process.pid",
+	"sourceProcessId":              "source.process.pid",
+	"sproc":                        "source.process.name",
+	"sourceProcessName":            "source.process.name",
+	"spt":                          "source.port",
+	"sourcePort":                   "source.port",
+	"src":                          "source.ip",
+	"sourceAddress":                "source.ip",
+	"sourceTranslatedAddress":      "source.nat.ip",
+	"sourceTranslatedPort":         "source.nat.port",
+	"start":                        "event.start",
+	"startTime":                    "event.start",
+	"suid":                         "source.user.id",
+	"sourceUserId":                 "source.user.id",
+	"suser":                        "source.user.name",
+	"sourceUserName":               "source.user.name",
+}
+
+// ecsValue converts numeric ECS fields, other values are kept as string
+func ecsValue(field string, value string) interface{} {
+	switch {
+	case strings.HasSuffix(field, ".port"),
+		strings.HasSuffix(field, ".pid"),
+		strings.HasSuffix(field, ".bytes"),
+		field == "file.size",
+		field == "event.count",
+		field == "event.severity":
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	}
+	return value
+}
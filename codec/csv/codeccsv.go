@@ -0,0 +1,376 @@
+package codeccsv
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "csv"
+
+// TSVModuleName is the name used in config file for tab separated values
+const TSVModuleName = "tsv"
+
+// ErrorTag tag added to event when process module failed
+const ErrorTag = "gogstash_codec_csv_error"
+
+// errors
+var (
+	ErrorInvalidSeparator1  = errutil.NewFactory("invalid separator: %q")
+	ErrorInvalidQuoteChar1  = errutil.NewFactory("invalid quote_char: %q")
+	ErrorInvalidConvert2    = errutil.NewFactory("invalid convert type %q of column %q")
+	ErrorUnterminatedQuote1 = errutil.NewFactory("unterminated quoted field: %q")
+	ErrorConvertColumn2     = errutil.NewFactory("convert column %q value %q failed")
+	ErrorNoColumns          = errutil.NewFactory("no columns defined for encoding")
+)
+
+// supported convert types
+const (
+	convertString  = "string"
+	convertInteger = "integer"
+	convertFloat   = "float"
+	convertBoolean = "boolean"
+)
+
+// Codec default struct for codec
+type Codec struct {
+	config.CodecConfig
+
+	// column names, default: "column1", "column2", ...
+	Columns []string `json:"columns"`
+	// column separator, default: "," for csv and "\t" for tsv
+	Separator string `json:"separator"`
+	// character used to quote fields, default: '"'
+	QuoteChar string `json:"quote_char"`
+	// use values of the first row as column names, default: false
+	AutodetectColumnNames bool `json:"autodetect_column_names"`
+	// skip rows equal to column names, default: false
+	SkipHeader bool `json:"skip_header"`
+	// don't set fields for empty columns, default: false
+	SkipEmptyColumns bool `json:"skip_empty_columns"`
+	// convert column values, one of ["string", "integer", "float", "boolean"]
+	Convert map[string]string `json:"convert"`
+	// store columns into this field instead of event root
+	Target string `json:"target"`
+	// write column names as first row of each file created by outputs, default: false
+	IncludeHeaders bool `json:"include_headers"`
+
+	separator rune
+	quote     rune
+
+	mutex sync.Mutex
+}
+
+// DefaultCodecConfig returns an Codec struct with default values
+func DefaultCodecConfig() Codec {
+	return Codec{
+		CodecConfig: config.CodecConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		Separator: ",",
+		QuoteChar: `"`,
+	}
+}
+
+// InitHandler initialize the codec plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeCodecConfig, error) {
+	conf := DefaultCodecConfig()
+	return initCodec(raw, &conf)
+}
+
+// InitTSVHandler initialize the codec plugin for tab separated values
+func InitTSVHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeCodecConfig, error) {
+	conf := DefaultCodecConfig()
+	conf.Type = TSVModuleName
+	conf.Separator = "\t"
+	return initCodec(raw, &conf)
+}
+
+func initCodec(raw *config.ConfigRaw, conf *Codec) (config.TypeCodecConfig, error) {
+	if raw != nil {
+		if err := config.ReflectConfig(raw, conf); err != nil {
+			return nil, err
+		}
+	}
+
+	if utf8.RuneCountInString(conf.Separator) != 1 {
+		return nil, ErrorInvalidSeparator1.New(nil, conf.Separator)
+	}
+	conf.separator, _ = utf8.DecodeRuneInString(conf.Separator)
+	if utf8.RuneCountInString(conf.QuoteChar) != 1 {
+		return nil, ErrorInvalidQuoteChar1.New(nil, conf.QuoteChar)
+	}
+	conf.quote, _ = utf8.DecodeRuneInString(conf.QuoteChar)
+
+	for column, typ := range conf.Convert {
+		switch typ {
+		case convertString, convertInteger, convertFloat, convertBoolean:
+		default:
+			return nil, ErrorInvalidConvert2.New(nil, typ, column)
+		}
+	}
+
+	return conf, nil
+}
+
+// Decode returns events from 'data' as CSV format, one event per row, adding provided 'eventExtra'
+func (c *Codec) Decode(ctx context.Context, data interface{},
+	eventExtra map[string]interface{},
+	msgChan chan<- logevent.LogEvent) (ok bool, err error) {
+
+	if config.GetMutexInstance().GetPause() == true {
+		return false, errors.New("Pause input")
+	}
+
+	var text string
+	switch v := data.(type) {
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		err = config.ErrDecodeData
+	}
+
+	var records [][]string
+	if err == nil {
+		records, err = c.parse(text)
+	}
+	if err != nil {
+		event := logevent.LogEvent{
+			Timestamp: time.Now(),
+			Message:   text,
+			Extra:     eventExtra,
+		}
+		event.AddTag(ErrorTag)
+		goglog.Logger.Error(err)
+		msgChan <- event
+		return true, err
+	}
+
+	for _, record := range records {
+		event, send := c.recordEvent(record, eventExtra)
+		if !send {
+			continue
+		}
+		msgChan <- event
+		ok = true
+	}
+
+	return
+}
+
+// DecodeEvent decodes the first row of 'data' as CSV format to event
+func (c *Codec) DecodeEvent(data []byte, v interface{}) error {
+	event := logevent.LogEvent{
+		Timestamp: time.Now(),
+	}
+
+	records, err := c.parse(string(data))
+	if err == nil && len(records) > 0 {
+		event, _ = c.recordEvent(records[0], nil)
+	} else {
+		event.Message = string(data)
+		event.AddTag(ErrorTag)
+		if err != nil {
+			goglog.Logger.Error(err)
+		}
+	}
+
+	switch e := v.(type) {
+	case *interface{}:
+		*e = event
+	case *logevent.LogEvent:
+		*e = event
+	default:
+		return config.ErrorUnsupportedTargetEvent
+	}
+	return nil
+}
+
+// recordEvent returns an event of the record, send will be false for header rows
+func (c *Codec) recordEvent(record []string, eventExtra map[string]interface{}) (event logevent.LogEvent, send bool) {
+	c.mutex.Lock()
+	if len(c.Columns) < 1 && c.AutodetectColumnNames {
+		c.Columns = record
+		c.mutex.Unlock()
+		return event, false
+	}
+	columns := c.Columns
+	c.mutex.Unlock()
+
+	if c.SkipHeader && isHeader(record, columns) {
+		return event, false
+	}
+
+	extra := make(map[string]interface{}, len(eventExtra)+len(record))
+	for k, v := range eventExtra {
+		extra[k] = v
+	}
+	event = logevent.LogEvent{
+		Timestamp: time.Now(),
+		Extra:     extra,
+	}
+
+	for i, value := range record {
+		if value == "" && c.SkipEmptyColumns {
+			continue
+		}
+		column := "column" + strconv.Itoa(i+1)
+		if i < len(columns) {
+			column = columns[i]
+		}
+		converted, err := c.convert(column, value)
+		if err != nil {
+			event.AddTag(ErrorTag)
+			goglog.Logger.Warn(err)
+		}
+		if c.Target != "" {
+			column = c.Target + "." + column
+		}
+		event.SetValue(column, converted)
+	}
+
+	return event, true
+}
+
+func isHeader(record []string, columns []string) bool {
+	if len(record) != len(columns) {
+		return false
+	}
+	for i := range record {
+		if record[i] != columns[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Codec) convert(column string, value string) (interface{}, error) {
+	switch c.Convert[column] {
+	case convertInteger:
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return v, nil
+		}
+	case convertFloat:
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v, nil
+		}
+	case convertBoolean:
+		if v, err := strconv.ParseBool(value); err == nil {
+			return v, nil
+		}
+	default:
+		return value, nil
+	}
+	return value, ErrorConvertColumn2.New(nil, column, value)
+}
+
+// parse splits data into records, quoted fields may contain separators,
+// line breaks and doubled quote characters
+func (c *Codec) parse(data string) (records [][]string, err error) {
+	var (
+		record  []string
+		field   strings.Builder
+		quoted  bool
+		started bool
+	)
+	endField := func() {
+		record = append(record, field.String())
+		field.Reset()
+		started = false
+	}
+	endRecord := func() {
+		endField()
+		if len(record) > 1 || record[0] != "" {
+			records = append(records, record)
+		}
+		record = nil
+	}
+
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRuneInString(data[i:])
+		i += size
+		switch {
+		case quoted && r == c.quote:
+			if next, nsize := utf8.DecodeRuneInString(data[i:]); i < len(data) && next == c.quote {
+				field.WriteRune(c.quote)
+				i += nsize
+			} else {
+				quoted = false
+			}
+		case quoted:
+			field.WriteRune(r)
+		case r == c.quote && !started:
+			quoted = true
+			started = true
+		case r == c.separator:
+			endField()
+		case r == '\r' && strings.HasPrefix(data[i:], "\n"):
+		case r == '\n':
+			endRecord()
+		default:
+			field.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, ErrorUnterminatedQuote1.New(nil, data)
+	}
+	if started || field.Len() > 0 || len(record) > 0 {
+		endRecord()
+	}
+	return records, nil
+}
+
+// Encode serializes the configured columns of event into a CSV row
+func (c *Codec) Encode(ctx context.Context, event logevent.LogEvent, dataChan chan<- []byte) (ok bool, err error) {
+	if len(c.Columns) < 1 {
+		return false, ErrorNoColumns.New(nil)
+	}
+
+	values := make([]string, len(c.Columns))
+	for i, column := range c.Columns {
+		values[i] = event.GetString(column)
+	}
+	dataChan <- []byte(c.format(values))
+
+	return true, nil
+}
+
+// EncodeHeader returns the row of column names if include_headers is set, nil otherwise
+func (c *Codec) EncodeHeader() (data []byte, err error) {
+	if !c.IncludeHeaders {
+		return nil, nil
+	}
+	if len(c.Columns) < 1 {
+		return nil, ErrorNoColumns.New(nil)
+	}
+	return []byte(c.format(c.Columns)), nil
+}
+
+func (c *Codec) format(values []string) string {
+	quote := string(c.quote)
+	fields := make([]string, len(values))
+	for i, value := range values {
+		if value == "" || !strings.ContainsAny(value, c.Separator+quote+"\r\n") &&
+			value[0] != ' ' && value[len(value)-1] != ' ' {
+			fields[i] = value
+			continue
+		}
+		fields[i] = quote + strings.Replace(value, quote, quote+quote, -1) + quote
+	}
+	return strings.Join(fields, c.Separator)
+}
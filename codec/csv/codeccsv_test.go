@@ -0,0 +1,177 @@
+package codeccsv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+}
+
+func TestDecode(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, &config.ConfigRaw{
+		"columns": []interface{}{"name", "age", "active", "note"},
+		"convert": map[string]interface{}{"age": "integer", "active": "boolean"},
+	})
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+
+	ok, err := codec.Decode(ctx, []byte("alice,30,true,\"hello, \"\"world\"\"\"\n"), map[string]interface{}{"host": "h"}, msgChan)
+	require.NoError(err)
+	assert.True(ok)
+	require.Len(msgChan, 1)
+	event := <-msgChan
+	assert.Equal(map[string]interface{}{
+		"host":   "h",
+		"name":   "alice",
+		"age":    int64(30),
+		"active": true,
+		"note":   `hello, "world"`,
+	}, event.Extra)
+
+	// multiple rows, quoted line break and extra column
+	ok, err = codec.Decode(ctx, "bob,x,false,\"multi\r\nline\"\r\ncarol,41,true,,extra\r\n", nil, msgChan)
+	require.NoError(err)
+	assert.True(ok)
+	require.Len(msgChan, 2)
+	event = <-msgChan
+	assert.Equal([]string{ErrorTag}, event.Tags)
+	assert.Equal("x", event.Extra["age"])
+	assert.Equal("multi\r\nline", event.Extra["note"])
+	event = <-msgChan
+	assert.Equal("", event.Extra["note"])
+	assert.Equal("extra", event.Extra["column5"])
+
+	// unterminated quote
+	ok, err = codec.Decode(ctx, `dave,"oops`, nil, msgChan)
+	require.Error(err)
+	assert.True(ok)
+	event = <-msgChan
+	assert.Equal([]string{ErrorTag}, event.Tags)
+	assert.Equal(`dave,"oops`, event.Message)
+}
+
+func TestDecodeHeader(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, &config.ConfigRaw{
+		"separator":               ";",
+		"quote_char":              "'",
+		"autodetect_column_names": true,
+		"skip_header":             true,
+		"skip_empty_columns":      true,
+		"target":                  "csv",
+	})
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+
+	ok, err := codec.Decode(ctx, "a;b\n", nil, msgChan)
+	require.NoError(err)
+	assert.False(ok)
+	require.Len(msgChan, 0)
+
+	ok, err = codec.Decode(ctx, "'x;y';\na;b\n", nil, msgChan)
+	require.NoError(err)
+	assert.True(ok)
+	require.Len(msgChan, 1)
+	event := <-msgChan
+	assert.Equal(map[string]interface{}{
+		"csv": map[string]interface{}{"a": "x;y"},
+	}, event.Extra)
+}
+
+func TestDecodeTSV(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitTSVHandler(ctx, &config.ConfigRaw{
+		"columns": []interface{}{"a", "b"},
+	})
+	require.NoError(err)
+	assert.Equal(TSVModuleName, codec.GetType())
+
+	event := logevent.LogEvent{}
+	require.NoError(codec.DecodeEvent([]byte("1,2\t3 4"), &event))
+	assert.Equal(map[string]interface{}{"a": "1,2", "b": "3 4"}, event.Extra)
+
+	// invalid config
+	_, err = InitHandler(ctx, &config.ConfigRaw{"separator": ",,"})
+	require.Error(err)
+	_, err = InitHandler(ctx, &config.ConfigRaw{"convert": map[string]interface{}{"a": "date"}})
+	require.Error(err)
+}
+
+func TestEncode(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, &config.ConfigRaw{
+		"columns":         []interface{}{"message", "user.name", "count", "missing"},
+		"include_headers": true,
+	})
+	require.NoError(err)
+
+	dataChan := make(chan []byte, 10)
+	event := logevent.LogEvent{
+		Message: `say "hi"`,
+		Extra: map[string]interface{}{
+			"user":  map[string]interface{}{"name": "a,b"},
+			"count": 3,
+		},
+	}
+	ok, err := codec.Encode(ctx, event, dataChan)
+	require.NoError(err)
+	assert.True(ok)
+	ok, err = codec.Encode(ctx, event, dataChan)
+	require.NoError(err)
+	assert.True(ok)
+	require.Len(dataChan, 2)
+	assert.Equal(`"say ""hi""","a,b",3,`, string(<-dataChan))
+	assert.Equal(`"say ""hi""","a,b",3,`, string(<-dataChan))
+
+	// header is asked by outputs for each file
+	header, err := codec.(config.TypeHeaderCodecConfig).EncodeHeader()
+	require.NoError(err)
+	assert.Equal("message,user.name,count,missing", string(header))
+
+	// columns are required
+	codec, err = InitHandler(ctx, &config.ConfigRaw{"include_headers": true})
+	require.NoError(err)
+	_, err = codec.Encode(ctx, event, dataChan)
+	require.Error(err)
+	_, err = codec.(config.TypeHeaderCodecConfig).EncodeHeader()
+	require.Error(err)
+
+	// no header without include_headers
+	codec, err = InitHandler(ctx, &config.ConfigRaw{"columns": []interface{}{"message"}})
+	require.NoError(err)
+	header, err = codec.(config.TypeHeaderCodecConfig).EncodeHeader()
+	require.NoError(err)
+	assert.Nil(header)
+}
@@ -0,0 +1,306 @@
+package codecgelf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "gelf"
+
+// ErrorTag tag added to event when process module failed
+const ErrorTag = "gogstash_codec_gelf_error"
+
+// Version is the GELF spec version written by Encode
+const Version = "1.1"
+
+// chunked GELF message header, see http://docs.graylog.org/en/latest/pages/gelf.html
+const (
+	// ChunkHeaderSize is the size of chunk header: magic, 8 bytes message id, sequence number and count
+	ChunkHeaderSize = 12
+	// MaxChunks is the maximum number of chunks of one message
+	MaxChunks = 128
+)
+
+// ChunkMagic is the first two bytes of a chunked GELF datagram
+var ChunkMagic = []byte{0x1e, 0x0f}
+
+// supported compression types
+const (
+	CompressionNone = config.CompressionNone
+	CompressionGzip = config.CompressionGzip
+	CompressionZlib = config.CompressionZlib
+)
+
+// errors
+var (
+	ErrorInvalidCompression1 = errutil.NewFactory("invalid compression type: %q")
+	ErrorNotObject           = errutil.NewFactory("GELF payload is not a JSON object")
+)
+
+// syslog severity of level names
+var levels = map[string]int{
+	"emerg":         0,
+	"emergency":     0,
+	"panic":         0,
+	"alert":         1,
+	"crit":          2,
+	"critical":      2,
+	"fatal":         2,
+	"err":           3,
+	"error":         3,
+	"warn":          4,
+	"warning":       4,
+	"notice":        5,
+	"info":          6,
+	"informational": 6,
+	"debug":         7,
+	"trace":         7,
+}
+
+// Codec default struct for codec
+type Codec struct {
+	config.CodecConfig
+
+	// event field used as GELF host when encoding, default: "host", fallback to hostname
+	HostField string `json:"host_field"`
+	// event field used as GELF level when encoding, default: "level"
+	LevelField string `json:"level_field"`
+	// GELF level when event has no level field, default: 6 (informational)
+	Level int `json:"level"`
+
+	hostname string
+}
+
+// DefaultCodecConfig returns an Codec struct with default values
+func DefaultCodecConfig() Codec {
+	return Codec{
+		CodecConfig: config.CodecConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		HostField:  "host",
+		LevelField: "level",
+		Level:      6,
+	}
+}
+
+// InitHandler initialize the codec plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeCodecConfig, error) {
+	conf := DefaultCodecConfig()
+	if raw != nil {
+		if err := config.ReflectConfig(raw, &conf); err != nil {
+			return nil, err
+		}
+	}
+	conf.hostname, _ = os.Hostname()
+	return &conf, nil
+}
+
+// Decode returns an event from 'data' as GELF format, gzip and zlib compressed payloads
+// are decompressed, adding provided 'eventExtra'
+func (c *Codec) Decode(ctx context.Context, data interface{},
+	eventExtra map[string]interface{},
+	msgChan chan<- logevent.LogEvent) (ok bool, err error) {
+
+	if config.GetMutexInstance().GetPause() == true {
+		return false, errors.New("Pause input")
+	}
+
+	var raw []byte
+	switch v := data.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		err = config.ErrDecodeData
+	}
+
+	event := logevent.LogEvent{
+		Timestamp: time.Now(),
+		Extra:     eventExtra,
+	}
+	if err == nil {
+		err = c.decode(raw, &event)
+	}
+	if err != nil {
+		event.AddTag(ErrorTag)
+		goglog.Logger.Error(err)
+	}
+
+	msgChan <- event
+	return true, err
+}
+
+// DecodeEvent decodes 'data' as GELF format to event
+func (c *Codec) DecodeEvent(data []byte, v interface{}) error {
+	event := logevent.LogEvent{
+		Timestamp: time.Now(),
+	}
+
+	if err := c.decode(data, &event); err != nil {
+		event.AddTag(ErrorTag)
+		goglog.Logger.Error(err)
+	}
+
+	switch e := v.(type) {
+	case *interface{}:
+		*e = event
+	case *logevent.LogEvent:
+		*e = event
+	default:
+		return config.ErrorUnsupportedTargetEvent
+	}
+	return nil
+}
+
+func (c *Codec) decode(data []byte, event *logevent.LogEvent) error {
+	payload, err := Decompress(data)
+	if err != nil {
+		event.Message = string(data)
+		return err
+	}
+	payload = bytes.TrimRight(payload, "\x00\r\n")
+
+	var fields map[string]interface{}
+	if err = jsoniter.Unmarshal(payload, &fields); err != nil {
+		event.Message = string(payload)
+		return err
+	}
+	if fields == nil {
+		event.Message = string(payload)
+		return ErrorNotObject.New(nil)
+	}
+
+	extra := make(map[string]interface{}, len(event.Extra)+len(fields))
+	for k, v := range event.Extra {
+		extra[k] = v
+	}
+	event.Extra = extra
+
+	for key, value := range fields {
+		switch key {
+		case "short_message":
+			event.Message = fmt.Sprintf("%v", value)
+		case "timestamp":
+			if ts, ok := value.(float64); ok {
+				sec, frac := math.Modf(ts)
+				event.Timestamp = time.Unix(int64(sec), int64(math.Round(frac*1e3))*int64(time.Millisecond)).UTC()
+			}
+		case "_id":
+			// reserved by spec
+		default:
+			// additional fields are prefixed with underscore
+			event.Extra[strings.TrimPrefix(key, "_")] = value
+		}
+	}
+	return nil
+}
+
+// Encode serializes the event into an uncompressed GELF message
+func (c *Codec) Encode(ctx context.Context, event logevent.LogEvent, dataChan chan<- []byte) (ok bool, err error) {
+	message := map[string]interface{}{
+		"version":       Version,
+		"host":          c.hostname,
+		"short_message": event.Message,
+		"timestamp":     float64(event.Timestamp.UnixNano()/int64(time.Millisecond)) / 1e3,
+		"level":         c.Level,
+	}
+	if message["short_message"] == "" {
+		// short_message is mandatory
+		message["short_message"] = "-"
+	}
+	if host := event.GetString(c.HostField); host != "" {
+		message["host"] = host
+	}
+
+	for key, value := range event.Extra {
+		switch key {
+		case c.HostField, "version", "timestamp":
+			continue
+		case c.LevelField:
+			if level, found := parseLevel(value); found {
+				message["level"] = level
+				continue
+			}
+		case "full_message", "facility", "line", "file":
+			message[key] = value
+			continue
+		}
+		addField(message, "_"+key, value)
+	}
+	if len(event.Tags) > 0 {
+		message["_"+logevent.TagsField] = strings.Join(event.Tags, ",")
+	}
+
+	data, err := jsoniter.Marshal(message)
+	if err != nil {
+		return false, err
+	}
+	dataChan <- data
+	return true, nil
+}
+
+// addField adds value as GELF additional field, nested maps are flattened with underscore
+func addField(message map[string]interface{}, key string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			addField(message, key+"_"+k, child)
+		}
+	case nil, string, bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+		if key == "_id" {
+			key = "__id"
+		}
+		message[key] = v
+	default:
+		message[key] = fmt.Sprintf("%v", v)
+	}
+}
+
+func parseLevel(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		if level, err := strconv.Atoi(v); err == nil {
+			return level, true
+		}
+		level, found := levels[strings.ToLower(v)]
+		return level, found
+	}
+	return 0, false
+}
+
+// Decompress returns data decompressed if data is gzip or zlib compressed
+func Decompress(data []byte) ([]byte, error) {
+	return config.Decompress(data, config.CompressionAuto)
+}
+
+// Compress returns data compressed with compression type, one of ["none", "gzip", "zlib"]
+func Compress(data []byte, compression string, level int) ([]byte, error) {
+	switch compression {
+	case "", CompressionNone, CompressionGzip, CompressionZlib:
+		return config.Compress(data, compression, level)
+	}
+	return nil, ErrorInvalidCompression1.New(nil, compression)
+}
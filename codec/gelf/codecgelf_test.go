@@ -0,0 +1,117 @@
+package codecgelf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+}
+
+const testMessage = `{"version":"1.1","host":"example.org","short_message":"A short message","full_message":"Backtrace here","timestamp":1385053862.307,"level":1,"_user_id":9001,"_some_info":"foo","_id":"x"}`
+
+func TestDecode(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, nil)
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+
+	expected := map[string]interface{}{
+		"version":      "1.1",
+		"host":         "example.org",
+		"full_message": "Backtrace here",
+		"level":        float64(1),
+		"user_id":      float64(9001),
+		"some_info":    "foo",
+	}
+
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionZlib} {
+		data, err := Compress([]byte(testMessage), compression, -1)
+		require.NoError(err)
+		ok, err := codec.Decode(ctx, data, nil, msgChan)
+		require.NoError(err)
+		assert.True(ok)
+		require.Len(msgChan, 1)
+		event := <-msgChan
+		assert.Equal("A short message", event.Message, compression)
+		assert.Equal(time.Date(2013, time.November, 21, 17, 11, 2, 307000000, time.UTC), event.Timestamp)
+		assert.Equal(expected, event.Extra)
+	}
+
+	// malformed data
+	ok, err := codec.Decode(ctx, "114514", nil, msgChan)
+	require.Error(err)
+	assert.True(ok)
+	event := <-msgChan
+	assert.Equal([]string{ErrorTag}, event.Tags)
+	assert.Equal("114514", event.Message)
+}
+
+func TestEncode(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, &config.ConfigRaw{"level": 5})
+	require.NoError(err)
+
+	dataChan := make(chan []byte, 1)
+	ok, err := codec.Encode(ctx, logevent.LogEvent{
+		Timestamp: time.Date(2013, time.November, 21, 17, 11, 2, 307000000, time.UTC),
+		Message:   "hello",
+		Tags:      []string{"a", "b"},
+		Extra: map[string]interface{}{
+			"host":  "web1",
+			"level": "error",
+			"id":    1,
+			"user":  map[string]interface{}{"name": "bob"},
+			"list":  []interface{}{1, 2},
+		},
+	}, dataChan)
+	require.NoError(err)
+	assert.True(ok)
+
+	var message map[string]interface{}
+	require.NoError(jsoniter.Unmarshal(<-dataChan, &message))
+	assert.Equal(map[string]interface{}{
+		"version":       Version,
+		"host":          "web1",
+		"short_message": "hello",
+		"timestamp":     1385053862.307,
+		"level":         float64(3),
+		"__id":          float64(1),
+		"_user_name":    "bob",
+		"_list":         "[1 2]",
+		"_tags":         "a,b",
+	}, message)
+
+	// default level and short_message
+	ok, err = codec.Encode(ctx, logevent.LogEvent{}, dataChan)
+	require.NoError(err)
+	assert.True(ok)
+	message = nil
+	require.NoError(jsoniter.Unmarshal(<-dataChan, &message))
+	assert.Equal("-", message["short_message"])
+	assert.Equal(float64(5), message["level"])
+
+	_, err = Compress(nil, "lz4", -1)
+	require.Error(err)
+}
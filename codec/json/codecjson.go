@@ -49,7 +49,7 @@ func (c *Codec) Decode(ctx context.Context, data interface{},
 
 	switch v := data.(type) {
 	case string:
-		if err = jsoniter.Unmarshal([]byte(v), &event.Extra); err != nil {
+		if err = jsoniter.UnmarshalFromString(v, &event.Extra); err != nil {
 			event.Message = v
 		}
 	case []byte:
@@ -72,32 +72,7 @@ func (c *Codec) Decode(ctx context.Context, data interface{},
 		goglog.Logger.Error(err)
 	}
 
-	if event.Extra != nil {
-		// try to fill basic log event by json message
-		if value, ok := event.Extra["message"]; ok {
-			switch v := value.(type) {
-			case string:
-				event.Message = v
-				delete(event.Extra, "message")
-			}
-		}
-		if value, ok := event.Extra["@timestamp"]; ok {
-			switch v := value.(type) {
-			case string:
-				if timestamp, err2 := time.Parse(time.RFC3339Nano, v); err2 == nil {
-					event.Timestamp = timestamp
-					delete(event.Extra, "@timestamp")
-				}
-			}
-		}
-		if value, ok := event.Extra[logevent.TagsField]; ok {
-			if event.ParseTags(value) {
-				delete(event.Extra, logevent.TagsField)
-			} else {
-				goglog.Logger.Warnf("malformed tags: %v", value)
-			}
-		}
-	}
+	fillEvent(&event)
 
 	msgChan <- event
 	ok = true
@@ -117,32 +92,7 @@ func (c *Codec) DecodeEvent(data []byte, v interface{}) error {
 		goglog.Logger.Error(err)
 	}
 
-	if event.Extra != nil {
-		// try to fill basic log event by json message
-		if value, ok := event.Extra["message"]; ok {
-			switch v := value.(type) {
-			case string:
-				event.Message = v
-				delete(event.Extra, "message")
-			}
-		}
-		if value, ok := event.Extra["@timestamp"]; ok {
-			switch v := value.(type) {
-			case string:
-				if timestamp, err2 := time.Parse(time.RFC3339Nano, v); err2 == nil {
-					event.Timestamp = timestamp
-					delete(event.Extra, "@timestamp")
-				}
-			}
-		}
-		if value, ok := event.Extra[logevent.TagsField]; ok {
-			if event.ParseTags(value) {
-				delete(event.Extra, logevent.TagsField)
-			} else {
-				goglog.Logger.Warnf("malformed tags: %v", value)
-			}
-		}
-	}
+	fillEvent(&event)
 
 	switch e := v.(type) {
 	case *interface{}:
@@ -155,6 +105,36 @@ func (c *Codec) DecodeEvent(data []byte, v interface{}) error {
 	return nil
 }
 
+// fillEvent fills basic log event fields by json message
+func fillEvent(event *logevent.LogEvent) {
+	if event.Extra == nil {
+		return
+	}
+	if value, ok := event.Extra["message"]; ok {
+		switch v := value.(type) {
+		case string:
+			event.Message = v
+			delete(event.Extra, "message")
+		}
+	}
+	if value, ok := event.Extra["@timestamp"]; ok {
+		switch v := value.(type) {
+		case string:
+			if timestamp, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				event.Timestamp = timestamp
+				delete(event.Extra, "@timestamp")
+			}
+		}
+	}
+	if value, ok := event.Extra[logevent.TagsField]; ok {
+		if event.ParseTags(value) {
+			delete(event.Extra, logevent.TagsField)
+		} else {
+			goglog.Logger.Warnf("malformed tags: %v", value)
+		}
+	}
+}
+
 // Encode function not implement (TODO)
 func (c *Codec) Encode(ctx context.Context, event logevent.LogEvent, dataChan chan<- []byte) (ok bool, err error) {
 	return false, config.ErrorNotImplement1.New(nil)
@@ -0,0 +1,281 @@
+package codecleef
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	codeccef "github.com/viethqc/gogstash/codec/cef"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "leef"
+
+// ErrorTag tag added to event when process module failed
+const ErrorTag = "gogstash_codec_leef_error"
+
+// header field names used when ecs_compatibility is disabled
+const (
+	FieldVersion       = "leefVersion"
+	FieldDeviceVendor  = "deviceVendor"
+	FieldDeviceProduct = "deviceProduct"
+	FieldDeviceVersion = "deviceVersion"
+	FieldEventID       = "eventId"
+	FieldSyslog        = "syslog"
+)
+
+var headerFields = []string{
+	FieldVersion,
+	FieldDeviceVendor,
+	FieldDeviceProduct,
+	FieldDeviceVersion,
+	FieldEventID,
+}
+
+// errors
+var (
+	ErrorInvalidHeader1    = errutil.NewFactory("invalid LEEF header: %q")
+	ErrorInvalidDelimiter1 = errutil.NewFactory("invalid LEEF delimiter: %q")
+)
+
+// Codec default struct for codec
+type Codec struct {
+	config.CodecConfig
+
+	// map header and attribute keys to Elastic Common Schema field names, default: false
+	ECSCompatibility bool `json:"ecs_compatibility"`
+}
+
+// DefaultCodecConfig returns an Codec struct with default values
+func DefaultCodecConfig() Codec {
+	return Codec{
+		CodecConfig: config.CodecConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+	}
+}
+
+// InitHandler initialize the codec plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeCodecConfig, error) {
+	conf := DefaultCodecConfig()
+	if raw != nil {
+		if err := config.ReflectConfig(raw, &conf); err != nil {
+			return nil, err
+		}
+	}
+	return &conf, nil
+}
+
+// Decode returns an event from 'data' as LEEF format, adding provided 'eventExtra'
+func (c *Codec) Decode(ctx context.Context, data interface{},
+	eventExtra map[string]interface{},
+	msgChan chan<- logevent.LogEvent) (ok bool, err error) {
+
+	if config.GetMutexInstance().GetPause() == true {
+		return false, errors.New("Pause input")
+	}
+
+	event := logevent.LogEvent{
+		Timestamp: time.Now(),
+		Extra:     eventExtra,
+	}
+
+	switch v := data.(type) {
+	case string:
+		err = c.decode(v, &event)
+	case []byte:
+		err = c.decode(string(v), &event)
+	default:
+		err = config.ErrDecodeData
+	}
+	if err != nil {
+		event.AddTag(ErrorTag)
+		goglog.Logger.Error(err)
+	}
+
+	msgChan <- event
+	ok = true
+
+	return
+}
+
+// DecodeEvent decodes 'data' as LEEF format to event
+func (c *Codec) DecodeEvent(data []byte, v interface{}) error {
+	event := logevent.LogEvent{
+		Timestamp: time.Now(),
+	}
+
+	if err := c.decode(string(data), &event); err != nil {
+		event.AddTag(ErrorTag)
+		goglog.Logger.Error(err)
+	}
+
+	switch e := v.(type) {
+	case *interface{}:
+		*e = event
+	case *logevent.LogEvent:
+		*e = event
+	default:
+		return config.ErrorUnsupportedTargetEvent
+	}
+	return nil
+}
+
+func (c *Codec) decode(data string, event *logevent.LogEvent) (err error) {
+	data = strings.TrimRight(data, "\r\n")
+
+	pos := strings.Index(data, "LEEF:")
+	if pos < 0 {
+		event.Message = data
+		return ErrorInvalidHeader1.New(nil, data)
+	}
+
+	headers, attributes := codeccef.SplitHeader(data[pos+len("LEEF:"):], len(headerFields))
+	if len(headers) < len(headerFields) {
+		event.Message = data
+		return ErrorInvalidHeader1.New(nil, data)
+	}
+
+	delimiter := "\t"
+	if strings.HasPrefix(headers[0], "2") {
+		// LEEF 2.0 has an additional header for the attribute delimiter
+		var delimiters []string
+		delimiters, attributes = codeccef.SplitHeader(attributes, 1)
+		if len(delimiters) < 1 {
+			event.Message = data
+			return ErrorInvalidHeader1.New(nil, data)
+		}
+		if delimiter, err = parseDelimiter(delimiters[0]); err != nil {
+			event.Message = data
+			return err
+		}
+	}
+
+	if pos > 0 {
+		c.setValue(event, FieldSyslog, strings.TrimSpace(data[:pos]))
+	}
+	for i, field := range headerFields {
+		c.setValue(event, field, headers[i])
+	}
+
+	var devTimeFormat string
+	pairs := parseAttributes(attributes, delimiter)
+	for _, pair := range pairs {
+		if pair.Key == "devTimeFormat" {
+			devTimeFormat = pair.Value
+		}
+	}
+	for _, pair := range pairs {
+		switch pair.Key {
+		case "msg", "message":
+			event.Message = pair.Value
+			continue
+		case "devTime":
+			if ts, err2 := parseDevTime(pair.Value, devTimeFormat); err2 == nil {
+				event.Timestamp = ts
+				if c.ECSCompatibility {
+					continue
+				}
+			}
+		}
+		c.setValue(event, pair.Key, pair.Value)
+	}
+
+	return nil
+}
+
+func (c *Codec) setValue(event *logevent.LogEvent, key string, value string) {
+	if !c.ECSCompatibility {
+		event.SetValue(key, value)
+		return
+	}
+	field, ok := ecsMapping[key]
+	if !ok {
+		field = "leef.extensions." + key
+	}
+	event.SetValue(field, ecsValue(field, value))
+}
+
+// Encode function not implement (TODO)
+func (c *Codec) Encode(ctx context.Context, event logevent.LogEvent, dataChan chan<- []byte) (ok bool, err error) {
+	return false, config.ErrorNotImplement1.New(nil)
+}
+
+// parseDelimiter parses LEEF 2.0 delimiter header, a single character
+// or its hex value, e.g. "^", "x5E" or "0x5E"
+func parseDelimiter(s string) (string, error) {
+	switch {
+	case s == "":
+		return "\t", nil
+	case len(s) == 1:
+		return s, nil
+	}
+	hex := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "0"), "x")
+	code, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return "", ErrorInvalidDelimiter1.New(err, s)
+	}
+	return string(rune(code)), nil
+}
+
+// parseAttributes splits key=value pairs separated by delimiter
+func parseAttributes(attributes string, delimiter string) (pairs []codeccef.Pair) {
+	for _, attr := range strings.Split(attributes, delimiter) {
+		if attr == "" {
+			continue
+		}
+		pos := indexUnescaped(attr, '=')
+		if pos <= 0 {
+			continue
+		}
+		pairs = append(pairs, codeccef.Pair{
+			Key:   strings.TrimSpace(attr[:pos]),
+			Value: codeccef.UnescapeValue(attr[pos+1:]),
+		})
+	}
+	return
+}
+
+func indexUnescaped(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+	return -1
+}
+
+// java date format patterns commonly used in devTimeFormat
+var devTimeFormatReplacer = strings.NewReplacer(
+	"yyyy", "2006",
+	"MMM", "Jan",
+	"MM", "01",
+	"dd", "02",
+	"HH", "15",
+	"mm", "04",
+	"ss", "05",
+	"SSS", "000",
+	"zzz", "MST",
+	"z", "MST",
+	"Z", "-0700",
+)
+
+func parseDevTime(value string, format string) (time.Time, error) {
+	if format != "" {
+		if ts, err := time.Parse(devTimeFormatReplacer.Replace(format), value); err == nil {
+			return ts, nil
+		}
+	}
+	return codeccef.ParseTime(value)
+}
@@ -0,0 +1,94 @@
+package codecleef
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+}
+
+func TestDecode(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, nil)
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 1)
+
+	// LEEF 1.0 uses tab as delimiter
+	ok, err := codec.Decode(ctx, []byte("LEEF:1.0|Microsoft|MSExchange|4.0 SP1|15345|src=192.0.2.0\tdst=172.50.123.1\tusrName=joe user\tmsg=a \\= b"), nil, msgChan)
+	require.NoError(err)
+	assert.True(ok)
+	require.Len(msgChan, 1)
+	event := <-msgChan
+	assert.Equal("a = b", event.Message)
+	assert.Equal(map[string]interface{}{
+		"leefVersion":   "1.0",
+		"deviceVendor":  "Microsoft",
+		"deviceProduct": "MSExchange",
+		"deviceVersion": "4.0 SP1",
+		"eventId":       "15345",
+		"src":           "192.0.2.0",
+		"dst":           "172.50.123.1",
+		"usrName":       "joe user",
+	}, event.Extra)
+
+	// LEEF 2.0 with hex delimiter and devTime
+	ok, err = codec.Decode(ctx, "<13>Jan 18 11:07:53 host LEEF:2.0|Lancope|StealthWatch|1.0|41|0x5E|src=10.0.1.8^devTimeFormat=yyyy-MM-dd HH:mm:ss^devTime=2019-01-18 11:07:53\n", nil, msgChan)
+	require.NoError(err)
+	assert.True(ok)
+	event = <-msgChan
+	assert.Equal("<13>Jan 18 11:07:53 host", event.Extra["syslog"])
+	assert.Equal("10.0.1.8", event.Extra["src"])
+	assert.Equal(time.Date(2019, time.January, 18, 11, 7, 53, 0, time.UTC), event.Timestamp)
+
+	// malformed data
+	ok, err = codec.Decode(ctx, []byte(`LEEF:1.0|missing`), nil, msgChan)
+	require.Error(err) // fail to decode
+	assert.True(ok)
+	event = <-msgChan
+	assert.Equal([]string{ErrorTag}, event.Tags)
+	assert.Equal("LEEF:1.0|missing", event.Message)
+}
+
+func TestDecodeECS(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, &config.ConfigRaw{"type": ModuleName, "ecs_compatibility": true})
+	require.NoError(err)
+
+	event := logevent.LogEvent{}
+	require.NoError(codec.DecodeEvent([]byte("LEEF:2.0|Vendor|Product|1|42|^|srcPort=80^usrName=bob^foo=bar"), &event))
+	assert.Equal(map[string]interface{}{
+		"leef": map[string]interface{}{
+			"version":    "2.0",
+			"extensions": map[string]interface{}{"foo": "bar"},
+		},
+		"observer": map[string]interface{}{
+			"vendor":  "Vendor",
+			"product": "Product",
+			"version": "1",
+		},
+		"event":  map[string]interface{}{"code": "42"},
+		"source": map[string]interface{}{"port": int64(80)},
+		"user":   map[string]interface{}{"name": "bob"},
+	}, event.Extra)
+}
@@ -0,0 +1,58 @@
+package codecleef
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ecsMapping maps LEEF header names and predefined attribute keys
+// to Elastic Common Schema fields
+var ecsMapping = map[string]string{
+	FieldVersion:       "leef.version",
+	FieldDeviceVendor:  "observer.vendor",
+	FieldDeviceProduct: "observer.product",
+	FieldDeviceVersion: "observer.version",
+	FieldEventID:       "event.code",
+	FieldSyslog:        "log.syslog.header",
+
+	"action":         "event.action",
+	"cat":            "leef.category",
+	"devTime":        "event.created",
+	"devTimeFormat":  "leef.devTimeFormat",
+	"domain":         "user.domain",
+	"dst":            "destination.ip",
+	"dstBytes":       "destination.bytes",
+	"dstMAC":         "destination.mac",
+	"dstPackets":     "destination.packets",
+	"dstPort":        "destination.port",
+	"dstPostNAT":     "destination.nat.ip",
+	"dstPostNATPort": "destination.nat.port",
+	"identHostName":  "host.name",
+	"policy":         "rule.name",
+	"proto":          "network.transport",
+	"sev":            "event.severity",
+	"src":            "source.ip",
+	"srcBytes":       "source.bytes",
+	"srcMAC":         "source.mac",
+	"srcPackets":     "source.packets",
+	"srcPort":        "source.port",
+	"srcPostNAT":     "source.nat.ip",
+	"srcPostNATPort": "source.nat.port",
+	"totalPackets":   "network.packets",
+	"url":            "url.original",
+	"usrName":        "user.name",
+}
+
+// ecsValue converts numeric ECS fields, other values are kept as string
+func ecsValue(field string, value string) interface{} {
+	switch {
+	case strings.HasSuffix(field, ".port"),
+		strings.HasSuffix(field, ".bytes"),
+		strings.HasSuffix(field, ".packets"),
+		field == "event.severity":
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	}
+	return value
+}
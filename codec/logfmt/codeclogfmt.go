@@ -0,0 +1,236 @@
+package codeclogfmt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "logfmt"
+
+// ErrorTag tag added to event when process module failed
+const ErrorTag = "gogstash_codec_logfmt_error"
+
+// Codec default struct for codec
+type Codec struct {
+	config.CodecConfig
+
+	// key stored as event message, default: "msg"
+	MessageKey string `json:"message_key"`
+	// key parsed as event timestamp in RFC3339 format, default: "time"
+	TimestampKey string `json:"timestamp_key"`
+	// store keys into this field instead of event root
+	Target string `json:"target"`
+	// event fields written by Encode, default: all event fields
+	Fields []string `json:"fields"`
+}
+
+// DefaultCodecConfig returns an Codec struct with default values
+func DefaultCodecConfig() Codec {
+	return Codec{
+		CodecConfig: config.CodecConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		MessageKey:   "msg",
+		TimestampKey: "time",
+	}
+}
+
+// InitHandler initialize the codec plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeCodecConfig, error) {
+	conf := DefaultCodecConfig()
+	if raw != nil {
+		if err := config.ReflectConfig(raw, &conf); err != nil {
+			return nil, err
+		}
+	}
+	return &conf, nil
+}
+
+// Decode returns events from 'data' as logfmt format, one event per line, adding provided 'eventExtra'
+func (c *Codec) Decode(ctx context.Context, data interface{},
+	eventExtra map[string]interface{},
+	msgChan chan<- logevent.LogEvent) (ok bool, err error) {
+
+	if config.GetMutexInstance().GetPause() == true {
+		return false, errors.New("Pause input")
+	}
+
+	var raw []byte
+	switch v := data.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		err = config.ErrDecodeData
+	}
+
+	var events []logevent.LogEvent
+	if err == nil {
+		events, err = c.decode(raw, eventExtra)
+	}
+	if err != nil {
+		event := logevent.LogEvent{
+			Timestamp: time.Now(),
+			Message:   string(raw),
+			Extra:     eventExtra,
+		}
+		event.AddTag(ErrorTag)
+		goglog.Logger.Error(err)
+		msgChan <- event
+		return true, err
+	}
+
+	for _, event := range events {
+		msgChan <- event
+		ok = true
+	}
+
+	return
+}
+
+// DecodeEvent decodes the first line of 'data' as logfmt format to event
+func (c *Codec) DecodeEvent(data []byte, v interface{}) error {
+	event := logevent.LogEvent{
+		Timestamp: time.Now(),
+	}
+
+	events, err := c.decode(data, nil)
+	if err == nil && len(events) > 0 {
+		event = events[0]
+	} else {
+		event.Message = string(data)
+		event.AddTag(ErrorTag)
+		if err != nil {
+			goglog.Logger.Error(err)
+		}
+	}
+
+	switch e := v.(type) {
+	case *interface{}:
+		*e = event
+	case *logevent.LogEvent:
+		*e = event
+	default:
+		return config.ErrorUnsupportedTargetEvent
+	}
+	return nil
+}
+
+func (c *Codec) decode(data []byte, eventExtra map[string]interface{}) (events []logevent.LogEvent, err error) {
+	dec := logfmt.NewDecoder(bytes.NewReader(data))
+	for dec.ScanRecord() {
+		extra := make(map[string]interface{}, len(eventExtra))
+		for k, v := range eventExtra {
+			extra[k] = v
+		}
+		event := logevent.LogEvent{
+			Timestamp: time.Now(),
+			Extra:     extra,
+		}
+		empty := true
+		for dec.ScanKeyval() {
+			empty = false
+			key := string(dec.Key())
+			value := dec.Value()
+			switch {
+			case key == c.MessageKey:
+				event.Message = string(value)
+				continue
+			case key == c.TimestampKey:
+				if ts, err2 := time.Parse(time.RFC3339Nano, string(value)); err2 == nil {
+					event.Timestamp = ts
+					continue
+				}
+			}
+			if c.Target != "" {
+				key = c.Target + "." + key
+			}
+			if value == nil {
+				// key without value is a flag
+				event.SetValue(key, true)
+			} else {
+				event.SetValue(key, string(value))
+			}
+		}
+		if err = dec.Err(); err != nil {
+			return nil, err
+		}
+		if !empty {
+			events = append(events, event)
+		}
+	}
+	return events, dec.Err()
+}
+
+// Encode serializes the event into a logfmt line
+func (c *Codec) Encode(ctx context.Context, event logevent.LogEvent, dataChan chan<- []byte) (ok bool, err error) {
+	buf := &bytes.Buffer{}
+	enc := logfmt.NewEncoder(buf)
+
+	if err = enc.EncodeKeyval(c.TimestampKey, event.Timestamp.UTC().Format(time.RFC3339Nano)); err != nil {
+		return false, err
+	}
+	if event.Message != "" {
+		if err = enc.EncodeKeyval(c.MessageKey, event.Message); err != nil {
+			return false, err
+		}
+	}
+
+	fields := c.Fields
+	if len(fields) < 1 {
+		fields = flattenKeys("", event.Extra)
+	}
+	for _, field := range fields {
+		value, found := event.GetValue(field)
+		if !found {
+			continue
+		}
+		switch value.(type) {
+		case nil, string, bool, int, int64, float64, error, fmt.Stringer:
+		default:
+			value = fmt.Sprintf("%v", value)
+		}
+		if err = enc.EncodeKeyval(field, value); err != nil {
+			return false, err
+		}
+	}
+	if len(event.Tags) > 0 {
+		if err = enc.EncodeKeyval(logevent.TagsField, strings.Join(event.Tags, ",")); err != nil {
+			return false, err
+		}
+	}
+	if err = enc.EndRecord(); err != nil {
+		return false, err
+	}
+
+	dataChan <- bytes.TrimRight(buf.Bytes(), "\n")
+	return true, nil
+}
+
+// flattenKeys returns sorted dotted paths of all leaf values
+func flattenKeys(prefix string, obj map[string]interface{}) (keys []string) {
+	for k, v := range obj {
+		key := prefix + k
+		if child, ok := v.(map[string]interface{}); ok {
+			keys = append(keys, flattenKeys(key+".", child)...)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
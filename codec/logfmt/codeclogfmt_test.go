@@ -0,0 +1,107 @@
+package codeclogfmt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+}
+
+func TestDecode(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, nil)
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+
+	ts := time.Date(2019, time.January, 4, 0, 55, 36, 0, time.UTC)
+	ok, err := codec.Decode(ctx, []byte(`time=`+ts.Format(time.RFC3339)+` level=info msg="request done" path=/api dur=1.2ms debug`), map[string]interface{}{"host": "h"}, msgChan)
+	require.NoError(err)
+	assert.True(ok)
+	require.Len(msgChan, 1)
+	event := <-msgChan
+	assert.Equal("request done", event.Message)
+	assert.Equal(ts, event.Timestamp)
+	assert.Equal(map[string]interface{}{
+		"host":  "h",
+		"level": "info",
+		"path":  "/api",
+		"dur":   "1.2ms",
+		"debug": true,
+	}, event.Extra)
+
+	// one event per line
+	ok, err = codec.Decode(ctx, "a=1\n\nb=2\n", nil, msgChan)
+	require.NoError(err)
+	assert.True(ok)
+	require.Len(msgChan, 2)
+	assert.Equal("1", (<-msgChan).Extra["a"])
+	assert.Equal("2", (<-msgChan).Extra["b"])
+
+	// malformed data
+	ok, err = codec.Decode(ctx, `a="unterminated`, nil, msgChan)
+	require.Error(err)
+	assert.True(ok)
+	event = <-msgChan
+	assert.Equal([]string{ErrorTag}, event.Tags)
+	assert.Equal(`a="unterminated`, event.Message)
+}
+
+func TestDecodeEventTarget(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, &config.ConfigRaw{"target": "app", "message_key": "message"})
+	require.NoError(err)
+
+	event := logevent.LogEvent{}
+	require.NoError(codec.DecodeEvent([]byte(`message=hello user=bob`), &event))
+	assert.Equal("hello", event.Message)
+	assert.Equal(map[string]interface{}{
+		"app": map[string]interface{}{"user": "bob"},
+	}, event.Extra)
+}
+
+func TestEncode(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := InitHandler(ctx, nil)
+	require.NoError(err)
+
+	dataChan := make(chan []byte, 1)
+	ok, err := codec.Encode(ctx, logevent.LogEvent{
+		Timestamp: time.Date(2019, time.January, 4, 0, 55, 36, 0, time.UTC),
+		Message:   "hello world",
+		Tags:      []string{"a", "b"},
+		Extra: map[string]interface{}{
+			"count": 3,
+			"user":  map[string]interface{}{"name": "bob"},
+			"list":  []interface{}{1, 2},
+		},
+	}, dataChan)
+	require.NoError(err)
+	assert.True(ok)
+	assert.Equal(`time=2019-01-04T00:55:36Z msg="hello world" count=3 list="[1 2]" user.name=bob tags=a,b`, string(<-dataChan))
+}
@@ -31,6 +31,13 @@ type TypeCodecConfig interface {
 	Encode(ctx context.Context, event logevent.LogEvent, dataChan chan<- []byte) (ok bool, err error)
 }
 
+// TypeHeaderCodecConfig is interface of codec module encoding a header before events,
+// outputs write it at the beginning of each file they create, e.g. column names of csv
+type TypeHeaderCodecConfig interface {
+	// EncodeHeader returns the header data, nil if the codec writes no header
+	EncodeHeader() (data []byte, err error)
+}
+
 // CodecConfig is basic codec config struct
 type CodecConfig struct {
 	CommonConfig
@@ -69,6 +76,9 @@ func GetCodecDefault(ctx context.Context, raw ConfigRaw, defaultType string) (Ty
 	switch codecConfig.(type) {
 	case map[string]interface{}:
 		return getCodec(ctx, ConfigRaw(codecConfig.(map[string]interface{})))
+	case map[interface{}]interface{}:
+		// nested config map decoded from YAML
+		return getCodec(ctx, ConfigRaw(dyno.ConvertMapI2MapS(codecConfig).(map[string]interface{})))
 	case string:
 		// shorthand codec config method:
 		// codec: [codecTypeName]
@@ -88,9 +98,39 @@ func getCodec(ctx context.Context, raw ConfigRaw) (codec TypeCodecConfig, err er
 		return nil, ErrorInitCodecFailed1.New(err, raw)
 	}
 
+	if codec, err = wrapCompression(codec, raw); err != nil {
+		return nil, ErrorInitCodecFailed1.New(err, raw)
+	}
+
 	return codec, nil
 }
 
+// DecodeFunc decodes data by codec and calls fn with each event in order,
+// returns after all events are handled
+func DecodeFunc(ctx context.Context, codec TypeCodecConfig, data interface{}, extra map[string]interface{}, fn func(event logevent.LogEvent)) (ok bool, err error) {
+	relay := make(chan logevent.LogEvent)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range relay {
+			fn(event)
+		}
+	}()
+
+	ok, err = codec.Decode(ctx, data, extra, relay)
+	close(relay)
+	<-done
+	return
+}
+
+// DecodeEvents returns events decoded from data by codec
+func DecodeEvents(ctx context.Context, codec TypeCodecConfig, data interface{}, extra map[string]interface{}) (events []logevent.LogEvent, err error) {
+	_, err = DecodeFunc(ctx, codec, data, extra, func(event logevent.LogEvent) {
+		events = append(events, event)
+	})
+	return
+}
+
 // DefaultCodecName default codec name
 const DefaultCodecName = "default"
 
@@ -33,6 +33,11 @@ func TestGetCodec(t *testing.T) {
 	require.NoError(err)
 	assert.NotNil(codec)
 
+	// codec config map decoded from YAML, should be ok
+	codec, err = GetCodec(ctx, ConfigRaw{"codec": map[interface{}]interface{}{"type": DefaultCodecName}})
+	require.NoError(err)
+	assert.NotNil(codec)
+
 	// undefined codec, should not exists
 	codec, err = GetCodec(ctx, ConfigRaw{"codec": map[string]interface{}{"type": "undefined"}})
 	require.Error(err)
@@ -74,3 +79,29 @@ func TestDefaultCodecDecode(t *testing.T) {
 	event = <-msgChan
 	assert.Equal("", event.Message)
 }
+
+func TestDecodeEvents(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := DefaultCodecInitHandler(ctx, nil)
+	require.NoError(err)
+
+	events, err := DecodeEvents(ctx, codec, []byte("foobar"), map[string]interface{}{"host": "a"})
+	require.NoError(err)
+	require.Len(events, 1)
+	assert.Equal("foobar", events[0].Message)
+	assert.Equal("a", events[0].Extra["host"])
+
+	// events are handled before DecodeFunc returns
+	handled := 0
+	ok, err := DecodeFunc(ctx, codec, 114514, nil, func(event logevent.LogEvent) {
+		handled++
+	})
+	require.Error(err)
+	assert.True(ok)
+	assert.Equal(1, handled)
+}
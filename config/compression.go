@@ -0,0 +1,291 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// supported compression types of codec config 'compression'
+const (
+	CompressionAuto   = "auto"
+	CompressionNone   = "none"
+	CompressionGzip   = "gzip"
+	CompressionZlib   = "zlib"
+	CompressionSnappy = "snappy"
+	CompressionZstd   = "zstd"
+)
+
+// CompressionErrorTag tag added to event when decompress data failed
+const CompressionErrorTag = "gogstash_codec_compression_error"
+
+// errors
+var (
+	ErrorUnknownCompression1 = errutil.NewFactory("unknown compression type: %q")
+)
+
+// magic bytes of compressed data
+var (
+	magicGzip   = []byte{0x1f, 0x8b}
+	magicZstd   = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	magicSnappy = []byte("\xff\x06\x00\x00sNaPpY")
+)
+
+// DetectCompression returns compression type of data by magic bytes, CompressionNone if not compressed
+func DetectCompression(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, magicGzip):
+		return CompressionGzip
+	case bytes.HasPrefix(data, magicZstd):
+		return CompressionZstd
+	case bytes.HasPrefix(data, magicSnappy):
+		return CompressionSnappy
+	case len(data) > 1 && data[0]&0x0f == 0x08 && data[1]&0x20 == 0 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0:
+		// deflate without preset dictionary
+		return CompressionZlib
+	}
+	return CompressionNone
+}
+
+func validCompression(compression string) bool {
+	switch compression {
+	case "", CompressionAuto, CompressionNone, CompressionGzip, CompressionZlib, CompressionSnappy, CompressionZstd:
+		return true
+	}
+	return false
+}
+
+// NewDecompressReader returns a reader decompressing r, compression type is detected by magic bytes if 'auto'
+func NewDecompressReader(r io.Reader, compression string) (io.ReadCloser, error) {
+	if compression == CompressionAuto {
+		br := bufio.NewReader(r)
+		magic, _ := br.Peek(len(magicSnappy))
+		compression = DetectCompression(magic)
+		r = br
+	}
+
+	switch compression {
+	case "", CompressionNone:
+		return ioutil.NopCloser(r), nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZlib:
+		return zlib.NewReader(r)
+	case CompressionSnappy:
+		return ioutil.NopCloser(snappy.NewReader(r)), nil
+	case CompressionZstd:
+		dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	}
+	return nil, ErrorUnknownCompression1.New(nil, compression)
+}
+
+// Decompress returns data decompressed, compression type is detected by magic bytes if 'auto'
+func Decompress(data []byte, compression string) ([]byte, error) {
+	if compression == CompressionAuto {
+		if compression = DetectCompression(data); compression == CompressionZlib {
+			// zlib header is only 2 bytes, plain text may look like it
+			if payload, err := Decompress(data, compression); err == nil {
+				return payload, nil
+			}
+			return data, nil
+		}
+	}
+	if compression == CompressionSnappy && !bytes.HasPrefix(data, magicSnappy) {
+		// snappy block format without stream header
+		return snappy.Decode(nil, data)
+	}
+
+	r, err := NewDecompressReader(bytes.NewReader(data), compression)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// Compress returns data compressed, level 0 means default level of compression type
+func Compress(data []byte, compression string, level int) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var w io.WriteCloser
+	var err error
+
+	switch compression {
+	case "", CompressionAuto, CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		w, err = gzip.NewWriterLevel(buf, level)
+	case CompressionZlib:
+		if level == 0 {
+			level = zlib.DefaultCompression
+		}
+		w, err = zlib.NewWriterLevel(buf, level)
+	case CompressionSnappy:
+		w = snappy.NewBufferedWriter(buf)
+	case CompressionZstd:
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		w, err = zstd.NewWriter(buf, opts...)
+	default:
+		return nil, ErrorUnknownCompression1.New(nil, compression)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressionCodec wraps a codec, decompress data before decoding and compress data after encoding
+type compressionCodec struct {
+	TypeCodecConfig
+	compression string
+	level       int
+}
+
+// wrapCompression returns codec wrapped with compression layer of 'compression' and 'compression_level' config
+func wrapCompression(codec TypeCodecConfig, raw ConfigRaw) (TypeCodecConfig, error) {
+	conf := struct {
+		Compression      string `json:"compression"`
+		CompressionLevel int    `json:"compression_level"`
+	}{}
+	if err := ReflectConfig(&raw, &conf); err != nil {
+		return nil, err
+	}
+	if !validCompression(conf.Compression) {
+		return nil, ErrorUnknownCompression1.New(nil, conf.Compression)
+	}
+	if conf.Compression == "" || conf.Compression == CompressionNone {
+		return codec, nil
+	}
+	return &compressionCodec{
+		TypeCodecConfig: codec,
+		compression:     conf.Compression,
+		level:           conf.CompressionLevel,
+	}, nil
+}
+
+// Decode decompresses 'data' then decode it with the wrapped codec
+func (c *compressionCodec) Decode(ctx context.Context, data interface{},
+	eventExtra map[string]interface{},
+	msgChan chan<- logevent.LogEvent) (ok bool, err error) {
+
+	if GetMutexInstance().GetPause() == true {
+		return false, errors.New("Pause input")
+	}
+
+	var raw []byte
+	switch v := data.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		// not a payload, e.g. decoded map
+		return c.TypeCodecConfig.Decode(ctx, data, eventExtra, msgChan)
+	}
+
+	payload, err := Decompress(raw, c.compression)
+	if err != nil {
+		event := logevent.LogEvent{
+			Timestamp: time.Now(),
+			Message:   string(raw),
+			Extra:     eventExtra,
+		}
+		event.AddTag(CompressionErrorTag)
+		goglog.Logger.Error(err)
+		msgChan <- event
+		return true, err
+	}
+
+	return c.TypeCodecConfig.Decode(ctx, payload, eventExtra, msgChan)
+}
+
+// DecodeEvent decompresses 'data' then decode it to event with the wrapped codec
+func (c *compressionCodec) DecodeEvent(data []byte, v interface{}) error {
+	payload, err := Decompress(data, c.compression)
+	if err != nil {
+		goglog.Logger.Error(err)
+		event := logevent.LogEvent{
+			Timestamp: time.Now(),
+			Message:   string(data),
+		}
+		event.AddTag(CompressionErrorTag)
+		switch e := v.(type) {
+		case *interface{}:
+			*e = event
+		case *logevent.LogEvent:
+			*e = event
+		default:
+			return ErrorUnsupportedTargetEvent
+		}
+		return nil
+	}
+	return c.TypeCodecConfig.DecodeEvent(payload, v)
+}
+
+// EncodeHeader compresses the header of the wrapped codec, nil if it writes no header
+func (c *compressionCodec) EncodeHeader() (data []byte, err error) {
+	headerCodec, ok := c.TypeCodecConfig.(TypeHeaderCodecConfig)
+	if !ok {
+		return nil, nil
+	}
+	if data, err = headerCodec.EncodeHeader(); err != nil || data == nil {
+		return
+	}
+	return Compress(data, c.compression, c.level)
+}
+
+// Encode encodes event with the wrapped codec then compress the data, 'auto' leaves data uncompressed
+func (c *compressionCodec) Encode(ctx context.Context, event logevent.LogEvent, dataChan chan<- []byte) (ok bool, err error) {
+	var (
+		encodedChan = make(chan []byte, 10)
+		encodeOK    bool
+		encodeErr   error
+	)
+	go func() {
+		defer close(encodedChan)
+		encodeOK, encodeErr = c.TypeCodecConfig.Encode(ctx, event, encodedChan)
+	}()
+
+	for data := range encodedChan {
+		if err != nil {
+			// drain the wrapped codec
+			continue
+		}
+		var compressed []byte
+		if compressed, err = Compress(data, c.compression, c.level); err == nil {
+			dataChan <- compressed
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	return encodeOK, encodeErr
+}
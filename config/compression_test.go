@@ -0,0 +1,143 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func TestCompression(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	data := []byte("hello compressed world")
+	for _, compression := range []string{CompressionGzip, CompressionZlib, CompressionSnappy, CompressionZstd} {
+		compressed, err := Compress(data, compression, 0)
+		require.NoError(err)
+		assert.Equal(compression, DetectCompression(compressed))
+
+		decompressed, err := Decompress(compressed, CompressionAuto)
+		require.NoError(err)
+		assert.Equal(data, decompressed, compression)
+
+		decompressed, err = Decompress(compressed, compression)
+		require.NoError(err)
+		assert.Equal(data, decompressed, compression)
+	}
+
+	// plain data is returned as is in auto mode, even looks like zlib header
+	for _, plain := range []string{"hello", "x^2 + y^2", ""} {
+		decompressed, err := Decompress([]byte(plain), CompressionAuto)
+		require.NoError(err)
+		assert.Equal(plain, string(decompressed))
+	}
+
+	_, err := Compress(data, "lz4", 0)
+	require.Error(err)
+	_, err = Decompress(data, CompressionGzip)
+	require.Error(err)
+}
+
+func TestGetCodecCompression(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	codec, err := GetCodec(ctx, ConfigRaw{"codec": map[string]interface{}{"type": DefaultCodecName, "compression": "auto"}})
+	require.NoError(err)
+	assert.Equal(DefaultCodecName, codec.GetType())
+
+	msgChan := make(chan logevent.LogEvent, 10)
+
+	compressed, err := Compress([]byte("foobar"), CompressionZstd, 0)
+	require.NoError(err)
+	ok, err := codec.Decode(ctx, compressed, nil, msgChan)
+	require.NoError(err)
+	assert.True(ok)
+	assert.Equal("foobar", (<-msgChan).Message)
+
+	ok, err = codec.Decode(ctx, "plain", nil, msgChan)
+	require.NoError(err)
+	assert.True(ok)
+	assert.Equal("plain", (<-msgChan).Message)
+
+	event := logevent.LogEvent{}
+	require.NoError(codec.DecodeEvent(compressed, &event))
+	assert.Equal("foobar", event.Message)
+
+	// explicit compression type, malformed data
+	codec, err = GetCodec(ctx, ConfigRaw{"codec": map[string]interface{}{"type": DefaultCodecName, "compression": "gzip"}})
+	require.NoError(err)
+	ok, err = codec.Decode(ctx, "plain", nil, msgChan)
+	require.Error(err)
+	assert.True(ok)
+	event = <-msgChan
+	assert.Equal("plain", event.Message)
+	assert.Equal([]string{CompressionErrorTag}, event.Tags)
+
+	// unknown compression type
+	_, err = GetCodec(ctx, ConfigRaw{"codec": map[string]interface{}{"type": DefaultCodecName, "compression": "lz4"}})
+	require.Error(err)
+}
+
+type testEncodeCodec struct {
+	DefaultCodec
+}
+
+func (c *testEncodeCodec) Encode(ctx context.Context, event logevent.LogEvent, dataChan chan<- []byte) (ok bool, err error) {
+	dataChan <- []byte("header")
+	dataChan <- []byte(event.Message)
+	return true, nil
+}
+
+func (c *testEncodeCodec) EncodeHeader() (data []byte, err error) {
+	return []byte("file header"), nil
+}
+
+func TestCompressionEncode(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	RegistCodecHandler("test_encode", func(context.Context, *ConfigRaw) (TypeCodecConfig, error) {
+		return &testEncodeCodec{}, nil
+	})
+	codec, err := GetCodec(ctx, ConfigRaw{"codec": map[string]interface{}{"type": "test_encode", "compression": "gzip", "compression_level": 9}})
+	require.NoError(err)
+
+	dataChan := make(chan []byte, 10)
+	ok, err := codec.Encode(ctx, logevent.LogEvent{Message: "foobar"}, dataChan)
+	require.NoError(err)
+	assert.True(ok)
+	require.Len(dataChan, 2)
+	for _, expected := range []string{"header", "foobar"} {
+		data := <-dataChan
+		assert.Equal(CompressionGzip, DetectCompression(data))
+		data, err = Decompress(data, CompressionGzip)
+		require.NoError(err)
+		assert.Equal(expected, string(data))
+	}
+
+	// header of the wrapped codec is compressed
+	header, err := codec.(TypeHeaderCodecConfig).EncodeHeader()
+	require.NoError(err)
+	header, err = Decompress(header, CompressionGzip)
+	require.NoError(err)
+	assert.Equal("file header", string(header))
+
+	// codec without header
+	codec, err = GetCodec(ctx, ConfigRaw{"codec": map[string]interface{}{"type": DefaultCodecName, "compression": "gzip"}})
+	require.NoError(err)
+	header, err = codec.(TypeHeaderCodecConfig).EncodeHeader()
+	require.NoError(err)
+	assert.Nil(header)
+}
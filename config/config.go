@@ -47,6 +47,10 @@ type Config struct {
 	chOutDebug  MsgChan // channel from output to debug
 	ctx         context.Context
 	eg          *errgroup.Group
+
+	// closed at end of stream, when all inputs returned and then when filters drained chInFilter
+	inputsDone  chan struct{}
+	filtersDone chan struct{}
 }
 
 var defaultConfig = Config{
@@ -122,6 +126,7 @@ func initConfig(config *Config) {
 func (t *Config) Start(ctx context.Context) (err error) {
 	ctx = contextWithOSSignal(ctx, goglog.Logger, os.Interrupt, os.Kill)
 	t.eg, t.ctx = errgroup.WithContext(ctx)
+	t.filtersDone = make(chan struct{})
 
 	if err = t.startOutputs(); err != nil {
 		return
@@ -100,6 +100,11 @@ func (t *Config) startFilters() (err error) {
 				if len(t.chInFilter) < 1 {
 					return nil
 				}
+			case <-t.inputsDone:
+				if len(t.chInFilter) < 1 {
+					close(t.filtersDone)
+					return nil
+				}
 			case event := <-t.chInFilter:
 				for _, filter := range filters {
 					event = filter.CommonFilter(t.ctx, event)
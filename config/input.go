@@ -2,6 +2,8 @@ package config
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/viethqc/gogstash/KDGoLib/errutil"
 	"github.com/viethqc/gogstash/config/logevent"
@@ -54,19 +56,60 @@ func (t *Config) getInputs() (inputs []TypeInputConfig, err error) {
 	return
 }
 
+// startInputs starts inputs, an input returns from Start at the end of its stream, e.g. stdin,
+// the pipeline is drained and stopped when all inputs returned
 func (t *Config) startInputs() (err error) {
 	inputs, err := t.getInputs()
 	if err != nil {
 		return
 	}
+	if len(inputs) < 1 {
+		return
+	}
 
+	t.inputsDone = make(chan struct{})
+	wg := &sync.WaitGroup{}
 	for _, input := range inputs {
+		wg.Add(1)
 		func(input TypeInputConfig) {
 			t.eg.Go(func() error {
+				defer wg.Done()
 				return input.Start(t.ctx, t.chInFilter)
 			})
 		}(input)
 	}
+	go func() {
+		wg.Wait()
+		close(t.inputsDone)
+	}()
 
 	return
 }
+
+// SendEvents sends events of a request to msgChan, returns false without sending any
+// if the queue stays full for wait. Once the first event is queued, the rest are sent
+// as the queue drains, so requests larger than the queue capacity are still accepted.
+func SendEvents(ctx context.Context, msgChan chan<- logevent.LogEvent, events []logevent.LogEvent, wait time.Duration) bool {
+	if len(events) < 1 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case msgChan <- events[0]:
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+
+	for _, event := range events[1:] {
+		select {
+		case msgChan <- event:
+		case <-ctx.Done():
+			return true
+		}
+	}
+	return true
+}
@@ -0,0 +1,156 @@
+package logevent
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maximum number of cached format templates, formats are usually static strings from config
+const maxFormatCache = 4096
+
+type segmentKind int
+
+const (
+	segmentLiteral     segmentKind = iota
+	segmentEventTime               // %{+@2006-01-02}
+	segmentCurrentTime             // %{+2006-01-02}
+	segmentField                   // %{field}, fallback to environment variable
+)
+
+type formatSegment struct {
+	kind  segmentKind
+	text  string // literal text, time layout or field name
+	token string // original placeholder, kept when field not found
+}
+
+// formatTemplate is a precompiled format string of LogEvent.Format
+type formatTemplate struct {
+	segments []formatSegment
+	size     int
+}
+
+var (
+	formatCache     sync.Map
+	formatCacheSize int32
+)
+
+// getFormatTemplate returns the cached template of format
+func getFormatTemplate(format string) *formatTemplate {
+	if tmpl, ok := formatCache.Load(format); ok {
+		return tmpl.(*formatTemplate)
+	}
+	tmpl := compileFormat(format)
+	if atomic.LoadInt32(&formatCacheSize) < maxFormatCache {
+		if _, loaded := formatCache.LoadOrStore(format, tmpl); !loaded {
+			atomic.AddInt32(&formatCacheSize, 1)
+		}
+	}
+	return tmpl
+}
+
+// compileFormat splits format into literal and placeholder segments,
+// placeholders follow the patterns of reEventTime, reCurrentTime and revar
+func compileFormat(format string) *formatTemplate {
+	tmpl := &formatTemplate{size: len(format)}
+	literal := strings.Builder{}
+	flush := func() {
+		if literal.Len() > 0 {
+			tmpl.segments = append(tmpl.segments, formatSegment{kind: segmentLiteral, text: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(format); {
+		start := strings.Index(format[i:], "%{")
+		if start < 0 {
+			literal.WriteString(format[i:])
+			break
+		}
+		start += i
+		literal.WriteString(format[i:start])
+
+		end := strings.IndexByte(format[start+2:], '}')
+		if end < 0 {
+			literal.WriteString(format[start:])
+			break
+		}
+		end += start + 2
+		token := format[start : end+1]
+		name := format[start+2 : end]
+
+		var segment formatSegment
+		switch {
+		case strings.HasPrefix(name, "+@") && len(name) > 2:
+			segment = formatSegment{kind: segmentEventTime, text: name[2:], token: token}
+		case strings.HasPrefix(name, "+") && len(name) > 1:
+			segment = formatSegment{kind: segmentCurrentTime, text: name[1:], token: token}
+		case isFieldName(name):
+			segment = formatSegment{kind: segmentField, text: name, token: token}
+		default:
+			// not a placeholder, e.g. "%{a b}", keep scanning after "%{"
+			literal.WriteString(format[start : start+2])
+			i = start + 2
+			continue
+		}
+		flush()
+		tmpl.segments = append(tmpl.segments, segment)
+		i = end + 1
+	}
+	flush()
+
+	return tmpl
+}
+
+// isFieldName reports whether name matches `[\w@\.]+`
+func isFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '@', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// render returns the formatted string of event
+func (tmpl *formatTemplate) render(t LogEvent) string {
+	if len(tmpl.segments) == 1 && tmpl.segments[0].kind == segmentLiteral {
+		return tmpl.segments[0].text
+	}
+
+	out := strings.Builder{}
+	out.Grow(tmpl.size)
+	for _, segment := range tmpl.segments {
+		switch segment.kind {
+		case segmentLiteral:
+			out.WriteString(segment.text)
+		case segmentEventTime:
+			out.WriteString(t.Timestamp.Format(segment.text))
+		case segmentCurrentTime:
+			out.WriteString(time.Now().Format(segment.text))
+		case segmentField:
+			if value := t.GetString(segment.text); value != "" {
+				out.WriteString(value)
+			} else if value = os.Getenv(segment.text); value != "" {
+				out.WriteString(value)
+			} else if segment.text == "HOSTNAME" {
+				if value, _ = os.Hostname(); value != "" {
+					out.WriteString(value)
+				} else {
+					out.WriteString(segment.token)
+				}
+			} else {
+				out.WriteString(segment.token)
+			}
+		}
+	}
+	return out.String()
+}
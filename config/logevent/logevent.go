@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -22,6 +23,7 @@ type Config struct {
 	SortMapKeys bool     `yaml:"sort_map_keys"`
 	RemoveField []string `yaml:"remove_field"`
 
+	json              jsoniter.API
 	jsonMarshal       func(v interface{}) ([]byte, error)
 	jsonMarshalIndent func(v interface{}, prefix, indent string) ([]byte, error)
 }
@@ -41,6 +43,7 @@ func SetConfig(c *Config) {
 		ValidateJsonRawMessage: false,
 		EscapeHTML:             false,
 	}.Froze()
+	config.json = json
 	config.jsonMarshal = json.Marshal
 	config.jsonMarshalIndent = jsonex.MarshalIndent
 }
@@ -126,9 +129,96 @@ func (t LogEvent) getJSONMap() map[string]interface{} {
 	return event
 }
 
+// MarshalJSON encodes the event as the JSON object of getJSONMap, fields are
+// streamed without building the intermediate map unless remove_field is configured
 func (t LogEvent) MarshalJSON() (data []byte, err error) {
-	event := t.getJSONMap()
-	return config.jsonMarshal(event)
+	if len(config.RemoveField) > 0 {
+		event := t.getJSONMap()
+		return config.jsonMarshal(event)
+	}
+
+	stream := config.json.BorrowStream(nil)
+	defer config.json.ReturnStream(stream)
+
+	t.writeJSON(stream)
+	if stream.Error != nil {
+		return nil, stream.Error
+	}
+	return append([]byte(nil), stream.Buffer()...), nil
+}
+
+// writeJSON writes the same fields as getJSONMap, Extra overrides "@timestamp"
+// and "message", non-empty Tags overrides Extra "tags"
+func (t LogEvent) writeJSON(stream *jsoniter.Stream) {
+	_, hasTimestamp := t.Extra["@timestamp"]
+	_, hasMessage := t.Extra["message"]
+	hasTags := len(t.Tags) > 0
+	writeMessage := t.Message != "" && !hasMessage
+
+	stream.WriteObjectStart()
+	if config.SortMapKeys {
+		keys := make([]string, 0, len(t.Extra)+3)
+		if !hasTimestamp {
+			keys = append(keys, "@timestamp")
+		}
+		if writeMessage {
+			keys = append(keys, "message")
+		}
+		for key := range t.Extra {
+			if key != TagsField || !hasTags {
+				keys = append(keys, key)
+			}
+		}
+		if hasTags {
+			keys = append(keys, TagsField)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			t.writeJSONField(stream, i > 0, key)
+		}
+	} else {
+		n := 0
+		if !hasTimestamp {
+			t.writeJSONField(stream, n > 0, "@timestamp")
+			n++
+		}
+		if writeMessage {
+			t.writeJSONField(stream, n > 0, "message")
+			n++
+		}
+		for key := range t.Extra {
+			if key != TagsField || !hasTags {
+				t.writeJSONField(stream, n > 0, key)
+				n++
+			}
+		}
+		if hasTags {
+			t.writeJSONField(stream, n > 0, TagsField)
+		}
+	}
+	stream.WriteObjectEnd()
+}
+
+func (t LogEvent) writeJSONField(stream *jsoniter.Stream, more bool, key string) {
+	if more {
+		stream.WriteMore()
+	}
+	stream.WriteObjectField(key)
+
+	if value, ok := t.Extra[key]; ok && (key != TagsField || len(t.Tags) < 1) {
+		stream.WriteVal(value)
+		return
+	}
+	switch key {
+	case "@timestamp":
+		stream.WriteRaw(`"`)
+		stream.SetBuffer(t.Timestamp.UTC().AppendFormat(stream.Buffer(), timeFormat))
+		stream.WriteRaw(`"`)
+	case "message":
+		stream.WriteString(t.Message)
+	case TagsField:
+		stream.WriteVal(t.Tags)
+	}
 }
 
 func (t LogEvent) MarshalIndent() (data []byte, err error) {
@@ -242,22 +332,10 @@ func FormatWithEventTime(text string, evevtTime time.Time) (result string) {
 }
 
 // Format return string with current time / LogEvent field / ENV, ex: %{hostname}
+// format strings are compiled once and cached, see formatTemplate
 func (t LogEvent) Format(format string) (out string) {
-	out = format
-
-	out = FormatWithEventTime(out, t.Timestamp)
-	out = FormatWithCurrentTime(out)
-
-	matches := revar.FindAllStringSubmatch(out, -1)
-	for _, submatches := range matches {
-		field := submatches[1]
-		value := t.GetString(field)
-		if value != "" {
-			out = strings.Replace(out, submatches[0], value, -1)
-		}
+	if !strings.Contains(format, "%{") {
+		return format
 	}
-
-	out = FormatWithEnv(out)
-
-	return
+	return getFormatTemplate(format).render(t)
 }
@@ -323,3 +323,98 @@ func TestChangeMessage(t *testing.T) {
 	assert.Equal(newMessage, event.GetString("message"))
 
 }
+
+func Test_FormatTemplate(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	key := "TESTENV"
+	originenv := os.Getenv(key)
+	defer func() {
+		os.Setenv(key, originenv)
+	}()
+	os.Setenv(key, "env")
+
+	eventTime := time.Date(2017, time.April, 5, 17, 41, 12, 345, time.UTC)
+	event := LogEvent{
+		Timestamp: eventTime,
+		Message:   "msg",
+		Extra: map[string]interface{}{
+			"foo": "bar",
+		},
+	}
+
+	hostname, _ := os.Hostname()
+	for format, expected := range map[string]string{
+		"plain":                         "plain",
+		"%{foo}-%{foo}":                 "bar-bar",
+		"%{message}%{TESTENV}":          "msgenv",
+		"%{+@2006}/%{foo}/%{null}":      "2017/bar/%{null}",
+		"%{a b} %{foo":                  "%{a b} %{foo",
+		"%{%{foo}}":                     "%{bar}",
+		"%{}%{foo}":                     "%{}bar",
+		"host=%{HOSTNAME}":              "host=" + hostname,
+		"gogstash-%{+@2006.01.02}-%{+}": "gogstash-2017.04.05-%{+}",
+	} {
+		assert.Equal(expected, event.Format(format), format)
+		// cached template
+		assert.Equal(expected, event.Format(format), format)
+	}
+}
+
+func Test_MarshalJSONStream(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	defer SetConfig(config)
+
+	eventTime := time.Date(2017, time.April, 5, 17, 41, 12, 345, time.UTC)
+	for _, event := range []LogEvent{
+		benchEvent,
+		{Timestamp: eventTime},
+		{Timestamp: eventTime, Message: "msg", Tags: []string{"a"}, Extra: map[string]interface{}{"tags": "extra", "z": 1}},
+		{Timestamp: eventTime, Message: "msg", Extra: map[string]interface{}{"message": "extra", "@timestamp": "now", "tags": []interface{}{"b"}}},
+	} {
+		for _, sortKeys := range []bool{false, true} {
+			SetConfig(&Config{SortMapKeys: sortKeys})
+			expected, err := config.jsonMarshal(event.getJSONMap())
+			require.NoError(err)
+			d, err := event.MarshalJSON()
+			require.NoError(err)
+			if sortKeys {
+				assert.Equal(string(expected), string(d))
+			} else {
+				assert.JSONEq(string(expected), string(d))
+			}
+		}
+	}
+
+	// remove_field uses the map encoding
+	SetConfig(&Config{RemoveField: []string{"z"}})
+	d, err := LogEvent{Timestamp: eventTime, Extra: map[string]interface{}{"z": 1, "y": 2}}.MarshalJSON()
+	require.NoError(err)
+	assert.JSONEq(`{"@timestamp":"2017-04-05T17:41:12.000000345Z","y":2}`, string(d))
+}
+
+func Benchmark_Format(b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		benchEvent.Format("gogstash-%{+@2006.01.02}-%{string}-%{child.childA}")
+	}
+}
+
+func Benchmark_MarshalJSON(b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		benchEvent.MarshalJSON()
+	}
+}
+
+func Benchmark_MarshalJSON_Map(b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		config.jsonMarshal(benchEvent.getJSONMap())
+	}
+}
@@ -23,6 +23,12 @@ type TypeOutputConfig interface {
 	IsRunning() (bool, error)
 }
 
+// TypeFlushableOutputConfig is interface of output module buffering events,
+// Flush is called when the pipeline stops at the end of input stream
+type TypeFlushableOutputConfig interface {
+	Flush(ctx context.Context) (err error)
+}
+
 // OutputConfig is basic output config struct
 type OutputConfig struct {
 	CommonConfig
@@ -77,6 +83,12 @@ func (t *Config) startOutputs() (err error) {
 				if len(t.chFilterOut) < 1 {
 					return nil
 				}
+			case <-t.filtersDone:
+				if len(t.chFilterOut) < 1 {
+					flushOutputs(t.ctx, outputs)
+					goglog.Logger.Info("end of input stream, outputs flushed")
+					return nil
+				}
 			case event := <-t.chFilterOut:
 				eg, ctx := errgroup.WithContext(t.ctx)
 				for _, output := range outputs {
@@ -118,3 +130,14 @@ func (t *Config) startOutputs() (err error) {
 
 	return
 }
+
+// flushOutputs flushes outputs buffering events
+func flushOutputs(ctx context.Context, outputs []TypeOutputConfig) {
+	for _, output := range outputs {
+		if flushable, ok := output.(TypeFlushableOutputConfig); ok {
+			if err := flushable.Flush(ctx); err != nil {
+				goglog.Logger.Errorf("flush output module %q failed: %v", output.GetType(), err)
+			}
+		}
+	}
+}
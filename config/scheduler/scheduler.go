@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+)
+
+// errors
+var (
+	ErrorInvalidSchedule2 = errutil.NewFactory("invalid schedule %q: %v")
+	ErrorInvalidDuration3 = errutil.NewFactory("invalid %s %q: %v")
+	ErrorScheduleConflict = errutil.NewFactory("schedule and every can not be set at the same time")
+	ErrorNoSchedule       = errutil.NewFactory("neither schedule nor every is set")
+)
+
+// cron expressions with optional seconds field and descriptors, e.g. "@hourly" and "@every 30s"
+var parser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+var (
+	random      = rand.New(rand.NewSource(time.Now().UnixNano()))
+	randomMutex sync.Mutex
+)
+
+// Config is the schedule options of periodic modules
+type Config struct {
+	Schedule     string `json:"schedule,omitempty"`       // cron expression, e.g. "*/5 * * * *"
+	Every        string `json:"every,omitempty"`          // duration between runs, e.g. "30s"
+	Jitter       string `json:"jitter,omitempty"`         // maximum random delay of each run, e.g. "5s"
+	RunAtStartup *bool  `json:"run_at_startup,omitempty"` // default: true for every, false for schedule
+}
+
+// Scheduler runs a function on the times of Config
+type Scheduler struct {
+	schedule     cron.Schedule
+	every        time.Duration
+	jitter       time.Duration
+	runAtStartup bool
+}
+
+// New returns a Scheduler of conf, defaultEvery is used if neither schedule nor every is set,
+// e.g. the legacy interval option of a module
+func New(conf Config, defaultEvery time.Duration) (*Scheduler, error) {
+	s := &Scheduler{}
+	var err error
+
+	switch {
+	case conf.Schedule != "" && conf.Every != "":
+		return nil, ErrorScheduleConflict.New(nil)
+	case conf.Schedule != "":
+		if s.schedule, err = parser.Parse(conf.Schedule); err != nil {
+			return nil, ErrorInvalidSchedule2.New(nil, conf.Schedule, err)
+		}
+	case conf.Every != "":
+		if s.every, err = time.ParseDuration(conf.Every); err != nil {
+			return nil, ErrorInvalidDuration3.New(nil, "every", conf.Every, err)
+		}
+		if s.every <= 0 {
+			return nil, ErrorInvalidDuration3.New(nil, "every", conf.Every, "must be positive")
+		}
+	case defaultEvery > 0:
+		s.every = defaultEvery
+	default:
+		return nil, ErrorNoSchedule.New(nil)
+	}
+
+	if conf.Jitter != "" {
+		if s.jitter, err = time.ParseDuration(conf.Jitter); err != nil {
+			return nil, ErrorInvalidDuration3.New(nil, "jitter", conf.Jitter, err)
+		}
+		if s.jitter < 0 {
+			return nil, ErrorInvalidDuration3.New(nil, "jitter", conf.Jitter, "must not be negative")
+		}
+	}
+
+	s.runAtStartup = s.schedule == nil
+	if conf.RunAtStartup != nil {
+		s.runAtStartup = *conf.RunAtStartup
+	}
+
+	return s, nil
+}
+
+// Next returns the next scheduled time after t, without jitter
+func (s *Scheduler) Next(t time.Time) time.Time {
+	if s.schedule != nil {
+		return s.schedule.Next(t)
+	}
+	return t.Add(s.every)
+}
+
+// Run calls fn on each scheduled time until ctx done, runs do not overlap,
+// scheduled times passed while fn is running are skipped
+func (s *Scheduler) Run(ctx context.Context, fn func(ctx context.Context)) {
+	now := time.Now()
+	next := now
+	if !s.runAtStartup {
+		next = s.Next(now)
+	}
+
+	for {
+		timer := time.NewTimer(time.Until(next.Add(s.randomJitter())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		fn(ctx)
+
+		now = time.Now()
+		if next = s.Next(next); next.Before(now) {
+			next = s.Next(now)
+		}
+	}
+}
+
+func (s *Scheduler) randomJitter() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	randomMutex.Lock()
+	defer randomMutex.Unlock()
+	return time.Duration(random.Int63n(int64(s.jitter) + 1))
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_scheduler_New(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	s, err := New(Config{}, time.Minute)
+	if assert.NoError(err) {
+		assert.Equal(time.Minute, s.every)
+		assert.True(s.runAtStartup)
+	}
+
+	s, err = New(Config{Schedule: "0 * * * *"}, time.Minute)
+	if assert.NoError(err) {
+		assert.NotNil(s.schedule)
+		assert.False(s.runAtStartup)
+	}
+
+	runAtStartup := false
+	s, err = New(Config{Every: "30s", Jitter: "5s", RunAtStartup: &runAtStartup}, time.Minute)
+	if assert.NoError(err) {
+		assert.Equal(30*time.Second, s.every)
+		assert.Equal(5*time.Second, s.jitter)
+		assert.False(s.runAtStartup)
+	}
+
+	_, err = New(Config{}, 0)
+	assert.True(ErrorNoSchedule.Match(err))
+	_, err = New(Config{Schedule: "* * * * *", Every: "1m"}, 0)
+	assert.True(ErrorScheduleConflict.Match(err))
+	_, err = New(Config{Schedule: "* * *"}, 0)
+	assert.True(ErrorInvalidSchedule2.Match(err))
+	_, err = New(Config{Every: "1x"}, 0)
+	assert.True(ErrorInvalidDuration3.Match(err))
+	_, err = New(Config{Every: "-1s"}, 0)
+	assert.True(ErrorInvalidDuration3.Match(err))
+	_, err = New(Config{Every: "1s", Jitter: "-1s"}, 0)
+	assert.True(ErrorInvalidDuration3.Match(err))
+}
+
+func Test_scheduler_Next(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	base := time.Date(2021, 1, 1, 0, 1, 2, 0, time.UTC)
+	for expr, expected := range map[string]time.Time{
+		"*/5 * * * *":     time.Date(2021, 1, 1, 0, 5, 0, 0, time.UTC),
+		"30 * * * * *":    time.Date(2021, 1, 1, 0, 1, 30, 0, time.UTC),
+		"@hourly":         time.Date(2021, 1, 1, 1, 0, 0, 0, time.UTC),
+		"@every 10s":      time.Date(2021, 1, 1, 0, 1, 12, 0, time.UTC),
+		"0 9 * * MON-FRI": time.Date(2021, 1, 1, 9, 0, 0, 0, time.UTC),
+	} {
+		s, err := New(Config{Schedule: expr}, 0)
+		if assert.NoError(err, expr) {
+			assert.Equal(expected, s.Next(base), expr)
+		}
+	}
+
+	s, err := New(Config{Every: "90s"}, 0)
+	if assert.NoError(err) {
+		assert.Equal(base.Add(90*time.Second), s.Next(base))
+	}
+}
+
+func Test_scheduler_Run(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	s, err := New(Config{Every: "100ms", Jitter: "20ms"}, 0)
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var count int32
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		defer close(done)
+		s.Run(ctx, func(context.Context) {
+			if atomic.AddInt32(&count, 1) == 1 {
+				assert.WithinDuration(start, time.Now(), 50*time.Millisecond)
+			}
+		})
+	}()
+
+	time.Sleep(350 * time.Millisecond)
+	cancel()
+	<-done
+	assert.EqualValues(4, atomic.LoadInt32(&count))
+
+	runAtStartup := false
+	s, err = New(Config{Every: "100ms", RunAtStartup: &runAtStartup}, 0)
+	require.NoError(err)
+	ctx, cancel = context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	count = 0
+	s.Run(ctx, func(context.Context) {
+		atomic.AddInt32(&count, 1)
+	})
+	assert.EqualValues(1, atomic.LoadInt32(&count))
+}
@@ -6,16 +6,16 @@ require (
 	cloud.google.com/go v0.38.0
 	github.com/Knetic/govaluate v3.0.0+incompatible
 	github.com/TrueFurby/go-callvis v0.3.5 // indirect
-	github.com/alicebob/gopher-json v0.0.0-20180125190556-5a6b3ba71ee6 // indirect
-	github.com/alicebob/miniredis v2.5.0+incompatible
+	github.com/alicebob/miniredis/v2 v2.22.0
 	github.com/bitly/go-hostpool v0.0.0-20171023180738-a3a6125de932
+	github.com/bmatcuk/doublestar/v4 v4.6.1
 	github.com/codegangsta/inject v0.0.0-20150114235600-33e0aa1cb7c0
 	github.com/drhodes/golorem v0.0.0-20160418191928-ecccc744c2d9
 	github.com/elastic/go-lumber v0.1.0
 	github.com/fsnotify/fsnotify v1.4.7
 	github.com/fsouza/go-dockerclient v1.3.6
 	github.com/gin-gonic/gin v1.4.0
-	github.com/go-logfmt/logfmt v0.4.0 // indirect
+	github.com/go-logfmt/logfmt v0.4.0
 	github.com/go-martini/martini v0.0.0-20170121215854-22fa46961aab
 	github.com/go-sql-driver/mysql v1.4.1
 	github.com/golang/mock v1.3.1
@@ -30,13 +30,15 @@ require (
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/json-iterator/go v1.1.6
 	github.com/kardianos/osext v0.0.0-20190222173326-2bc1f35cddc0
-	github.com/klauspost/compress v1.7.2 // indirect
+	github.com/klauspost/compress v1.15.15
 	github.com/klauspost/cpuid v1.2.1 // indirect
 	github.com/lib/pq v1.1.1
 	github.com/libp2p/go-reuseport v0.0.1
 	github.com/lusis/slack-test v0.0.0-20190426140909-c40012f20018 // indirect
 	github.com/mailru/easyjson v0.0.0-20190626092158-b2ccc519800e // indirect
 	github.com/martini-contrib/render v0.0.0-20150707142108-ec18f8345a11
+	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/nlopes/slack v0.5.0
 	github.com/olivere/elastic v6.2.21+incompatible // indirect
 	github.com/oschwald/geoip2-golang v1.2.1
@@ -44,6 +46,7 @@ require (
 	github.com/oxtoacart/bpool v0.0.0-20190530202638-03653db5a59c // indirect
 	github.com/pmezard/go-difflib v1.0.0
 	github.com/prometheus/client_golang v1.0.0
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/satyrius/gonx v1.3.1-0.20181123214749-d96bd26e3b2c
 	github.com/sirupsen/logrus v1.3.0
 	github.com/spf13/cobra v0.0.3
@@ -55,7 +58,6 @@ require (
 	github.com/tsaikd/govalidator v0.0.0-20161031084447-986f2244fc69
 	github.com/ua-parser/uap-go v0.0.0-20190303233514-1004ccd816b3
 	github.com/vjeantet/grok v1.0.0
-	github.com/yuin/gopher-lua v0.0.0-20190514113301-1cd887cd7036 // indirect
 	go.opencensus.io v0.22.0 // indirect
 	golang.org/x/sync v0.0.0-20190423024810-112230192c58
 	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4
@@ -0,0 +1,138 @@
+package inputbeats
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/viethqc/gogstash/config/goglog"
+)
+
+// listener limits concurrent client connections and wraps accepted
+// connections to enforce the client inactivity timeout
+type listener struct {
+	net.Listener
+	maxConnections    int
+	inactivityTimeout time.Duration
+	connSlots         chan struct{}
+}
+
+func newListener(l net.Listener, maxConnections int, inactivityTimeout time.Duration) *listener {
+	result := &listener{
+		Listener:          l,
+		maxConnections:    maxConnections,
+		inactivityTimeout: inactivityTimeout,
+	}
+	if maxConnections > 0 {
+		result.connSlots = make(chan struct{}, maxConnections)
+	}
+	return result
+}
+
+// Accept waits for the next connection, closing connections exceeding max_connections
+func (l *listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if !l.acquireConn() {
+			goglog.Logger.Warnf("beats input: max_connections %d reached, close connection from %q", l.maxConnections, conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		client := &clientConn{
+			Conn:              conn,
+			inactivityTimeout: l.inactivityTimeout,
+			release:           l.releaseConn,
+		}
+		// new connections are idle until the client sends its first batch
+		if err := client.SetReadDeadline(time.Time{}); err != nil {
+			client.Close()
+			continue
+		}
+		return client, nil
+	}
+}
+
+func (l *listener) acquireConn() bool {
+	if l.connSlots == nil {
+		return true
+	}
+	select {
+	case l.connSlots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *listener) releaseConn() {
+	if l.connSlots != nil {
+		<-l.connSlots
+	}
+}
+
+// clientConn is a client connection which applies the inactivity timeout
+// while the lumberjack server waits for the next batch. Writes (ACKs and
+// keepalives) count as activity so clients waiting on a saturated pipeline
+// are not disconnected.
+type clientConn struct {
+	net.Conn
+	inactivityTimeout time.Duration
+	release           func()
+
+	mutex     sync.Mutex
+	idle      bool
+	closeOnce sync.Once
+}
+
+// SetReadDeadline replaces the lumberjack server's "no deadline" with the inactivity timeout
+func (c *clientConn) SetReadDeadline(t time.Time) error {
+	if c.inactivityTimeout <= 0 {
+		return c.Conn.SetReadDeadline(t)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.idle = t.IsZero()
+	if c.idle {
+		t = time.Now().Add(c.inactivityTimeout)
+	}
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *clientConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil && c.isIdle() {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			goglog.Logger.Infof("beats input: close inactive connection from %q", c.RemoteAddr())
+			c.Close()
+		}
+	}
+	return n, err
+}
+
+func (c *clientConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if err == nil && c.inactivityTimeout > 0 {
+		c.mutex.Lock()
+		if c.idle {
+			_ = c.Conn.SetReadDeadline(time.Now().Add(c.inactivityTimeout))
+		}
+		c.mutex.Unlock()
+	}
+	return n, err
+}
+
+// Close closes the connection and releases its max_connections slot
+func (c *clientConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(c.release)
+	return err
+}
+
+func (c *clientConn) isIdle() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.idle
+}
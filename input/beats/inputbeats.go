@@ -5,8 +5,11 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"time"
 
+	"github.com/elastic/go-lumber/lj"
 	"github.com/elastic/go-lumber/server"
+	jsoniter "github.com/json-iterator/go"
 	reuse "github.com/libp2p/go-reuseport"
 	codecjson "github.com/viethqc/gogstash/codec/json"
 	"github.com/viethqc/gogstash/config"
@@ -17,6 +20,9 @@ import (
 // ModuleName is the name used in config file
 const ModuleName = "beats"
 
+// MetadataField is the event field of beats metadata (beat name, version, type)
+const MetadataField = "@metadata"
+
 // InputConfig holds the configuration json fields and internal objects
 type InputConfig struct {
 	config.InputConfig
@@ -38,6 +44,11 @@ type InputConfig struct {
 	// SSL Verify, defaults to false
 	SSLVerify bool `json:"ssl_verify"`
 
+	// Close idle clients after the given seconds of inactivity, 0 to disable, defaults to 60
+	ClientInactivityTimeout int `json:"client_inactivity_timeout"`
+	// Maximum number of concurrent client connections, 0 for unlimited
+	MaxConnections int `json:"max_connections"`
+
 	tlsConfig *tls.Config
 }
 
@@ -49,7 +60,8 @@ func DefaultInputConfig() InputConfig {
 				Type: ModuleName,
 			},
 		},
-		Host: "0.0.0.0",
+		Host:                    "0.0.0.0",
+		ClientInactivityTimeout: 60,
 	}
 }
 
@@ -91,20 +103,12 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 // Start wraps the actual function starting the plugin
 func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) (err error) {
 	addr := fmt.Sprintf("%s:%d", t.Host, t.Port)
-	s, err := server.ListenAndServeWith(func(network, addr string) (l net.Listener, err error) {
-		if t.ReusePort {
-			l, err = reuse.Listen(network, addr)
-		} else {
-			l, err = net.Listen(network, addr)
-		}
-		if err != nil {
-			return nil, err
-		}
-		if t.SSL {
-			l = tls.NewListener(l, t.tlsConfig)
-		}
-		return l, err
-	}, addr, server.JSONDecoder(t.Codec.DecodeEvent))
+	// unbuffered, so client handlers wait for the pipeline instead of queueing batches
+	batches := make(chan *lj.Batch)
+	s, err := server.ListenAndServeWith(t.listen, addr,
+		server.JSONDecoder(t.decodeEvent),
+		server.Channel(batches),
+	)
 	if err != nil {
 		return err
 	}
@@ -116,12 +120,72 @@ func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEven
 		case <-ctx.Done():
 			goglog.Logger.Info("input beats stopped")
 			return nil
-		case data := <-s.ReceiveChan():
-			for _, e := range data.Events {
-				msgChan <- e.(logevent.LogEvent)
+		case batch := <-s.ReceiveChan():
+			// ACK only after all events are accepted by the pipeline,
+			// beats keep waiting (and backing off) until then
+			if !t.accept(ctx, batch, msgChan) {
+				goglog.Logger.Info("input beats stopped")
+				return nil
+			}
+			batch.ACK()
+		}
+	}
+}
+
+func (t *InputConfig) listen(network, addr string) (l net.Listener, err error) {
+	if t.ReusePort {
+		l, err = reuse.Listen(network, addr)
+	} else {
+		l, err = net.Listen(network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.SSL {
+		l = tls.NewListener(l, t.tlsConfig)
+	}
+	return newListener(l, t.MaxConnections, time.Duration(t.ClientInactivityTimeout)*time.Second), nil
+}
+
+// accept sends batch events to the pipeline, returns false if ctx is done before all are accepted
+func (t *InputConfig) accept(ctx context.Context, batch *lj.Batch, msgChan chan<- logevent.LogEvent) bool {
+	for _, e := range batch.Events {
+		event, ok := e.(logevent.LogEvent)
+		if !ok {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case msgChan <- event:
+		}
+	}
+	return true
+}
+
+// decodeEvent decodes beats event by codec and preserves the beats metadata
+func (t *InputConfig) decodeEvent(data []byte, v interface{}) error {
+	event := logevent.LogEvent{}
+	if err := t.Codec.DecodeEvent(data, &event); err != nil {
+		return err
+	}
+
+	if _, ok := event.Extra[MetadataField]; !ok {
+		if metadata := jsoniter.Get(data, MetadataField); metadata.ValueType() == jsoniter.ObjectValue {
+			if event.Extra == nil {
+				event.Extra = map[string]interface{}{}
 			}
-			data.ACK()
+			event.Extra[MetadataField] = metadata.GetInterface()
 		}
 	}
+
+	switch e := v.(type) {
+	case *interface{}:
+		*e = event
+	case *logevent.LogEvent:
+		*e = event
+	default:
+		return config.ErrorUnsupportedTargetEvent
+	}
 	return nil
 }
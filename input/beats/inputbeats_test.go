@@ -24,11 +24,13 @@ import (
 	codecjson "github.com/viethqc/gogstash/codec/json"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
 )
 
 func init() {
 	goglog.Logger.SetLevel(logrus.DebugLevel)
 	config.RegistInputHandler(ModuleName, InitHandler)
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
 	config.RegistCodecHandler(codecjson.ModuleName, codecjson.InitHandler)
 }
 
@@ -177,6 +179,176 @@ input:
 	}
 }
 
+func startBeats(ctx context.Context, t *testing.T, raw config.ConfigRaw, msgChan chan<- logevent.LogEvent) {
+	require := require.New(t)
+	input, err := InitHandler(ctx, &raw)
+	require.NoError(err)
+	go input.Start(ctx, msgChan)
+	time.Sleep(500 * time.Millisecond)
+}
+
+func Test_input_beats_metadata(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgChan := make(chan logevent.LogEvent, 10)
+	startBeats(ctx, t, config.ConfigRaw{"host": "127.0.0.1", "port": 5045}, msgChan)
+
+	c, err := client.SyncDial("127.0.0.1:5045")
+	require.NoError(err)
+	defer c.Close()
+
+	n, err := c.Send([]interface{}{map[string]interface{}{
+		"message": "test message",
+		"@metadata": map[string]interface{}{
+			"beat":    "filebeat",
+			"type":    "_doc",
+			"version": "7.10.2",
+		},
+	}})
+	require.NoError(err)
+	assert.Equal(1, n)
+
+	event := <-msgChan
+	assert.Equal("test message", event.Message)
+	assert.Equal("filebeat", event.GetString("@metadata.beat"))
+	assert.Equal("_doc", event.GetString("@metadata.type"))
+	assert.Equal("7.10.2", event.GetString("@metadata.version"))
+}
+
+func Test_input_beats_metadata_codec(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgChan := make(chan logevent.LogEvent, 10)
+	startBeats(ctx, t, config.ConfigRaw{"host": "127.0.0.1", "port": 5046, "codec": config.DefaultCodecName}, msgChan)
+
+	c, err := client.SyncDial("127.0.0.1:5046")
+	require.NoError(err)
+	defer c.Close()
+
+	_, err = c.Send([]interface{}{map[string]interface{}{
+		"message":   "test message",
+		"@metadata": map[string]interface{}{"beat": "winlogbeat"},
+	}})
+	require.NoError(err)
+
+	event := <-msgChan
+	assert.Equal("winlogbeat", event.GetString("@metadata.beat"))
+}
+
+func Test_input_beats_backpressure(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// unbuffered, the pipeline accepts one event at a time
+	msgChan := make(chan logevent.LogEvent)
+	startBeats(ctx, t, config.ConfigRaw{"host": "127.0.0.1", "port": 5047}, msgChan)
+
+	c, err := client.SyncDial("127.0.0.1:5047")
+	require.NoError(err)
+	defer c.Close()
+
+	acked := make(chan int, 1)
+	go func() {
+		n, err := c.Send([]interface{}{
+			map[string]interface{}{"message": "first"},
+			map[string]interface{}{"message": "second"},
+		})
+		assert.NoError(err)
+		acked <- n
+	}()
+
+	assert.Equal("first", (<-msgChan).Message)
+	select {
+	case <-acked:
+		assert.Fail("batch acked before all events accepted")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	assert.Equal("second", (<-msgChan).Message)
+	select {
+	case n := <-acked:
+		assert.Equal(2, n)
+	case <-time.After(2 * time.Second):
+		assert.Fail("batch not acked")
+	}
+}
+
+func Test_input_beats_max_connections(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgChan := make(chan logevent.LogEvent, 10)
+	startBeats(ctx, t, config.ConfigRaw{"host": "127.0.0.1", "port": 5048, "max_connections": 1}, msgChan)
+
+	data := []interface{}{map[string]interface{}{"message": "test message"}}
+
+	c1, err := client.SyncDial("127.0.0.1:5048", client.Timeout(time.Second))
+	require.NoError(err)
+	_, err = c1.Send(data)
+	assert.NoError(err)
+
+	c2, err := client.SyncDial("127.0.0.1:5048", client.Timeout(time.Second))
+	require.NoError(err)
+	_, err = c2.Send(data)
+	assert.Error(err)
+	c2.Close()
+
+	// the slot is released when the first client disconnects
+	c1.Close()
+	time.Sleep(100 * time.Millisecond)
+	c3, err := client.SyncDial("127.0.0.1:5048", client.Timeout(time.Second))
+	require.NoError(err)
+	defer c3.Close()
+	_, err = c3.Send(data)
+	assert.NoError(err)
+}
+
+func Test_input_beats_client_inactivity_timeout(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgChan := make(chan logevent.LogEvent, 10)
+	startBeats(ctx, t, config.ConfigRaw{"host": "127.0.0.1", "port": 5049, "client_inactivity_timeout": 1}, msgChan)
+
+	data := []interface{}{map[string]interface{}{"message": "test message"}}
+
+	c, err := client.SyncDial("127.0.0.1:5049", client.Timeout(time.Second))
+	require.NoError(err)
+	defer c.Close()
+	_, err = c.Send(data)
+	assert.NoError(err)
+
+	time.Sleep(500 * time.Millisecond)
+	_, err = c.Send(data)
+	assert.NoError(err)
+
+	time.Sleep(1500 * time.Millisecond)
+	_, err = c.Send(data)
+	assert.Error(err)
+}
+
 func publicKey(priv interface{}) interface{} {
 	switch k := priv.(type) {
 	case *rsa.PrivateKey:
@@ -17,14 +17,50 @@ var (
 	regNameTrim = regexp.MustCompile(`^/`)
 )
 
+// ContainerMeta holds container metadata used for selection and event enrichment
+type ContainerMeta struct {
+	ID     string
+	Name   string   // first name without leading "/"
+	Names  []string // raw names as reported by docker
+	Image  string
+	Labels map[string]string
+	Tty    bool // only known for inspected containers
+}
+
 // GetContainerInfo return container info from docker object
 func GetContainerInfo(container interface{}) (id string, name string, err error) {
+	meta, err := GetContainerMeta(container)
+	if err != nil {
+		return "", "", err
+	}
+	return meta.ID, meta.Name, nil
+}
+
+// GetContainerMeta return container metadata from docker object
+func GetContainerMeta(container interface{}) (meta ContainerMeta, err error) {
 	switch info := container.(type) {
 	case docker.APIContainers:
-		return info.ID, regNameTrim.ReplaceAllString(info.Names[0], ""), nil
+		meta = ContainerMeta{
+			ID:     info.ID,
+			Names:  info.Names,
+			Image:  info.Image,
+			Labels: info.Labels,
+		}
 	case *docker.Container:
-		return info.ID, regNameTrim.ReplaceAllString(info.Name, ""), nil
+		meta = ContainerMeta{
+			ID:    info.ID,
+			Names: []string{info.Name},
+		}
+		if info.Config != nil {
+			meta.Image = info.Config.Image
+			meta.Labels = info.Config.Labels
+			meta.Tty = info.Config.Tty
+		}
 	default:
-		return "", "", ErrUnsupportedContainerType1.New(nil, reflect.TypeOf(container).String())
+		return meta, ErrUnsupportedContainerType1.New(nil, reflect.TypeOf(container).String())
+	}
+	if len(meta.Names) > 0 {
+		meta.Name = regNameTrim.ReplaceAllString(meta.Names[0], "")
 	}
+	return meta, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/fsouza/go-dockerclient"
@@ -23,6 +24,10 @@ type InputConfig struct {
 	DockerURL               string   `json:"dockerurl"`
 	IncludePatterns         []string `json:"include_patterns"`
 	ExcludePatterns         []string `json:"exclude_patterns"`
+	IncludeLabels           []string `json:"include_labels,omitempty"` // "key" or "key=value", all must match
+	ExcludeLabels           []string `json:"exclude_labels,omitempty"` // "key" or "key=value", any match excludes
+	IncludeImages           []string `json:"include_images,omitempty"` // regular expressions of image name
+	ExcludeImages           []string `json:"exclude_images,omitempty"` // regular expressions of image name
 	SincePath               string   `json:"sincepath"`
 	StartPos                string   `json:"start_position,omitempty"` // one of ["beginning", "end"]
 	ConnectionRetryInterval int      `json:"connection_retry_interval,omitempty"`
@@ -31,6 +36,8 @@ type InputConfig struct {
 	sincedb        *SinceDB
 	includes       []*regexp.Regexp
 	excludes       []*regexp.Regexp
+	includeImages  []*regexp.Regexp
+	excludeImages  []*regexp.Regexp
 	hostname       string
 	client         *docker.Client
 }
@@ -71,11 +78,8 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 		return nil, err
 	}
 
-	for _, pattern := range conf.IncludePatterns {
-		conf.includes = append(conf.includes, regexp.MustCompile(pattern))
-	}
-	for _, pattern := range conf.ExcludePatterns {
-		conf.excludes = append(conf.excludes, regexp.MustCompile(pattern))
+	if err = conf.initFilters(); err != nil {
+		return nil, err
 	}
 	if conf.sincedb, err = NewSinceDB(conf.SincePath); err != nil {
 		return nil, err
@@ -104,10 +108,19 @@ func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEven
 			return ErrorListContainerFailed.New(err)
 		}
 
-		for _, container := range containers {
-			if !t.isValidContainer(container.Names) {
+		for _, apiContainer := range containers {
+			meta, err := dockertool.GetContainerMeta(apiContainer)
+			if err != nil {
+				return ErrorGetContainerInfoFailed.New(err)
+			}
+			if !t.isValidContainer(meta) {
 				continue
 			}
+			// inspect for tty and full config
+			container, err := t.client.InspectContainer(meta.ID)
+			if err != nil {
+				return ErrorInspectContainerFailed.New(err)
+			}
 			since, err2 := t.getSince(container.ID)
 			if err2 != nil {
 				return err2
@@ -140,7 +153,11 @@ func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEven
 					if err != nil {
 						return ErrorInspectContainerFailed.New(err)
 					}
-					if !t.isValidContainer([]string{container.Name}) {
+					meta, err := dockertool.GetContainerMeta(container)
+					if err != nil {
+						return ErrorGetContainerInfoFailed.New(err)
+					}
+					if !t.isValidContainer(meta) {
 						continue
 					}
 					since, err := t.getSince(dockerEvent.ID)
@@ -172,17 +189,58 @@ func (t *InputConfig) getSince(containerID string) (since *time.Time, err error)
 	return
 }
 
-func (t *InputConfig) isValidContainer(names []string) bool {
-	for _, name := range names {
-		for _, re := range t.excludes {
-			if re.MatchString(name) {
-				return false
+func (t *InputConfig) initFilters() (err error) {
+	compile := func(patterns []string) (res []*regexp.Regexp, err error) {
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, err
 			}
+			res = append(res, re)
 		}
-		for _, re := range t.includes {
-			if re.MatchString(name) {
-				return true
-			}
+		return
+	}
+	if t.includes, err = compile(t.IncludePatterns); err != nil {
+		return
+	}
+	if t.excludes, err = compile(t.ExcludePatterns); err != nil {
+		return
+	}
+	if t.includeImages, err = compile(t.IncludeImages); err != nil {
+		return
+	}
+	if t.excludeImages, err = compile(t.ExcludeImages); err != nil {
+		return
+	}
+	return nil
+}
+
+// isValidContainer reports whether logs of container should be read,
+// a container is skipped if any exclude matches, or any kind of include given does not match
+func (t *InputConfig) isValidContainer(meta dockertool.ContainerMeta) bool {
+	for _, label := range t.ExcludeLabels {
+		if matchLabel(meta.Labels, label) {
+			return false
+		}
+	}
+	for _, label := range t.IncludeLabels {
+		if !matchLabel(meta.Labels, label) {
+			return false
+		}
+	}
+	if matchAny(t.excludeImages, meta.Image) {
+		return false
+	}
+	if len(t.includeImages) > 0 && !matchAny(t.includeImages, meta.Image) {
+		return false
+	}
+
+	for _, name := range meta.Names {
+		if matchAny(t.excludes, name) {
+			return false
+		}
+		if matchAny(t.includes, name) {
+			return true
 		}
 	}
 
@@ -191,3 +249,22 @@ func (t *InputConfig) isValidContainer(names []string) bool {
 	}
 	return true
 }
+
+// matchLabel reports whether labels has the key of "key", or the value of "key=value"
+func matchLabel(labels map[string]string, label string) bool {
+	kv := strings.SplitN(label, "=", 2)
+	v, ok := labels[kv[0]]
+	if len(kv) < 2 {
+		return ok
+	}
+	return ok && v == kv[1]
+}
+
+func matchAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
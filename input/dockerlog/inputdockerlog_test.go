@@ -11,6 +11,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+	"github.com/viethqc/gogstash/input/dockerlog/dockertool"
 )
 
 func init() {
@@ -44,3 +46,82 @@ input:
 		t.Log(event)
 	}
 }
+
+func Test_input_dockerlog_stream_partial(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	since := time.Time{}
+	meta := dockertool.ContainerMeta{
+		ID:    "0123456789ab",
+		Name:  "web_1",
+		Image: "nginx:latest",
+		Labels: map[string]string{
+			ComposeServiceLabel: "web",
+			ComposeProjectLabel: "shop",
+		},
+	}
+	stream := NewContainerLogStream(msgChan, meta.ID, withStream(containerEventExtra("host1", meta), "stdout"), &since, goglog.Logger)
+	stream.Framed = true
+
+	part1 := `{"msg":"` + strings.Repeat("a", 16384-8)
+	_, err := stream.Write([]byte("2019-01-02T03:04:05.000000001Z " + part1))
+	require.NoError(err)
+	_, err = stream.Write([]byte("2019-01-02T03:04:05.000000002Z " + `"}` + "\n"))
+	require.NoError(err)
+	_, err = stream.Write([]byte("2019-01-02T03:04:05.000000003Z second\n"))
+	require.NoError(err)
+
+	event := <-msgChan
+	assert.Equal(part1+`"}`, event.Message)
+	assert.Equal(time.Date(2019, 1, 2, 3, 4, 5, 1, time.UTC), event.Timestamp)
+	assert.Equal("0123456789ab", event.Extra["containerid"])
+	assert.Equal("web_1", event.Extra["containername"])
+	assert.Equal("nginx:latest", event.Extra["containerimage"])
+	assert.Equal("web", event.Extra["composeservice"])
+	assert.Equal("shop", event.Extra["composeproject"])
+	assert.Equal("stdout", event.Extra["stream"])
+	if labels, ok := event.Extra["containerlabels"].(map[string]interface{}); assert.True(ok) {
+		assert.Equal("web", labels[ComposeServiceLabel])
+	}
+
+	event = <-msgChan
+	assert.Equal("second", event.Message)
+}
+
+func Test_input_dockerlog_filter(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	conf := DefaultInputConfig()
+	conf.IncludeLabels = []string{"logging=enabled"}
+	conf.ExcludeLabels = []string{"logging.skip"}
+	conf.ExcludeImages = []string{`^busybox`}
+	require.NoError(conf.initFilters())
+
+	container := func(name string, image string, labels map[string]string) dockertool.ContainerMeta {
+		return dockertool.ContainerMeta{Names: []string{"/" + name}, Image: image, Labels: labels}
+	}
+	enabled := map[string]string{"logging": "enabled"}
+	assert.True(conf.isValidContainer(container("web", "nginx", enabled)))
+	assert.False(conf.isValidContainer(container("web", "nginx", nil)))
+	assert.False(conf.isValidContainer(container("web", "nginx", map[string]string{"logging": "disabled"})))
+	assert.False(conf.isValidContainer(container("web", "nginx", map[string]string{"logging": "enabled", "logging.skip": ""})))
+	assert.False(conf.isValidContainer(container("web", "busybox:1.31", enabled)))
+	assert.False(conf.isValidContainer(container("gogstash", "nginx", enabled)))
+
+	conf.IncludeImages = []string{`^nginx`}
+	conf.IncludePatterns = []string{`^/api`}
+	require.NoError(conf.initFilters())
+	assert.True(conf.isValidContainer(container("api", "nginx", enabled)))
+	assert.False(conf.isValidContainer(container("web", "nginx", enabled)))
+	assert.False(conf.isValidContainer(container("api", "redis", enabled)))
+
+	conf.IncludePatterns = []string{`(`}
+	assert.Error(conf.initFilters())
+}
@@ -6,41 +6,56 @@ import (
 	"time"
 
 	"github.com/fsouza/go-dockerclient"
+	"github.com/viethqc/gogstash/config/goglog"
 	"github.com/viethqc/gogstash/config/logevent"
 	"github.com/viethqc/gogstash/input/dockerlog/dockertool"
 )
 
+// labels set by docker compose
+const (
+	ComposeServiceLabel = "com.docker.compose.service"
+	ComposeProjectLabel = "com.docker.compose.project"
+)
+
 func (t *InputConfig) containerLogLoop(ctx context.Context, container interface{}, since *time.Time, msgChan chan<- logevent.LogEvent) (err error) {
-	id, name, err := dockertool.GetContainerInfo(container)
+	meta, err := dockertool.GetContainerMeta(container)
 	if err != nil {
 		return ErrorGetContainerInfoFailed.New(err)
 	}
+	id := meta.ID
 	if t.containerExist.Exist(id) {
 		return ErrorContainerLoopRunning1.New(nil, id)
 	}
 	t.containerExist.Add(id)
 	defer t.containerExist.Remove(id)
 
-	eventExtra := map[string]interface{}{
-		"host":          t.hostname,
-		"containername": name,
-	}
+	eventExtra := containerEventExtra(t.hostname, meta)
 
 	retry := 5
-	stream := NewContainerLogStream(msgChan, id, eventExtra, since, nil)
+	var stdout, stderr *ContainerLogStream
+	if meta.Tty {
+		// tty output is not multiplexed, stdout and stderr are merged
+		stream := NewContainerLogStream(msgChan, id, eventExtra, since, goglog.Logger)
+		stdout, stderr = &stream, &stream
+	} else {
+		outStream := NewContainerLogStream(msgChan, id, withStream(eventExtra, "stdout"), since, goglog.Logger)
+		errStream := NewContainerLogStream(msgChan, id, withStream(eventExtra, "stderr"), since, goglog.Logger)
+		outStream.Framed, errStream.Framed = true, true
+		stdout, stderr = &outStream, &errStream
+	}
 
 	for err == nil || retry > 0 {
 		err = t.client.Logs(docker.LogsOptions{
 			Context:      ctx,
 			Container:    id,
-			OutputStream: &stream,
-			ErrorStream:  &stream,
+			OutputStream: stdout,
+			ErrorStream:  stderr,
 			Follow:       true,
 			Stdout:       true,
 			Stderr:       true,
 			Timestamps:   true,
 			Tail:         "",
-			RawTerminal:  true,
+			RawTerminal:  meta.Tty,
 		})
 		if err != nil && strings.Contains(err.Error(), "connection refused") {
 			retry--
@@ -52,3 +67,36 @@ func (t *InputConfig) containerLogLoop(ctx context.Context, container interface{
 
 	return
 }
+
+// containerEventExtra returns the fields added to events of container
+func containerEventExtra(hostname string, meta dockertool.ContainerMeta) map[string]interface{} {
+	eventExtra := map[string]interface{}{
+		"host":           hostname,
+		"containerid":    meta.ID,
+		"containername":  meta.Name,
+		"containerimage": meta.Image,
+	}
+	if len(meta.Labels) > 0 {
+		labels := make(map[string]interface{}, len(meta.Labels))
+		for k, v := range meta.Labels {
+			labels[k] = v
+		}
+		eventExtra["containerlabels"] = labels
+	}
+	if service := meta.Labels[ComposeServiceLabel]; service != "" {
+		eventExtra["composeservice"] = service
+	}
+	if project := meta.Labels[ComposeProjectLabel]; project != "" {
+		eventExtra["composeproject"] = project
+	}
+	return eventExtra
+}
+
+func withStream(eventExtra map[string]interface{}, stream string) map[string]interface{} {
+	extra := make(map[string]interface{}, len(eventExtra)+1)
+	for k, v := range eventExtra {
+		extra[k] = v
+	}
+	extra["stream"] = stream
+	return extra
+}
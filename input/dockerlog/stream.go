@@ -24,7 +24,10 @@ func NewContainerLogStream(msgChan chan<- logevent.LogEvent, id string, eventExt
 
 type ContainerLogStream struct {
 	io.Writer
-	ID         string
+	ID string
+	// Framed is set when each Write is one docker log message, i.e. demultiplexed output of non-tty containers,
+	// messages split by docker over 16KB are written without trailing newline and reassembled
+	Framed     bool
 	eventChan  chan<- logevent.LogEvent
 	eventExtra map[string]interface{}
 	logger     *logrus.Logger
@@ -33,20 +36,27 @@ type ContainerLogStream struct {
 }
 
 func (t *ContainerLogStream) Write(p []byte) (n int, err error) {
-	n, err = t.buffer.Write(p)
-	if err != nil {
+	n = len(p)
+	if t.Framed && t.buffer.Len() > 0 {
+		// continuation of a partial message, keep the timestamp of the first part only
+		if loc := reTime.FindIndex(p); len(loc) > 0 && loc[0] == 0 {
+			p = bytes.TrimPrefix(p[loc[1]:], []byte(" "))
+		}
+	}
+	if _, err = t.buffer.Write(p); err != nil {
 		t.logger.Fatal(err)
 		return
 	}
 
 	idx := bytes.IndexByte(t.buffer.Bytes(), '\n')
-	for idx > 0 {
+	for idx >= 0 {
 		data := t.buffer.Next(idx)
-		err = t.sendEvent(data)
 		t.buffer.Next(1)
-		if err != nil {
-			t.logger.Fatal(err)
-			return
+		if len(data) > 0 {
+			if err = t.sendEvent(data); err != nil {
+				t.logger.Fatal(err)
+				return
+			}
 		}
 		idx = bytes.IndexByte(t.buffer.Bytes(), '\n')
 	}
@@ -65,9 +75,12 @@ func (t *ContainerLogStream) sendEvent(data []byte) (err error) {
 	event := logevent.LogEvent{
 		Timestamp: time.Now(),
 		Message:   string(data),
-		Extra:     t.eventExtra,
+		Extra:     make(map[string]interface{}, len(t.eventExtra)+1),
 	}
 
+	for k, v := range t.eventExtra {
+		event.Extra[k] = v
+	}
 	event.Extra["containerid"] = t.ID
 
 	loc := reTime.FindIndex(data)
@@ -10,6 +10,7 @@ import (
 	"github.com/viethqc/gogstash/KDGoLib/errutil"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/logevent"
+	"github.com/viethqc/gogstash/config/scheduler"
 	"github.com/viethqc/gogstash/input/dockerlog/dockertool"
 	"golang.org/x/sync/errgroup"
 )
@@ -20,19 +21,20 @@ const ModuleName = "dockerstats"
 // InputConfig holds the configuration json fields and internal objects
 type InputConfig struct {
 	config.InputConfig
+	scheduler.Config
 	DockerURL               string   `json:"dockerurl"`
 	IncludePatterns         []string `json:"include_patterns"`
 	ExcludePatterns         []string `json:"exclude_patterns"`
-	StatInterval            int      `json:"stat_interval"`
+	StatInterval            int      `json:"stat_interval"` // in seconds, used if neither schedule nor every is set
 	ConnectionRetryInterval int      `json:"connection_retry_interval,omitempty"`
 	LogMode                 Mode     `json:"log_mode,omitempty"`
 
-	sincemap
 	containerExist dockertool.StringExist
 	includes       []*regexp.Regexp
 	excludes       []*regexp.Regexp
 	hostname       string
 	client         *docker.Client
+	scheduler      *scheduler.Scheduler
 }
 
 // DefaultInputConfig returns an InputConfig struct with default values
@@ -48,7 +50,6 @@ func DefaultInputConfig() InputConfig {
 		ConnectionRetryInterval: 10,
 		LogMode:                 ModeFull,
 
-		sincemap:       newSinceMap(),
 		containerExist: dockertool.NewStringExist(),
 	}
 }
@@ -76,6 +77,9 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 	for _, pattern := range conf.ExcludePatterns {
 		conf.excludes = append(conf.excludes, regexp.MustCompile(pattern))
 	}
+	if conf.scheduler, err = scheduler.New(conf.Config, time.Duration(conf.StatInterval)*time.Second); err != nil {
+		return nil, err
+	}
 	if conf.hostname, err = os.Hostname(); err != nil {
 		return nil, err
 	}
@@ -104,12 +108,11 @@ func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEven
 			if !t.isValidContainer(container.Names) {
 				continue
 			}
-			since := t.sincemap.ensure(container.ID)
-			func(container interface{}, since *time.Time) {
+			func(container interface{}) {
 				eg.Go(func() error {
-					return t.containerLogLoop(ctx, container, since, msgChan)
+					return t.containerLogLoop(ctx, container, msgChan)
 				})
-			}(container, since)
+			}(container)
 		}
 
 		return nil
@@ -136,12 +139,11 @@ func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEven
 					if !t.isValidContainer([]string{container.Name}) {
 						return errutil.New("invalid container name " + container.Name)
 					}
-					since := t.sincemap.ensure(container.ID)
-					func(container interface{}, since *time.Time) {
+					func(container interface{}) {
 						eg.Go(func() error {
-							return t.containerLogLoop(ctx, container, since, msgChan)
+							return t.containerLogLoop(ctx, container, msgChan)
 						})
-					}(container, since)
+					}(container)
 				}
 			}
 		}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsouza/go-dockerclient"
@@ -15,7 +16,7 @@ var (
 	containerMap = map[string]interface{}{}
 )
 
-func (t *InputConfig) containerLogLoop(ctx context.Context, container interface{}, since *time.Time, msgChan chan<- logevent.LogEvent) (err error) {
+func (t *InputConfig) containerLogLoop(ctx context.Context, container interface{}, msgChan chan<- logevent.LogEvent) (err error) {
 	id, name, err := dockertool.GetContainerInfo(container)
 	if err != nil {
 		return ErrorGetContainerInfoFailed.New(err)
@@ -26,34 +27,45 @@ func (t *InputConfig) containerLogLoop(ctx context.Context, container interface{
 	t.containerExist.Add(id)
 	defer t.containerExist.Remove(id)
 
-	retry := 5
-	statsChan := make(chan *docker.Stats, 100)
+	// latest stats of stream, sent to msgChan on schedule
+	var (
+		latest *docker.Stats
+		mutex  sync.Mutex
+	)
+	loopCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go t.scheduler.Run(loopCtx, func(context.Context) {
+		mutex.Lock()
+		stats := latest
+		latest = nil
+		mutex.Unlock()
+		if stats == nil {
+			return
+		}
 
-	for err == nil || retry > 0 {
-		go func() {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case stats := <-statsChan:
-					if time.Now().Add(-time.Duration(float64(t.StatInterval)-0.5) * time.Second).Before(*since) {
-						continue
-					}
+		filterStatsByMode(stats, t.LogMode)
 
-					filterStatsByMode(stats, t.LogMode)
+		event := logevent.LogEvent{
+			Timestamp: time.Now(),
+			Extra: map[string]interface{}{
+				"host":          t.hostname,
+				"containerid":   id,
+				"containername": name,
+				"stats":         *stats,
+			},
+		}
+		msgChan <- event
+	})
 
-					event := logevent.LogEvent{
-						Timestamp: time.Now(),
-						Extra: map[string]interface{}{
-							"host":          t.hostname,
-							"containerid":   id,
-							"containername": name,
-							"stats":         *stats,
-						},
-					}
-					*since = time.Now()
-					msgChan <- event
-				}
+	retry := 5
+	for err == nil || retry > 0 {
+		// statsChan is closed by client.Stats
+		statsChan := make(chan *docker.Stats, 100)
+		go func() {
+			for stats := range statsChan {
+				mutex.Lock()
+				latest = stats
+				mutex.Unlock()
 			}
 		}()
 
@@ -0,0 +1,179 @@
+package inputelasticbulk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+)
+
+// bulk actions
+const (
+	ActionIndex  = "index"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// errors
+var (
+	ErrorMalformedAction2 = errutil.NewFactory("Malformed action/metadata line [%d], %s")
+	ErrorMissingSource1   = errutil.NewFactory("Validation Failed: 1: source is missing on line [%d];")
+)
+
+// bulkItem is an action and source of bulk request
+type bulkItem struct {
+	action   string
+	index    string
+	id       string
+	pipeline string
+	source   []byte
+	err      *itemError
+}
+
+// itemError is the error of bulk response item
+type itemError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	status int
+}
+
+type bulkActionMeta struct {
+	Index    string `json:"_index"`
+	ID       string `json:"_id"`
+	Pipeline string `json:"pipeline"`
+}
+
+// parseBulk parses NDJSON action and source line pairs, index is the default index of request path,
+// returns error if the request is malformed, errors of single items are set to item.err
+func parseBulk(body []byte, index string) ([]bulkItem, error) {
+	lines := bytes.Split(body, []byte("\n"))
+	items := []bulkItem{}
+	for lineNo := 0; lineNo < len(lines); lineNo++ {
+		line := bytes.TrimSpace(lines[lineNo])
+		if len(line) < 1 {
+			continue
+		}
+
+		action := map[string]bulkActionMeta{}
+		if err := json.Unmarshal(line, &action); err != nil {
+			return nil, ErrorMalformedAction2.New(nil, lineNo+1, "expected START_OBJECT")
+		}
+		if len(action) != 1 {
+			return nil, ErrorMalformedAction2.New(nil, lineNo+1, "expected one action")
+		}
+
+		item := bulkItem{}
+		for name, meta := range action {
+			item.action = name
+			item.index = meta.Index
+			item.id = meta.ID
+			item.pipeline = meta.Pipeline
+		}
+		if item.index == "" {
+			item.index = index
+		}
+
+		switch item.action {
+		case ActionIndex, ActionCreate, ActionUpdate:
+			lineNo++
+			if lineNo >= len(lines) || len(bytes.TrimSpace(lines[lineNo])) < 1 {
+				return nil, ErrorMissingSource1.New(nil, lineNo)
+			}
+			item.source = bytes.TrimSpace(lines[lineNo])
+		case ActionDelete:
+			item.err = &itemError{
+				Type:   "action_request_validation_exception",
+				Reason: "Validation Failed: 1: delete is not supported;",
+				status: 400,
+			}
+		default:
+			return nil, ErrorMalformedAction2.New(nil, lineNo+1, "unknown action ["+item.action+"]")
+		}
+
+		if item.err == nil {
+			item.err = item.validate()
+		}
+		if item.id == "" && item.action != ActionUpdate {
+			item.id = newDocumentID()
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// validate checks index and source of item, the document of update is the "doc" field
+func (t *bulkItem) validate() *itemError {
+	if t.index == "" {
+		return &itemError{
+			Type:   "action_request_validation_exception",
+			Reason: "Validation Failed: 1: index is missing;",
+			status: 400,
+		}
+	}
+
+	if t.action == ActionUpdate {
+		if t.id == "" {
+			return &itemError{
+				Type:   "action_request_validation_exception",
+				Reason: "Validation Failed: 1: id is missing;",
+				status: 400,
+			}
+		}
+		update := struct {
+			Doc json.RawMessage `json:"doc"`
+		}{}
+		if err := json.Unmarshal(t.source, &update); err != nil || len(update.Doc) < 1 {
+			return &itemError{
+				Type:   "action_request_validation_exception",
+				Reason: "Validation Failed: 1: doc is missing, only partial document update is supported;",
+				status: 400,
+			}
+		}
+		t.source = update.Doc
+	}
+
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(t.source, &object); err != nil {
+		return &itemError{
+			Type:   "mapper_parsing_exception",
+			Reason: "failed to parse: " + err.Error(),
+			status: 400,
+		}
+	}
+	return nil
+}
+
+// newDocumentID returns a random ID like auto generated IDs of elasticsearch
+func newDocumentID() string {
+	b := make([]byte, 15)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// bulkResponse is the response body of bulk request
+type bulkResponse struct {
+	Took   int64                         `json:"took"`
+	Errors bool                          `json:"errors"`
+	Items  []map[string]bulkResponseItem `json:"items"`
+}
+
+type bulkResponseItem struct {
+	Index       string          `json:"_index"`
+	ID          string          `json:"_id,omitempty"`
+	Version     int             `json:"_version,omitempty"`
+	Result      string          `json:"result,omitempty"`
+	Shards      *responseShards `json:"_shards,omitempty"`
+	SeqNo       *int64          `json:"_seq_no,omitempty"`
+	PrimaryTerm int             `json:"_primary_term,omitempty"`
+	Status      int             `json:"status"`
+	Error       *itemError      `json:"error,omitempty"`
+}
+
+type responseShards struct {
+	Total      int `json:"total"`
+	Successful int `json:"successful"`
+	Failed     int `json:"failed"`
+}
@@ -0,0 +1,306 @@
+package inputelasticbulk
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	codecjson "github.com/viethqc/gogstash/codec/json"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+	inputhttplisten "github.com/viethqc/gogstash/input/httplisten"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "elasticbulk"
+
+// MetadataField is the event field of bulk action metadata
+const MetadataField = "elasticsearch"
+
+// time to wait for the pipeline queue before answering 429
+const queueWait = time.Second
+
+// InputConfig holds the configuration json fields and internal objects
+type InputConfig struct {
+	config.InputConfig
+	Address           string `json:"address"` // host:port to listen on
+	ServerCert        string `json:"cert"`
+	ServerKey         string `json:"key"`
+	BasicAuthUsername string `json:"basic_auth_username"`
+	BasicAuthPassword string `json:"basic_auth_password"`
+	Version           string `json:"version"`       // elasticsearch version reported to clients
+	MaxBodySize       int64  `json:"max_body_size"` // maximum size of decompressed request body in bytes
+	RetryAfter        int    `json:"retry_after"`   // seconds of Retry-After header when the pipeline is busy
+
+	seqNo int64
+}
+
+// DefaultInputConfig returns an InputConfig struct with default values
+func DefaultInputConfig() InputConfig {
+	return InputConfig{
+		InputConfig: config.InputConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		Address:     "0.0.0.0:9200",
+		Version:     "7.10.2",
+		MaxBodySize: 104857600,
+		RetryAfter:  1,
+	}
+}
+
+// InitHandler initialize the input plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputConfig, error) {
+	conf := DefaultInputConfig()
+	err := config.ReflectConfig(raw, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	conf.Codec, err = config.GetCodecDefault(ctx, *raw, codecjson.ModuleName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conf, nil
+}
+
+// Start wraps the actual function starting the plugin
+func (i *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) (err error) {
+	logger := goglog.Logger
+	server := &http.Server{
+		Addr: i.Address,
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			i.serveHTTP(ctx, msgChan, rw, req)
+		}),
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		logger.Infof("accepting bulk requests on %s", i.Address)
+		if i.ServerCert != "" && i.ServerKey != "" {
+			errChan <- server.ListenAndServeTLS(i.ServerCert, i.ServerKey)
+		} else {
+			errChan <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		logger.Info("input elasticbulk stopped")
+		return nil
+	case err = <-errChan:
+		return err
+	}
+}
+
+func (i *InputConfig) serveHTTP(ctx context.Context, msgChan chan<- logevent.LogEvent, rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("X-Elastic-Product", "Elasticsearch")
+	if !i.authorize(req) {
+		rw.Header().Set("WWW-Authenticate", `Basic realm="security" charset="UTF-8"`)
+		writeError(rw, http.StatusUnauthorized, "security_exception", "missing or invalid authentication credentials")
+		return
+	}
+
+	path := strings.Trim(req.URL.Path, "/")
+	segments := strings.Split(path, "/")
+	switch {
+	case path == "" && (req.Method == http.MethodGet || req.Method == http.MethodHead):
+		i.serveInfo(rw)
+	case path == "_license" && req.Method == http.MethodGet:
+		i.serveLicense(rw)
+	case segments[len(segments)-1] == "_bulk" && len(segments) <= 3 &&
+		(req.Method == http.MethodPost || req.Method == http.MethodPut):
+		index := ""
+		if len(segments) > 1 {
+			index = segments[0]
+		}
+		i.serveBulk(ctx, msgChan, rw, req, index)
+	default:
+		writeJSON(rw, http.StatusBadRequest, map[string]interface{}{
+			"error":  fmt.Sprintf("no handler found for uri [%s] and method [%s]", req.URL.Path, req.Method),
+			"status": http.StatusBadRequest,
+		})
+	}
+}
+
+func (i *InputConfig) authorize(req *http.Request) bool {
+	if i.BasicAuthUsername == "" {
+		return true
+	}
+	username, password, ok := req.BasicAuth()
+	return ok && subtle.ConstantTimeCompare([]byte(username), []byte(i.BasicAuthUsername)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(i.BasicAuthPassword)) == 1
+}
+
+// serveInfo responds the cluster info of "GET /"
+func (i *InputConfig) serveInfo(rw http.ResponseWriter) {
+	hostname, _ := os.Hostname()
+	writeJSON(rw, http.StatusOK, map[string]interface{}{
+		"name":         hostname,
+		"cluster_name": "gogstash",
+		"cluster_uuid": "gogstash",
+		"version": map[string]interface{}{
+			"number":                              i.Version,
+			"build_flavor":                        "default",
+			"build_type":                          "gogstash",
+			"build_hash":                          "gogstash",
+			"build_snapshot":                      false,
+			"lucene_version":                      "8.7.0",
+			"minimum_wire_compatibility_version":  "6.8.0",
+			"minimum_index_compatibility_version": "6.0.0-beta1",
+		},
+		"tagline": "You Know, for Search",
+	})
+}
+
+// serveLicense responds an active basic license of "GET /_license"
+func (i *InputConfig) serveLicense(rw http.ResponseWriter) {
+	writeJSON(rw, http.StatusOK, map[string]interface{}{
+		"license": map[string]interface{}{
+			"status":               "active",
+			"uid":                  "gogstash",
+			"type":                 "basic",
+			"issue_date":           "2021-01-01T00:00:00.000Z",
+			"issue_date_in_millis": 1609459200000,
+			"max_nodes":            1000,
+			"issued_to":            "gogstash",
+			"issuer":               "gogstash",
+			"start_date_in_millis": -1,
+		},
+	})
+}
+
+// serveBulk sends documents of bulk request to msgChan
+func (i *InputConfig) serveBulk(ctx context.Context, msgChan chan<- logevent.LogEvent, rw http.ResponseWriter, req *http.Request, index string) {
+	logger := goglog.Logger
+	start := time.Now()
+
+	if config.GetMutexInstance().GetPause() {
+		i.retryLater(rw, http.StatusServiceUnavailable, "cluster_block_exception", "pipeline is paused")
+		return
+	}
+
+	body, status, err := inputhttplisten.ReadBody(req, i.MaxBodySize)
+	if err != nil {
+		writeError(rw, status, "parse_exception", err.Error())
+		return
+	}
+	items, err := parseBulk(body, index)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, "illegal_argument_exception", err.Error())
+		return
+	}
+
+	events := make([]logevent.LogEvent, 0, len(items))
+	for j := range items {
+		item := &items[j]
+		if item.err != nil {
+			continue
+		}
+		meta := map[string]interface{}{
+			"action": item.action,
+			"index":  item.index,
+			"id":     item.id,
+		}
+		if item.pipeline != "" {
+			meta["pipeline"] = item.pipeline
+		}
+		decoded, err := config.DecodeEvents(ctx, i.Codec, item.source, map[string]interface{}{MetadataField: meta})
+		if err != nil || len(decoded) < 1 {
+			// the document is answered as failed, none of its events are sent
+			reason := "failed to parse: no event decoded"
+			if err != nil {
+				logger.Errorf("decode bulk document error: %v", err)
+				reason = "failed to parse: " + err.Error()
+			}
+			item.err = &itemError{
+				Type:   "mapper_parsing_exception",
+				Reason: reason,
+				status: http.StatusBadRequest,
+			}
+			continue
+		}
+		events = append(events, decoded...)
+	}
+
+	// none of documents are accepted if the queue stays full, so the client can retry the whole request
+	if !config.SendEvents(ctx, msgChan, events, queueWait) {
+		i.retryLater(rw, http.StatusTooManyRequests, "es_rejected_execution_exception", "pipeline queue is full")
+		return
+	}
+
+	resp := bulkResponse{Items: make([]map[string]bulkResponseItem, 0, len(items))}
+	for _, item := range items {
+		respItem := bulkResponseItem{
+			Index: item.index,
+			ID:    item.id,
+		}
+		if item.err != nil {
+			resp.Errors = true
+			respItem.Status = item.err.status
+			respItem.Error = item.err
+		} else {
+			seqNo := atomic.AddInt64(&i.seqNo, 1) - 1
+			respItem.Version = 1
+			respItem.Result = "created"
+			respItem.Status = http.StatusCreated
+			if item.action == ActionUpdate {
+				respItem.Result = "updated"
+				respItem.Status = http.StatusOK
+			}
+			respItem.Shards = &responseShards{Total: 1, Successful: 1}
+			respItem.SeqNo = &seqNo
+			respItem.PrimaryTerm = 1
+		}
+		resp.Items = append(resp.Items, map[string]bulkResponseItem{item.action: respItem})
+	}
+	resp.Took = int64(time.Since(start) / time.Millisecond)
+	writeJSON(rw, http.StatusOK, resp)
+}
+
+func (i *InputConfig) retryLater(rw http.ResponseWriter, status int, errorType string, reason string) {
+	goglog.Logger.Warnf("input elasticbulk: %s", reason)
+	rw.Header().Set("Retry-After", fmt.Sprint(i.RetryAfter))
+	writeError(rw, status, errorType, reason)
+}
+
+// writeError responds error in elasticsearch format
+func writeError(rw http.ResponseWriter, status int, errorType string, reason string) {
+	cause := map[string]interface{}{
+		"type":   errorType,
+		"reason": reason,
+	}
+	writeJSON(rw, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"root_cause": []interface{}{cause},
+			"type":       errorType,
+			"reason":     reason,
+		},
+		"status": status,
+	})
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		goglog.Logger.Errorf("input elasticbulk: marshal response error: %v", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	rw.WriteHeader(status)
+	rw.Write(bytes.TrimSpace(data))
+}
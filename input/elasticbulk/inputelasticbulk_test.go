@@ -0,0 +1,320 @@
+package inputelasticbulk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	codecjson "github.com/viethqc/gogstash/codec/json"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+	config.RegistInputHandler(ModuleName, InitHandler)
+	config.RegistCodecHandler(codecjson.ModuleName, codecjson.InitHandler)
+}
+
+func Test_input_elasticbulk_parseBulk(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	items, err := parseBulk([]byte(strings.Join([]string{
+		`{"index":{"_index":"logs","_id":"1","pipeline":"p"}}`,
+		`{"message":"a"}`,
+		`{"create":{}}`,
+		`{"message":"b"}`,
+		`{"update":{"_id":"2"}}`,
+		`{"doc":{"message":"c"}}`,
+		`{"delete":{"_id":"3"}}`,
+		`{"index":{}}`,
+		`not json`,
+		``,
+	}, "\n")), "default")
+	require.NoError(err)
+	require.Len(items, 5)
+
+	assert.Equal(ActionIndex, items[0].action)
+	assert.Equal("logs", items[0].index)
+	assert.Equal("1", items[0].id)
+	assert.Equal("p", items[0].pipeline)
+	assert.Nil(items[0].err)
+
+	assert.Equal("default", items[1].index)
+	assert.Len(items[1].id, 20)
+	assert.Nil(items[1].err)
+
+	assert.Equal("2", items[2].id)
+	assert.Equal(`{"message":"c"}`, string(items[2].source))
+	assert.Nil(items[2].err)
+
+	if assert.NotNil(items[3].err) {
+		assert.Equal(http.StatusBadRequest, items[3].err.status)
+	}
+	if assert.NotNil(items[4].err) {
+		assert.Equal("mapper_parsing_exception", items[4].err.Type)
+	}
+
+	_, err = parseBulk([]byte("{\"index\":{}}\n"), "logs")
+	assert.True(ErrorMissingSource1.Match(err))
+	_, err = parseBulk([]byte("{\"index\":{}\n{}\n"), "logs")
+	assert.True(ErrorMalformedAction2.Match(err))
+	_, err = parseBulk([]byte("{\"upsert\":{}}\n{}\n"), "logs")
+	assert.True(ErrorMalformedAction2.Match(err))
+
+	items, err = parseBulk([]byte("{\"index\":{}}\n{}\n"), "")
+	require.NoError(err)
+	if assert.Len(items, 1) && assert.NotNil(items[0].err) {
+		assert.Equal("action_request_validation_exception", items[0].err.Type)
+	}
+}
+
+func Test_input_elasticbulk_module(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conf, err := config.LoadFromYAML([]byte(strings.TrimSpace(`
+input:
+  - type: elasticbulk
+    address: "127.0.0.1:8091"
+    basic_auth_username: "user"
+    basic_auth_password: "pass"
+    retry_after: 5
+	`)))
+	require.NoError(err)
+	input, err := InitHandler(ctx, &conf.InputRaw[0])
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 3)
+	done := make(chan error, 1)
+	go func() {
+		done <- input.Start(ctx, msgChan)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	request := func(method string, path string, body []byte, headers map[string]string) (*http.Response, map[string]interface{}) {
+		req, err := http.NewRequest(method, "http://127.0.0.1:8091"+path, bytes.NewReader(body))
+		require.NoError(err)
+		req.SetBasicAuth("user", "pass")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(err)
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		require.NoError(err)
+		result := map[string]interface{}{}
+		if len(data) > 0 {
+			require.NoError(json.Unmarshal(data, &result))
+		}
+		return resp, result
+	}
+
+	// client handshake
+	resp, result := request(http.MethodGet, "/", nil, nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("Elasticsearch", resp.Header.Get("X-Elastic-Product"))
+	assert.Equal("You Know, for Search", result["tagline"])
+	if version, ok := result["version"].(map[string]interface{}); assert.True(ok) {
+		assert.Equal("7.10.2", version["number"])
+	}
+	resp, result = request(http.MethodGet, "/_license", nil, nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	if license, ok := result["license"].(map[string]interface{}); assert.True(ok) {
+		assert.Equal("active", license["status"])
+	}
+
+	// bulk with per-item errors
+	body := strings.Join([]string{
+		`{"index":{"_id":"1"}}`,
+		`{"message":"a","@timestamp":"2021-01-02T03:04:05Z"}`,
+		`{"create":{"_index":"other","pipeline":"p"}}`,
+		`{"message":"b"}`,
+		`{"delete":{"_id":"1"}}`,
+		``,
+	}, "\n")
+	resp, result = request(http.MethodPost, "/logs/_bulk", []byte(body), map[string]string{"Content-Type": "application/x-ndjson"})
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(true, result["errors"])
+	if items, ok := result["items"].([]interface{}); assert.True(ok) && assert.Len(items, 3) {
+		item := items[0].(map[string]interface{})["index"].(map[string]interface{})
+		assert.Equal("logs", item["_index"])
+		assert.Equal("1", item["_id"])
+		assert.Equal("created", item["result"])
+		assert.EqualValues(http.StatusCreated, item["status"])
+		item = items[1].(map[string]interface{})["create"].(map[string]interface{})
+		assert.Equal("other", item["_index"])
+		assert.NotEmpty(item["_id"])
+		item = items[2].(map[string]interface{})["delete"].(map[string]interface{})
+		assert.EqualValues(http.StatusBadRequest, item["status"])
+		assert.Contains(item, "error")
+	}
+
+	event := <-msgChan
+	assert.Equal("a", event.Message)
+	assert.Equal(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), event.Timestamp.UTC())
+	assert.Equal(map[string]interface{}{"action": "index", "index": "logs", "id": "1"}, event.Extra[MetadataField])
+	event = <-msgChan
+	assert.Equal("b", event.Message)
+	assert.Equal("p", event.GetString(MetadataField+".pipeline"))
+
+	// gzip body
+	gzBody := bytes.Buffer{}
+	gz := gzip.NewWriter(&gzBody)
+	gz.Write([]byte("{\"index\":{\"_index\":\"logs\"}}\n{\"message\":\"c\"}\n"))
+	gz.Close()
+	resp, result = request(http.MethodPut, "/_bulk", gzBody.Bytes(), map[string]string{"Content-Encoding": "gzip"})
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(false, result["errors"])
+	assert.Equal("c", (<-msgChan).Message)
+
+	// malformed request
+	resp, result = request(http.MethodPost, "/_bulk", []byte("{\"index\":{}}\n"), nil)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(result, "error")
+
+	// unknown endpoint
+	resp, _ = request(http.MethodGet, "/logs/_search", nil, nil)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	// queue full, none of documents accepted
+	for j := 0; j < cap(msgChan); j++ {
+		msgChan <- logevent.LogEvent{}
+	}
+	resp, result = request(http.MethodPost, "/logs/_bulk", []byte("{\"index\":{}}\n{\"message\":\"d\"}\n{\"index\":{}}\n{\"message\":\"e\"}\n"), nil)
+	assert.Equal(http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal("5", resp.Header.Get("Retry-After"))
+	if errBody, ok := result["error"].(map[string]interface{}); assert.True(ok) {
+		assert.Equal("es_rejected_execution_exception", errBody["type"])
+	}
+	assert.Len(msgChan, cap(msgChan))
+	for j := 0; j < cap(msgChan); j++ {
+		<-msgChan
+	}
+
+	// more documents than the queue capacity are accepted while the queue drains
+	bulk := bytes.Buffer{}
+	for j := 0; j < 10; j++ {
+		bulk.WriteString(fmt.Sprintf("{\"index\":{}}\n{\"message\":\"m%d\"}\n", j))
+	}
+	received := make(chan []string, 1)
+	go func() {
+		messages := []string{}
+		for j := 0; j < 10; j++ {
+			messages = append(messages, (<-msgChan).Message)
+		}
+		received <- messages
+	}()
+	resp, result = request(http.MethodPost, "/logs/_bulk", bulk.Bytes(), nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(false, result["errors"])
+	assert.Equal([]string{"m0", "m1", "m2", "m3", "m4", "m5", "m6", "m7", "m8", "m9"}, <-received)
+
+	// authentication
+	req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:8091/", nil)
+	require.NoError(err)
+	req.SetBasicAuth("user", "wrong")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	cancel()
+	select {
+	case err = <-done:
+		assert.NoError(err)
+	case <-time.After(2 * time.Second):
+		assert.Fail("input not stopped")
+	}
+}
+
+// rejectCodec decodes JSON documents, failing documents with field "reject" and dropping ones with field "drop"
+type rejectCodec struct {
+	codecjson.Codec
+}
+
+func (c *rejectCodec) Decode(ctx context.Context, data interface{}, extra map[string]interface{}, msgChan chan<- logevent.LogEvent) (ok bool, err error) {
+	text := string(data.([]byte))
+	switch {
+	case strings.Contains(text, `"reject"`):
+		return false, config.ErrDecodeData
+	case strings.Contains(text, `"drop"`):
+		return false, nil
+	}
+	return c.Codec.Decode(ctx, data, extra, msgChan)
+}
+
+func Test_input_elasticbulk_module_decode_error(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conf, err := config.LoadFromYAML([]byte(strings.TrimSpace(`
+input:
+  - type: elasticbulk
+    address: "127.0.0.1:8092"
+	`)))
+	require.NoError(err)
+	input, err := InitHandler(ctx, &conf.InputRaw[0])
+	require.NoError(err)
+	input.(*InputConfig).Codec = &rejectCodec{}
+
+	msgChan := make(chan logevent.LogEvent, 3)
+	go input.Start(ctx, msgChan)
+	time.Sleep(200 * time.Millisecond)
+
+	body := strings.Join([]string{
+		`{"index":{}}`,
+		`{"reject":true}`,
+		`{"index":{}}`,
+		`{"drop":true}`,
+		`{"index":{}}`,
+		`{"message":"a"}`,
+		``,
+	}, "\n")
+	resp, err := http.Post("http://127.0.0.1:8092/logs/_bulk", "application/x-ndjson", strings.NewReader(body))
+	require.NoError(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	result := map[string]interface{}{}
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(true, result["errors"])
+	if items, ok := result["items"].([]interface{}); assert.True(ok) && assert.Len(items, 3) {
+		for _, j := range []int{0, 1} {
+			item := items[j].(map[string]interface{})["index"].(map[string]interface{})
+			assert.EqualValues(http.StatusBadRequest, item["status"])
+			assert.NotContains(item, "result")
+			if itemErr, ok := item["error"].(map[string]interface{}); assert.True(ok) {
+				assert.Equal("mapper_parsing_exception", itemErr["type"])
+			}
+		}
+		item := items[2].(map[string]interface{})["index"].(map[string]interface{})
+		assert.EqualValues(http.StatusCreated, item["status"])
+		assert.Equal("created", item["result"])
+	}
+
+	assert.Equal("a", (<-msgChan).Message)
+	assert.Len(msgChan, 0)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/viethqc/gogstash/KDGoLib/errutil"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/logevent"
+	"github.com/viethqc/gogstash/config/scheduler"
 )
 
 // ModuleName is the name used in config file
@@ -20,17 +21,35 @@ const ModuleName = "exec"
 // ErrorTag tag added to event when process module failed
 const ErrorTag = "gogstash_input_exec_error"
 
+// modes of running command
+const (
+	ModeInterval = "interval"
+	ModeStream   = "stream"
+)
+
+// event fields
+const (
+	ExitCodeField = "exit_code"
+	DurationField = "duration_ms"
+	StreamField   = "stream"
+)
+
 // InputConfig holds the configuration json fields and internal objects
 type InputConfig struct {
 	config.InputConfig
-	Command   string   `json:"command"`                  // Command to run. e.g. “uptime”
-	Args      []string `json:"args,omitempty"`           // Arguments of command
-	Interval  int      `json:"interval,omitempty"`       // Second, default: 60
-	MsgTrim   string   `json:"message_trim,omitempty"`   // default: " \t\r\n"
-	MsgPrefix string   `json:"message_prefix,omitempty"` // only in text type, e.g. "%{@timestamp} [uptime] "
-	MsgType   MsgType  `json:"message_type,omitempty"`   // default: "text"
-
-	hostname string
+	scheduler.Config
+	Command         string   `json:"command"`                  // Command to run. e.g. “uptime”
+	Args            []string `json:"args,omitempty"`           // Arguments of command
+	Mode            string   `json:"mode,omitempty"`           // one of ["interval", "stream"], default: "interval"
+	Interval        int      `json:"interval,omitempty"`       // Second, default: 60, used if neither schedule nor every is set
+	MsgTrim         string   `json:"message_trim,omitempty"`   // default: " \t\r\n"
+	MsgPrefix       string   `json:"message_prefix,omitempty"` // only in text type, e.g. "%{@timestamp} [uptime] "
+	MsgType         MsgType  `json:"message_type,omitempty"`   // default: "text"
+	RestartDelay    int      `json:"restart_delay"`            // stream mode, initial delay in seconds to restart, doubled on each exit
+	RestartMaxDelay int      `json:"restart_max_delay"`        // stream mode, maximum delay in seconds to restart
+
+	hostname  string
+	scheduler *scheduler.Scheduler
 }
 
 // DefaultInputConfig returns an InputConfig struct with default values
@@ -41,15 +60,19 @@ func DefaultInputConfig() InputConfig {
 				Type: ModuleName,
 			},
 		},
-		Interval: 60,
-		MsgTrim:  " \t\r\n",
-		MsgType:  MsgTypeText,
+		Mode:            ModeInterval,
+		Interval:        60,
+		MsgTrim:         " \t\r\n",
+		MsgType:         MsgTypeText,
+		RestartDelay:    1,
+		RestartMaxDelay: 60,
 	}
 }
 
 // errors
 var (
 	ErrorExecCommandFailed1 = errutil.NewFactory("run exec failed: %q")
+	ErrorUnknownMode1       = errutil.NewFactory("unknown mode %q")
 )
 
 // InitHandler initialize the input plugin
@@ -64,38 +87,51 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 		return nil, err
 	}
 
+	switch conf.Mode {
+	case ModeInterval:
+		if conf.scheduler, err = scheduler.New(conf.Config, time.Duration(conf.Interval)*time.Second); err != nil {
+			return nil, err
+		}
+	case ModeStream:
+		if conf.RestartDelay < 1 {
+			conf.RestartDelay = 1
+		}
+		if conf.Codec, err = config.GetCodec(ctx, *raw); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrorUnknownMode1.New(nil, conf.Mode)
+	}
+
 	return &conf, nil
 }
 
 // Start wraps the actual function starting the plugin
 func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) (err error) {
-	startChan := make(chan bool, 1) // startup tick
-	ticker := time.NewTicker(time.Duration(t.Interval) * time.Second)
-	defer ticker.Stop()
-
-	startChan <- true
-
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case <-startChan:
-			t.exec(msgChan)
-		case <-ticker.C:
-			t.exec(msgChan)
-		}
+	if t.Mode == ModeStream {
+		return t.startStream(ctx, msgChan)
 	}
+
+	t.scheduler.Run(ctx, func(context.Context) {
+		t.exec(msgChan)
+	})
+	return nil
 }
 
 func (t *InputConfig) exec(msgChan chan<- logevent.LogEvent) {
 	errs := []error{}
 
-	message, err := t.doExecCommand()
+	start := time.Now()
+	message, exitCode, err := t.doExecCommand()
 	if err != nil {
 		errs = append(errs, err)
 	}
 	extra := map[string]interface{}{
-		"host": t.hostname,
+		"host":        t.hostname,
+		DurationField: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if exitCode >= 0 {
+		extra[ExitCodeField] = exitCode
 	}
 
 	switch t.MsgType {
@@ -128,12 +164,17 @@ func (t *InputConfig) exec(msgChan chan<- logevent.LogEvent) {
 	return
 }
 
-func (t *InputConfig) doExecCommand() (data string, err error) {
+// doExecCommand returns trimmed stdout and exit code of command, exit code is -1 if the command did not exit
+func (t *InputConfig) doExecCommand() (data string, exitCode int, err error) {
 	buferr := &bytes.Buffer{}
 	cmd := exec.Command(t.Command, t.Args...)
 	cmd.Stderr = buferr
 
 	raw, err := cmd.Output()
+	exitCode = -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
 	if err != nil {
 		return
 	}
@@ -11,11 +11,13 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
 )
 
 func init() {
 	goglog.Logger.SetLevel(logrus.DebugLevel)
 	config.RegistInputHandler(ModuleName, InitHandler)
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
 }
 
 func Test_input_exec_module(t *testing.T) {
@@ -97,3 +99,90 @@ input:
 		require.Equal(map[string]interface{}{"data": "text in child"}, event.Extra["child"])
 	}
 }
+
+func Test_input_exec_module_exit_code(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input, err := InitHandler(ctx, &config.ConfigRaw{
+		"type":    ModuleName,
+		"command": "sh",
+		"args":    []string{"-c", "sleep 0.1; echo done; exit 2"},
+	})
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 1)
+	go input.Start(ctx, msgChan)
+
+	select {
+	case event := <-msgChan:
+		assert.Equal(2, event.Extra[ExitCodeField])
+		assert.True(event.Extra[DurationField].(float64) >= 100)
+		assert.Contains(event.Tags, ErrorTag)
+	case <-time.After(2 * time.Second):
+		require.FailNow("timeout waiting for event")
+	}
+}
+
+func Test_input_exec_module_stream(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input, err := InitHandler(ctx, &config.ConfigRaw{
+		"type":          ModuleName,
+		"mode":          ModeStream,
+		"command":       "sh",
+		"args":          []string{"-c", "echo out1; echo err1 >&2; echo out2; exit 1"},
+		"restart_delay": 1,
+	})
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		done <- input.Start(ctx, msgChan)
+	}()
+
+	receive := func() map[string][]string {
+		messages := map[string][]string{}
+		for i := 0; i < 3; i++ {
+			select {
+			case event := <-msgChan:
+				stream := event.GetString(StreamField)
+				messages[stream] = append(messages[stream], event.Message)
+			case <-time.After(3 * time.Second):
+				require.FailNow("timeout waiting for event")
+			}
+		}
+		return messages
+	}
+	expected := map[string][]string{
+		StreamStdout: {"out1", "out2"},
+		StreamStderr: {"err1"},
+	}
+	assert.Equal(expected, receive())
+
+	// restarted after restart_delay
+	assert.Equal(expected, receive())
+	assert.True(time.Since(start) >= time.Second)
+
+	cancel()
+	select {
+	case err = <-done:
+		assert.NoError(err)
+	case <-time.After(3 * time.Second):
+		assert.Fail("input not stopped")
+	}
+
+	_, err = InitHandler(context.Background(), &config.ConfigRaw{"type": ModuleName, "command": "true", "mode": "daemon"})
+	assert.True(ErrorUnknownMode1.Match(err))
+}
@@ -0,0 +1,110 @@
+package inputexec
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// stream names of event field "stream"
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// startStream runs the command until ctx done, restarts it with backoff when it exits
+func (t *InputConfig) startStream(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
+	logger := goglog.Logger
+
+	delay := time.Duration(t.RestartDelay) * time.Second
+	maxDelay := time.Duration(t.RestartMaxDelay) * time.Second
+	for {
+		start := time.Now()
+		err := t.runStream(ctx, msgChan)
+		select {
+		case <-ctx.Done():
+			logger.Info("input exec stopped")
+			return nil
+		default:
+		}
+
+		// process ran long enough is considered healthy
+		if maxDelay > 0 && time.Since(start) >= maxDelay {
+			delay = time.Duration(t.RestartDelay) * time.Second
+		}
+		if err != nil {
+			logger.Warnf("input exec: command %q exited: %v, restart in %v", t.Command, err, delay)
+		} else {
+			logger.Warnf("input exec: command %q exited, restart in %v", t.Command, delay)
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info("input exec stopped")
+			return nil
+		case <-time.After(delay):
+		}
+		if delay *= 2; maxDelay > 0 && delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// runStream runs the command once, decodes each line of stdout and stderr
+func (t *InputConfig) runStream(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
+	cmd := exec.CommandContext(ctx, t.Command, t.Args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		t.readLines(ctx, stdout, StreamStdout, msgChan)
+	}()
+	go func() {
+		defer wg.Done()
+		t.readLines(ctx, stderr, StreamStderr, msgChan)
+	}()
+	// pipes must be read to the end before Wait
+	wg.Wait()
+
+	return cmd.Wait()
+}
+
+func (t *InputConfig) readLines(ctx context.Context, r io.Reader, stream string, msgChan chan<- logevent.LogEvent) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			extra := map[string]interface{}{
+				"host":      t.hostname,
+				StreamField: stream,
+			}
+			if _, decodeErr := t.Codec.Decode(ctx, line, extra, msgChan); decodeErr != nil {
+				goglog.Logger.Errorf("input exec: decode %s error: %v", stream, decodeErr)
+			}
+		}
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				goglog.Logger.Warnf("input exec: read %s error: %v", stream, err)
+			}
+			return
+		}
+	}
+}
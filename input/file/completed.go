@@ -0,0 +1,164 @@
+package inputfile
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// actions applied to files completed in read mode, combined with "_and_", e.g. "log_and_delete"
+const (
+	CompletedActionDelete  = "delete"
+	CompletedActionLog     = "log"
+	CompletedActionArchive = "archive"
+)
+
+// errors
+var (
+	ErrorInvalidCompletedAction1 = errutil.NewFactory("invalid file_completed_action: %q")
+	ErrorCompletedLogPathEmpty   = errutil.NewFactory("file_completed_log_path is required for file_completed_action log")
+	ErrorArchiveDirEmpty         = errutil.NewFactory("file_completed_archive_dir is required for file_completed_action archive")
+)
+
+func (t *InputConfig) completedActions() []string {
+	return strings.Split(t.FileCompletedAction, "_and_")
+}
+
+func (t *InputConfig) checkCompletedAction() error {
+	actions := t.completedActions()
+	if len(actions) > 2 || (len(actions) == 2 && actions[0] != CompletedActionLog) {
+		return ErrorInvalidCompletedAction1.New(nil, t.FileCompletedAction)
+	}
+	for _, action := range actions {
+		switch action {
+		case CompletedActionDelete:
+		case CompletedActionLog:
+			if t.FileCompletedLogPath == "" {
+				return ErrorCompletedLogPathEmpty.New(nil)
+			}
+		case CompletedActionArchive:
+			if t.FileCompletedArchiveDir == "" {
+				return ErrorArchiveDirEmpty.New(nil)
+			}
+		default:
+			return ErrorInvalidCompletedAction1.New(nil, t.FileCompletedAction)
+		}
+	}
+	return nil
+}
+
+// readFile reads the whole file in read mode, and applies file_completed_action when done
+func (t *InputConfig) readFile(ctx context.Context, fpath string, key string, msgChan chan<- logevent.LogEvent) (err error) {
+	compression, err := detectFileCompression(fpath)
+	if err != nil {
+		return
+	}
+
+	since, done, err := t.readFileOnce(ctx, fpath, compression, false, msgChan)
+	if err != nil {
+		return
+	}
+	if since != nil && since.Completed {
+		// completed before restart
+		t.markCompleted(key)
+		return nil
+	}
+	if !done {
+		return nil
+	}
+
+	t.sinceDBMutex.Lock()
+	since.Completed = true
+	since.LastActive = time.Now()
+	t.sinceDBMutex.Unlock()
+	// record completion before the action, a file is never read twice even if the action fails
+	if err = t.SaveSinceDBInfos(); err != nil {
+		return
+	}
+	t.markCompleted(key)
+
+	return t.applyCompletedAction(fpath, key)
+}
+
+func (t *InputConfig) applyCompletedAction(fpath string, key string) (err error) {
+	logger := goglog.Logger
+	for _, action := range t.completedActions() {
+		switch action {
+		case CompletedActionLog:
+			err = appendLine(t.FileCompletedLogPath, fpath)
+		case CompletedActionDelete:
+			logger.Infof("Deleting completed file: %q", fpath)
+			if err = os.Remove(fpath); err == nil {
+				err = t.forgetCompleted(key)
+			}
+		case CompletedActionArchive:
+			target := filepath.Join(t.FileCompletedArchiveDir, filepath.Base(fpath))
+			logger.Infof("Archiving completed file: %q to %q", fpath, target)
+			if err = moveFile(fpath, target); err == nil {
+				err = t.forgetCompleted(key)
+			}
+		}
+		if err != nil {
+			return errutil.New("file_completed_action "+action+" failed: "+fpath, err)
+		}
+	}
+	return nil
+}
+
+// forgetCompleted forgets a completed file removed from its path and saves sincedb,
+// the inode may be reused by a new file starting with the same content
+func (t *InputConfig) forgetCompleted(key string) error {
+	t.readersMutex.Lock()
+	delete(t.completed, key)
+	t.readersMutex.Unlock()
+
+	t.deleteSinceDBInfo(key)
+	return t.SaveSinceDBInfos()
+}
+
+func appendLine(fpath string, line string) (err error) {
+	fp, err := os.OpenFile(fpath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	if _, err = fp.WriteString(line + "\n"); err != nil {
+		fp.Close()
+		return
+	}
+	return fp.Close()
+}
+
+// moveFile renames src to dst, or copies and removes src across file systems
+func moveFile(src string, dst string) (err error) {
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return
+	}
+	if err = os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return
+	}
+	if err = out.Close(); err != nil {
+		return
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,17 @@
+// +build !windows
+
+package inputfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode number of file
+func fileIdentity(fi os.FileInfo) (device uint64, inode uint64, ok bool) {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(stat.Dev), uint64(stat.Ino), true
+}
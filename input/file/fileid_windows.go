@@ -0,0 +1,10 @@
+// +build windows
+
+package inputfile
+
+import "os"
+
+// fileIdentity is not supported on windows, files are identified by path and fingerprint
+func fileIdentity(fi os.FileInfo) (device uint64, inode uint64, ok bool) {
+	return 0, 0, false
+}
@@ -4,15 +4,18 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/fsnotify/fsnotify"
 	"github.com/viethqc/gogstash/KDGoLib/errutil"
-	"github.com/viethqc/gogstash/KDGoLib/futil"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/goglog"
 	"github.com/viethqc/gogstash/config/logevent"
@@ -22,18 +25,56 @@ import (
 // ModuleName is the name used in config file
 const ModuleName = "file"
 
+// modes of reading files
+const (
+	ModeTail = "tail" // follow files for new lines
+	ModeRead = "read" // read whole files once, then apply file_completed_action
+)
+
 // InputConfig holds the configuration json fields and internal objects
 type InputConfig struct {
 	config.InputConfig
-	Path                 string `json:"path"`
-	StartPos             string `json:"start_position,omitempty"` // one of ["beginning", "end"]
-	SinceDBPath          string `json:"sincedb_path,omitempty"`
-	SinceDBWriteInterval int    `json:"sincedb_write_interval,omitempty"`
+	Mode                 string   `json:"mode,omitempty"`           // one of ["tail", "read"]
+	Path                 PathList `json:"path"`                     // glob patterns, support "**" to match directories recursively
+	Exclude              []string `json:"exclude,omitempty"`        // glob patterns of files to skip
+	StartPos             string   `json:"start_position,omitempty"` // one of ["beginning", "end"]
+	SinceDBPath          string   `json:"sincedb_path,omitempty"`
+	SinceDBWriteInterval int      `json:"sincedb_write_interval,omitempty"`
+	SinceDBCleanAfter    string   `json:"sincedb_clean_after,omitempty"` // duration, entries of files inactive longer are removed, "0" to disable
+	DiscoverInterval     int      `json:"discover_interval,omitempty"`   // in seconds, <= 0 disables periodic discovery
+	CloseInactive        int      `json:"close_inactive,omitempty"`      // in seconds, <= 0 never closes idle files
+	MaxOpenFiles         int      `json:"max_open_files,omitempty"`      // <= 0 for no limit
+	KeepSymlinkPath      bool     `json:"keep_symlink_path,omitempty"`   // report the matched path of symlinks instead of the target
+
+	// read mode only
+	FileCompletedAction     string `json:"file_completed_action,omitempty"` // one of ["delete", "log", "archive", "log_and_delete", "log_and_archive"]
+	FileCompletedLogPath    string `json:"file_completed_log_path,omitempty"`
+	FileCompletedArchiveDir string `json:"file_completed_archive_dir,omitempty"`
 
 	hostname            string
 	SinceDBInfos        map[string]*SinceDBInfo `json:"-"`
+	sinceDBMutex        sync.Mutex
 	sinceDBLastInfosRaw []byte
 	SinceDBLastSaveTime time.Time `json:"-"`
+	sinceDBCleanAfter   time.Duration
+
+	readers      map[string]*fileReader
+	completed    map[string]bool // sincedb keys of files which are read once, compressed files or in read mode
+	readingKeys  map[string]bool // sincedb keys of files being read
+	watchedDirs  map[string]bool
+	linkPaths    map[string]string // matched symlink path of resolved path, for keep_symlink_path
+	readersMutex sync.Mutex
+	watcher      *fsnotify.Watcher
+	discoverChan chan struct{}
+}
+
+// fileReader is the state of a file being read
+type fileReader struct {
+	fpath      string
+	events     chan fsnotify.Event
+	cancel     context.CancelFunc
+	idle       bool // waiting for new data at EOF
+	lastActive time.Time
 }
 
 // DefaultInputConfig returns an InputConfig struct with default values
@@ -44,9 +85,15 @@ func DefaultInputConfig() InputConfig {
 				Type: ModuleName,
 			},
 		},
+		Mode:                 ModeTail,
 		StartPos:             "end",
 		SinceDBPath:          ".sincedb.json",
 		SinceDBWriteInterval: 15,
+		SinceDBCleanAfter:    "336h",
+		DiscoverInterval:     15,
+		CloseInactive:        300,
+		MaxOpenFiles:         1024,
+		FileCompletedAction:  CompletedActionDelete,
 
 		SinceDBInfos: map[string]*SinceDBInfo{},
 	}
@@ -54,7 +101,10 @@ func DefaultInputConfig() InputConfig {
 
 // errors
 var (
-	ErrorGlobFailed1 = errutil.NewFactory("glob(%q) failed")
+	ErrorGlobFailed1         = errutil.NewFactory("glob(%q) failed")
+	ErrorCreateWatcher       = errutil.NewFactory("create file watcher failed")
+	ErrorInvalidExcludeGlob1 = errutil.NewFactory("invalid exclude pattern: %q")
+	ErrorInvalidMode1        = errutil.NewFactory("invalid mode: %q")
 )
 
 // InitHandler initialize the input plugin
@@ -65,6 +115,26 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 		return nil, err
 	}
 
+	if conf.sinceDBCleanAfter, err = time.ParseDuration(conf.SinceDBCleanAfter); err != nil {
+		return nil, err
+	}
+
+	switch conf.Mode {
+	case ModeTail:
+	case ModeRead:
+		if err = conf.checkCompletedAction(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrorInvalidMode1.New(nil, conf.Mode)
+	}
+
+	for _, pattern := range conf.Exclude {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, ErrorInvalidExcludeGlob1.New(nil, pattern)
+		}
+	}
+
 	if conf.hostname, err = os.Hostname(); err != nil {
 		return nil, err
 	}
@@ -79,16 +149,20 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 
 // Start wraps the actual function starting the plugin
 func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) (err error) {
-	logger := goglog.Logger
-
 	if err = t.LoadSinceDBInfos(); err != nil {
 		return
 	}
 
-	matches, err := filepath.Glob(t.Path)
-	if err != nil {
-		return ErrorGlobFailed1.New(err, t.Path)
+	t.readers = map[string]*fileReader{}
+	t.completed = map[string]bool{}
+	t.readingKeys = map[string]bool{}
+	t.watchedDirs = map[string]bool{}
+	t.linkPaths = map[string]string{}
+	t.discoverChan = make(chan struct{}, 1)
+	if t.watcher, err = fsnotify.NewWatcher(); err != nil {
+		return ErrorCreateWatcher.New(err)
 	}
+	defer t.watcher.Close()
 
 	eg, ctx := errgroup.WithContext(ctx)
 
@@ -96,142 +170,490 @@ func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEven
 		return t.CheckSaveSinceDBInfosLoop(ctx)
 	})
 
-	for _, fpath := range matches {
-		if fpath, err = evalSymlinks(ctx, fpath); err != nil {
-			logger.Errorf("Get symlinks failed: %q\n%v", fpath, err)
+	eg.Go(func() error {
+		return t.watchLoop(ctx)
+	})
+
+	eg.Go(func() error {
+		return t.discoverLoop(ctx, eg, msgChan)
+	})
+
+	return eg.Wait()
+}
+
+// discoverLoop finds files matching path periodically or when a new file created in watched directories
+func (t *InputConfig) discoverLoop(ctx context.Context, eg *errgroup.Group, msgChan chan<- logevent.LogEvent) (err error) {
+	if err = t.discover(ctx, eg, msgChan, true); err != nil {
+		return
+	}
+
+	var tick <-chan time.Time
+	if t.DiscoverInterval > 0 {
+		ticker := time.NewTicker(time.Duration(t.DiscoverInterval) * time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick:
+		case <-t.discoverChan:
+		}
+		if err = t.discover(ctx, eg, msgChan, false); err != nil {
+			goglog.Logger.Error(err)
+		}
+	}
+}
+
+// triggerDiscover requests a discovery without blocking, pending requests are merged
+func (t *InputConfig) triggerDiscover() {
+	select {
+	case t.discoverChan <- struct{}{}:
+	default:
+	}
+}
+
+// discover starts reading files which are not read yet,
+// files found after startup are read from the beginning
+func (t *InputConfig) discover(ctx context.Context, eg *errgroup.Group, msgChan chan<- logevent.LogEvent, initial bool) (err error) {
+	logger := goglog.Logger
+
+	t.watchPatternDirs()
+
+	fpaths, err := t.findFiles()
+	if err != nil {
+		return
+	}
+
+	for _, fpath := range fpaths {
+		fi, err := os.Stat(fpath)
+		if err != nil {
+			logger.Errorf("stat(%q) failed\n%s", fpath, err)
+			continue
+		}
+		key := sinceDBKey(fpath, fi)
+		if t.isReading(fpath, key) {
 			continue
 		}
 
-		var fi os.FileInfo
-		if fi, err = os.Stat(fpath); err != nil {
-			logger.Errorf("stat(%q) failed\n%s", t.Path, err)
+		if t.Mode == ModeRead {
+			// files are read one by one, new files wait for the next discovery
+			if err := t.readFile(ctx, fpath, key, msgChan); err != nil {
+				logger.Errorf("read file failed: %q\n%v", fpath, err)
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
 			continue
 		}
 
-		if fi.IsDir() {
-			logger.Infof("Skipping directory: %q", t.Path)
+		compression, err := detectFileCompression(fpath)
+		if err != nil {
+			logger.Errorf("read file failed: %q\n%v", fpath, err)
+			continue
+		}
+		if compression != config.CompressionNone {
+			// compressed files, e.g. rotated logs, are read once and not watched
+			if t.markCompleted(key) {
+				func(fpath string, compression string) {
+					eg.Go(func() error {
+						if _, _, err := t.readFileOnce(ctx, fpath, compression, t.StartPos == "end", msgChan); err != nil {
+							logger.Errorf("read compressed file failed: %q\n%v", fpath, err)
+						}
+						return nil
+					})
+				}(fpath, compression)
+			}
 			continue
 		}
 
-		func(fpath string) {
-			readEventChan := make(chan fsnotify.Event, 10)
-			eg.Go(func() error {
-				return t.fileReadLoop(ctx, readEventChan, fpath, msgChan)
-			})
+		startPos := t.StartPos
+		if !initial {
+			startPos = "beginning"
+			if t.isFileUnchanged(key, fi) {
+				// file closed by close_inactive or rotated without new data
+				continue
+			}
+		}
+
+		reader, readerCtx, ok := t.addReader(ctx, fpath)
+		if !ok {
+			logger.Debugf("max_open_files %d reached, deferring file: %q", t.MaxOpenFiles, fpath)
+			continue
+		}
+		t.watchDir(filepath.Dir(fpath))
+
+		func(reader *fileReader, startPos string) {
 			eg.Go(func() error {
-				return t.fileWatchLoop(ctx, readEventChan, fpath, fsnotify.Create|fsnotify.Write)
+				defer t.removeReader(reader)
+				if err := t.fileReadLoop(readerCtx, reader, startPos, msgChan); err != nil {
+					logger.Errorf("read file failed: %q\n%v", reader.fpath, err)
+				}
+				return nil
 			})
-		}(fpath)
+		}(reader, startPos)
 	}
 
-	return eg.Wait()
+	return nil
+}
+
+// findFiles returns regular files matching path and not matching exclude, symlinks are resolved
+func (t *InputConfig) findFiles() (fpaths []string, err error) {
+	logger := goglog.Logger
+	found := map[string]bool{}
+
+	for _, pattern := range t.Path {
+		matches, err := doublestar.FilepathGlob(pattern)
+		if err != nil {
+			return nil, ErrorGlobFailed1.New(err, pattern)
+		}
+
+		for _, fpath := range matches {
+			if t.isExcluded(fpath) {
+				continue
+			}
+
+			matched := fpath
+			if fpath, err = filepath.EvalSymlinks(fpath); err != nil {
+				logger.Errorf("Get symlinks failed: %q\n%v", fpath, err)
+				continue
+			}
+			if found[fpath] {
+				continue
+			}
+			found[fpath] = true
+			if t.KeepSymlinkPath && matched != fpath {
+				t.readersMutex.Lock()
+				t.linkPaths[fpath] = matched
+				t.readersMutex.Unlock()
+			}
+
+			fi, err := os.Stat(fpath)
+			if err != nil {
+				logger.Errorf("stat(%q) failed\n%s", fpath, err)
+				continue
+			}
+			if !fi.Mode().IsRegular() {
+				logger.Debugf("Skipping non-regular file: %q", fpath)
+				continue
+			}
+
+			fpaths = append(fpaths, fpath)
+		}
+	}
+
+	return fpaths, nil
+}
+
+// eventPath returns the path of fpath in events, the matched symlink path if keep_symlink_path
+func (t *InputConfig) eventPath(fpath string) string {
+	if !t.KeepSymlinkPath {
+		return fpath
+	}
+	t.readersMutex.Lock()
+	defer t.readersMutex.Unlock()
+	if link, ok := t.linkPaths[fpath]; ok {
+		return link
+	}
+	return fpath
+}
+
+// isExcluded reports whether fpath matches any exclude pattern,
+// patterns without path separator match the file name only
+func (t *InputConfig) isExcluded(fpath string) bool {
+	for _, pattern := range t.Exclude {
+		name := fpath
+		if !strings.ContainsRune(pattern, filepath.Separator) {
+			name = filepath.Base(fpath)
+		}
+		if matched, _ := doublestar.PathMatch(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isFileUnchanged reports whether the file of key was read to the end and not modified since then
+func (t *InputConfig) isFileUnchanged(key string, fi os.FileInfo) bool {
+	t.sinceDBMutex.Lock()
+	defer t.sinceDBMutex.Unlock()
+	since, ok := t.SinceDBInfos[key]
+	return ok && fi.Size() == since.Offset
+}
+
+// isReading reports whether fpath has a reader, or the file of key is being read under another path
+func (t *InputConfig) isReading(fpath string, key string) bool {
+	t.readersMutex.Lock()
+	defer t.readersMutex.Unlock()
+	_, ok := t.readers[fpath]
+	return ok || t.readingKeys[key] || t.completed[key]
+}
+
+func (t *InputConfig) isKeyReading(key string) bool {
+	t.readersMutex.Lock()
+	defer t.readersMutex.Unlock()
+	return t.readingKeys[key]
+}
+
+// acquireKey marks the file of key as being read, returns false if it is read by another reader
+func (t *InputConfig) acquireKey(key string) bool {
+	t.readersMutex.Lock()
+	defer t.readersMutex.Unlock()
+	if t.readingKeys[key] {
+		return false
+	}
+	t.readingKeys[key] = true
+	return true
+}
+
+func (t *InputConfig) releaseKey(key string) {
+	t.readersMutex.Lock()
+	defer t.readersMutex.Unlock()
+	delete(t.readingKeys, key)
+}
+
+// markCompleted marks a compressed file as read, returns false if it was marked already
+func (t *InputConfig) markCompleted(key string) bool {
+	t.readersMutex.Lock()
+	defer t.readersMutex.Unlock()
+	if t.completed[key] {
+		return false
+	}
+	t.completed[key] = true
+	return true
+}
+
+// addReader registers a reader of fpath, when max_open_files reached the
+// least recently active idle reader is closed, returns false if all readers are busy
+func (t *InputConfig) addReader(ctx context.Context, fpath string) (*fileReader, context.Context, bool) {
+	t.readersMutex.Lock()
+	defer t.readersMutex.Unlock()
+
+	if t.MaxOpenFiles > 0 && len(t.readers) >= t.MaxOpenFiles {
+		var oldest *fileReader
+		for _, reader := range t.readers {
+			if reader.idle && (oldest == nil || reader.lastActive.Before(oldest.lastActive)) {
+				oldest = reader
+			}
+		}
+		if oldest == nil {
+			return nil, nil, false
+		}
+		goglog.Logger.Infof("max_open_files %d reached, closing idle file: %q", t.MaxOpenFiles, oldest.fpath)
+		oldest.cancel()
+		delete(t.readers, oldest.fpath)
+	}
+
+	readerCtx, cancel := context.WithCancel(ctx)
+	reader := &fileReader{
+		fpath:      fpath,
+		events:     make(chan fsnotify.Event, 10),
+		cancel:     cancel,
+		lastActive: time.Now(),
+	}
+	t.readers[fpath] = reader
+	return reader, readerCtx, true
+}
+
+func (t *InputConfig) removeReader(reader *fileReader) {
+	t.readersMutex.Lock()
+	defer t.readersMutex.Unlock()
+	if t.readers[reader.fpath] == reader {
+		delete(t.readers, reader.fpath)
+	}
+	reader.cancel()
+}
+
+func (t *InputConfig) setReaderIdle(reader *fileReader, idle bool) {
+	t.readersMutex.Lock()
+	defer t.readersMutex.Unlock()
+	reader.idle = idle
+	reader.lastActive = time.Now()
+}
+
+// watchPatternDirs watches the existing static base directories of path patterns,
+// so that new files in directories without matched files yet trigger a discovery
+func (t *InputConfig) watchPatternDirs() {
+	for _, pattern := range t.Path {
+		base, _ := doublestar.SplitPattern(filepath.ToSlash(pattern))
+		dir, err := filepath.EvalSymlinks(filepath.FromSlash(base))
+		if err != nil {
+			continue
+		}
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			t.watchDir(dir)
+		}
+	}
+}
+
+func (t *InputConfig) watchDir(dir string) {
+	t.readersMutex.Lock()
+	defer t.readersMutex.Unlock()
+	if t.watchedDirs[dir] {
+		return
+	}
+	if err := t.watcher.Add(dir); err != nil {
+		goglog.Logger.Errorf("add watch path failed: %q\n%v", dir, err)
+		return
+	}
+	t.watchedDirs[dir] = true
+}
+
+// watchLoop dispatches file system events to readers by file name
+func (t *InputConfig) watchLoop(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-t.watcher.Events:
+			if !ok {
+				return nil
+			}
+			t.dispatchWatchEvent(event)
+		case err, ok := <-t.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			goglog.Logger.Errorf("watcher error: %v", err)
+		}
+	}
+}
+
+func (t *InputConfig) dispatchWatchEvent(event fsnotify.Event) {
+	if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+		return
+	}
+
+	t.readersMutex.Lock()
+	reader, ok := t.readers[event.Name]
+	t.readersMutex.Unlock()
+
+	if ok {
+		select {
+		case reader.events <- event:
+		default:
+			// reader is busy, it will read the new data before waiting again
+		}
+		return
+	}
+
+	if event.Op&fsnotify.Create == fsnotify.Create {
+		t.triggerDiscover()
+	} else if fi, err := os.Stat(event.Name); err == nil {
+		if _, known := t.hasSinceDBInfo(sinceDBKey(event.Name, fi)); known {
+			// file closed by close_inactive, max_open_files or rotation is written again
+			t.triggerDiscover()
+		}
+	}
 }
 
 func (t *InputConfig) fileReadLoop(
 	ctx context.Context,
-	readEventChan chan fsnotify.Event,
-	fpath string,
+	reader *fileReader,
+	startPos string,
 	msgChan chan<- logevent.LogEvent,
 ) (err error) {
 	var (
 		since     *SinceDBInfo
+		key       string
 		fp        *os.File
 		truncated bool
-		ok        bool
-		whence    int
-		reader    *bufio.Reader
+		bufReader *bufio.Reader
 		line      string
 		size      int
 
+		fpath  = reader.fpath
 		buffer = &bytes.Buffer{}
 		logger = goglog.Logger
 	)
 
-	if fpath, err = evalSymlinks(ctx, fpath); err != nil {
-		logger.Errorf("Get symlinks failed: %q\n%v", fpath, err)
-		return
-	}
-
-	if since, ok = t.SinceDBInfos[fpath]; !ok {
-		t.SinceDBInfos[fpath] = &SinceDBInfo{}
-		since = t.SinceDBInfos[fpath]
-	}
-
-	if since.Offset == 0 {
-		if t.StartPos == "end" {
-			whence = os.SEEK_END
-		} else {
-			whence = os.SEEK_SET
+	if fp, bufReader, since, key, err = t.openTrackedFile(fpath, startPos); err != nil {
+		if err == errFileBeingRead {
+			return nil
 		}
-	} else {
-		whence = os.SEEK_SET
-	}
-
-	if fp, reader, err = openfile(fpath, since.Offset, whence); err != nil {
 		return
 	}
-	defer fp.Close()
-
-	if truncated, err = isFileTruncated(fp, since); err != nil {
-		return
-	}
-	if truncated {
-		logger.Warnf("File truncated, seeking to beginning: %q", fpath)
-		since.Offset = 0
-		if _, err = fp.Seek(since.Offset, os.SEEK_SET); err != nil {
-			logger.Errorf("seek file failed: %q", fpath)
-			return
-		}
-	}
+	defer func() {
+		fp.Close()
+		t.releaseKey(key)
+	}()
 
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return nil
 		default:
 		}
 
-		if line, size, err = readline(ctx, reader, buffer); err != nil {
-			if err == io.EOF {
-				watchev := <-readEventChan
-				logger.Debug("fileReadLoop recv:", watchev)
-				if watchev.Op&fsnotify.Create == fsnotify.Create {
-					logger.Warnf("File recreated, seeking to beginning: %q", fpath)
-					fp.Close()
-					since.Offset = 0
-					if fp, reader, err = openfile(fpath, since.Offset, os.SEEK_SET); err != nil {
-						return
+		if truncated, err = t.isFileTruncated(fp, since); err != nil {
+			return
+		}
+		if truncated {
+			// copytruncate, the copy resumes from the offset kept by resetTruncatedSinceDBInfo
+			logger.Warnf("File truncated, seeking to beginning: %q", fpath)
+			if err = t.resetTruncatedSinceDBInfo(since, fp); err != nil {
+				return
+			}
+			if _, err = fp.Seek(since.Offset, io.SeekStart); err != nil {
+				return errutil.New("seek file failed: "+fpath, err)
+			}
+			bufReader.Reset(fp)
+			buffer.Reset()
+		}
+
+		if line, size, err = readline(ctx, bufReader, buffer); err != nil {
+			if err != io.EOF {
+				return
+			}
+
+			if t.isFileRotated(fpath, fp) {
+				// the old file is read to the end, continue with the new file of fpath
+				logger.Infof("File rotated, reading new file: %q", fpath)
+				fp.Close()
+				t.releaseKey(key)
+				buffer.Reset()
+				if fp, bufReader, since, key, err = t.openTrackedFile(fpath, "beginning"); err != nil {
+					if err == errFileBeingRead {
+						return nil
 					}
-				}
-				if truncated, err = isFileTruncated(fp, since); err != nil {
 					return
 				}
-				if truncated {
-					logger.Warnf("File truncated, seeking to beginning: %q", fpath)
-					since.Offset = 0
-					if _, err = fp.Seek(since.Offset, os.SEEK_SET); err != nil {
-						logger.Errorf("seek file failed: %q", fpath)
-						return
-					}
-					continue
-				}
-				logger.Debugf("watch %q %q %v", watchev.Name, fpath, watchev)
 				continue
-			} else {
+			}
+
+			if err = t.touchSinceDBInfo(since, fp); err != nil {
+				return
+			}
+
+			var watchev fsnotify.Event
+			t.setReaderIdle(reader, true)
+			if watchev, err = t.waitFileEvent(ctx, reader); err != nil {
+				if err == errFileInactive {
+					logger.Infof("Closing inactive file: %q", fpath)
+					return nil
+				}
 				return
 			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			t.setReaderIdle(reader, false)
+			logger.Debugf("watch %q %q %v", watchev.Name, fpath, watchev)
+			continue
 		}
 
 		_, err := t.Codec.Decode(ctx, []byte(line),
 			map[string]interface{}{
 				"host":   t.hostname,
-				"path":   fpath,
+				"path":   t.eventPath(fpath),
 				"offset": since.Offset,
 			},
 			msgChan)
 
 		if err == nil {
-			since.Offset += int64(size)
+			t.setSinceDBOffset(since, since.Offset+int64(size))
 
 			//loggfer.Debugf("%q %v", event.Message, event)
 			//msgChan <- event
@@ -244,25 +666,193 @@ func (t *InputConfig) fileReadLoop(
 	}
 }
 
-func (self *InputConfig) fileWatchLoop(ctx context.Context, readEventChan chan fsnotify.Event, fpath string, op fsnotify.Op) (err error) {
+// openTrackedFile opens fpath and seeks to the offset in sincedb, new files start at startPos
+func (t *InputConfig) openTrackedFile(fpath string, startPos string) (
+	fp *os.File,
+	bufReader *bufio.Reader,
+	since *SinceDBInfo,
+	key string,
+	err error,
+) {
+	var found bool
+
+	if fp, err = os.Open(fpath); err != nil {
+		err = errutil.New("open file failed: "+fpath, err)
+		return
+	}
+
+	if since, key, found, err = t.lookupSinceDBInfo(fpath, fp); err != nil {
+		fp.Close()
+		return
+	}
+	if !t.acquireKey(key) {
+		fp.Close()
+		err = errFileBeingRead
+		return
+	}
+
+	offset, whence := since.Offset, io.SeekStart
+	if !found && startPos == "end" {
+		offset, whence = 0, io.SeekEnd
+	}
+	if offset, err = fp.Seek(offset, whence); err != nil {
+		fp.Close()
+		t.releaseKey(key)
+		err = errutil.New("seek file failed: "+fpath, err)
+		return
+	}
+	t.setSinceDBOffset(since, offset)
+
+	bufReader = bufio.NewReaderSize(fp, 16*1024)
+	return
+}
+
+// isFileRotated reports whether fpath refers to another file than fp, e.g. renamed and recreated by logrotate
+func (t *InputConfig) isFileRotated(fpath string, fp *os.File) bool {
+	fi, err := os.Stat(fpath)
+	if err != nil {
+		// removed or renamed, keep reading until a new file created
+		return false
+	}
+	openfi, err := fp.Stat()
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(fi, openfi)
+}
+
+var errFileBeingRead = errors.New("file being read by another reader")
+
+var errFileInactive = errors.New("file inactive")
+
+// waitFileEvent waits for a watch event of reader, returns errFileInactive after close_inactive
+func (t *InputConfig) waitFileEvent(ctx context.Context, reader *fileReader) (event fsnotify.Event, err error) {
+	var inactive <-chan time.Time
+	if t.CloseInactive > 0 {
+		timer := time.NewTimer(time.Duration(t.CloseInactive) * time.Second)
+		defer timer.Stop()
+		inactive = timer.C
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-inactive:
+		err = errFileInactive
+	case event = <-reader.events:
+	}
+	return
+}
+
+// readFileOnce reads lines of a file until EOF, offset is counted in uncompressed bytes,
+// files without sincedb entry are skipped if skipNew, done reports whether EOF reached
+func (t *InputConfig) readFileOnce(
+	ctx context.Context,
+	fpath string,
+	compression string,
+	skipNew bool,
+	msgChan chan<- logevent.LogEvent,
+) (since *SinceDBInfo, done bool, err error) {
 	var (
-		event fsnotify.Event
+		key    string
+		fp     *os.File
+		r      io.ReadCloser
+		ok     bool
+		logger = goglog.Logger
 	)
+
+	if fp, err = os.Open(fpath); err != nil {
+		return nil, false, errutil.New("open file failed: "+fpath, err)
+	}
+	defer fp.Close()
+
+	if since, key, ok, err = t.lookupSinceDBInfo(fpath, fp); err != nil {
+		return
+	}
+	if !ok && skipNew {
+		logger.Infof("Skipping compressed file with start_position end: %q", fpath)
+		t.deleteSinceDBInfo(key)
+		return nil, false, nil
+	}
+	if since.Completed {
+		return since, false, nil
+	}
+
+	if compression == config.CompressionNone {
+		if _, err = fp.Seek(since.Offset, io.SeekStart); err != nil {
+			return since, false, errutil.New("seek file failed: "+fpath, err)
+		}
+	}
+	if r, err = config.NewDecompressReader(fp, compression); err != nil {
+		return since, false, errutil.New("decompress file failed: "+fpath, err)
+	}
+	defer r.Close()
+
+	if compression != config.CompressionNone {
+		if _, err = io.CopyN(ioutil.Discard, r, since.Offset); err != nil {
+			if err == io.EOF {
+				// already read
+				return since, true, nil
+			}
+			return since, false, errutil.New("seek file failed: "+fpath, err)
+		}
+	}
+
+	reader := bufio.NewReaderSize(r, 16*1024)
 	for {
 		select {
 		case <-ctx.Done():
-			return nil
+			return since, false, nil
 		default:
 		}
 
-		if event, err = waitWatchEvent(ctx, fpath, op); err != nil {
-			return
+		segment, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return since, false, errutil.New("read line failed", err)
+		}
+		if len(segment) > 0 {
+			line := strings.TrimRight(string(segment), "\r\n")
+			if _, err2 := t.Codec.Decode(ctx, []byte(line),
+				map[string]interface{}{
+					"host":   t.hostname,
+					"path":   t.eventPath(fpath),
+					"offset": since.Offset,
+				},
+				msgChan); err2 != nil {
+				logger.Errorf("Failed to decode %v using codec %v", line, t.Codec)
+			}
+			t.setSinceDBOffset(since, since.Offset+int64(len(segment)))
+			t.CheckSaveSinceDBInfos()
+		}
+		if err == io.EOF {
+			logger.Infof("File read done: %q", fpath)
+			return since, true, nil
 		}
-		readEventChan <- event
 	}
 }
 
-func isFileTruncated(fp *os.File, since *SinceDBInfo) (truncated bool, err error) {
+// detectFileCompression returns compression type of file by magic bytes
+func detectFileCompression(fpath string) (compression string, err error) {
+	fp, err := os.Open(fpath)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+
+	magic := make([]byte, 10)
+	n, err := io.ReadFull(fp, magic)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return
+	}
+	compression = config.DetectCompression(magic[:n])
+	if compression == config.CompressionZlib {
+		// zlib header is too weak to detect plain text files
+		compression = config.CompressionNone
+	}
+	return compression, nil
+}
+
+// isFileTruncated reports whether the file is shorter than the read offset, or its leading bytes changed
+func (t *InputConfig) isFileTruncated(fp *os.File, since *SinceDBInfo) (truncated bool, err error) {
 	var (
 		fi os.FileInfo
 	)
@@ -271,26 +861,9 @@ func isFileTruncated(fp *os.File, since *SinceDBInfo) (truncated bool, err error
 		return
 	}
 	if fi.Size() < since.Offset {
-		truncated = true
-	} else {
-		truncated = false
-	}
-	return
-}
-
-func openfile(fpath string, offset int64, whence int) (fp *os.File, reader *bufio.Reader, err error) {
-	if fp, err = os.Open(fpath); err != nil {
-		err = errutil.New("open file failed: "+fpath, err)
-		return
+		return true, nil
 	}
-
-	if _, err = fp.Seek(offset, whence); err != nil {
-		err = errutil.New("seek file failed: " + fpath)
-		return
-	}
-
-	reader = bufio.NewReaderSize(fp, 16*1024)
-	return
+	return t.isContentChanged(since, fp)
 }
 
 func readline(ctx context.Context, reader *bufio.Reader, buffer *bytes.Buffer) (line string, size int, err error) {
@@ -338,76 +911,3 @@ func isPartialLine(segment []byte) bool {
 	}
 	return false
 }
-
-var (
-	mapWatcher = map[string]*fsnotify.Watcher{}
-)
-
-func waitWatchEvent(ctx context.Context, fpath string, op fsnotify.Op) (event fsnotify.Event, err error) {
-	var (
-		fdir    string
-		watcher *fsnotify.Watcher
-		ok      bool
-	)
-
-	if fpath, err = evalSymlinks(ctx, fpath); err != nil {
-		err = errutil.New("Get symlinks failed: "+fpath, err)
-		return
-	}
-
-	fdir = filepath.Dir(fpath)
-
-	if watcher, ok = mapWatcher[fdir]; !ok {
-		//		logger.Debugf("create new watcher for %q", fdir)
-		if watcher, err = fsnotify.NewWatcher(); err != nil {
-			err = errutil.New("create new watcher failed: "+fdir, err)
-			return
-		}
-		mapWatcher[fdir] = watcher
-		if err = watcher.Add(fdir); err != nil {
-			err = errutil.New("add new watch path failed: "+fdir, err)
-			return
-		}
-	}
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case event = <-watcher.Events:
-			if event.Name == fpath {
-				if op > 0 {
-					if event.Op&op > 0 {
-						return
-					}
-				} else {
-					return
-				}
-			}
-		case err = <-watcher.Errors:
-			err = errutil.New("watcher error", err)
-			return
-		}
-	}
-}
-
-func evalSymlinks(ctx context.Context, path string) (string, error) {
-	// https://github.com/viethqc/gogstash/issues/30
-	for retry := 5; retry > 0; retry-- {
-		if futil.IsExist(path) {
-			break
-		}
-
-		select {
-		case <-ctx.Done():
-			return path, nil
-		case <-time.After(500 * time.Millisecond):
-		}
-	}
-
-	if futil.IsNotExist(path) {
-		return path, os.ErrNotExist
-	}
-
-	return filepath.EvalSymlinks(path)
-}
@@ -2,6 +2,9 @@ package inputfile
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -11,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
 )
 
 func init() {
@@ -69,3 +73,406 @@ input:
 		require.Equal("gogstash input file", event.Message)
 	}
 }
+
+func Test_input_file_module_compressed(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-input-file")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	data, err := config.Compress([]byte("line1\nline2\nline3"), config.CompressionGzip, 0)
+	require.NoError(err)
+	fpath := filepath.Join(dir, "app.log.1.gz")
+	require.NoError(ioutil.WriteFile(fpath, data, 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
+	input, err := InitHandler(ctx, &config.ConfigRaw{
+		"path":           filepath.Join(dir, "*.gz"),
+		"sincedb_path":   "",
+		"start_position": "beginning",
+	})
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	go input.Start(ctx, msgChan)
+
+	for _, expected := range []string{"line1", "line2", "line3"} {
+		select {
+		case event := <-msgChan:
+			assert.Equal(expected, event.Message)
+			assert.Equal(fpath, event.Extra["path"])
+		case <-time.After(2 * time.Second):
+			require.Fail("timeout")
+		}
+	}
+}
+
+func Test_input_file_module_discover(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-input-file")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "a.log"), []byte("a1\n"), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "sub", "b.log"), []byte("b1\n"), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "sub", "skip.log"), []byte("skip\n"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
+	input, err := InitHandler(ctx, &config.ConfigRaw{
+		"path":              []interface{}{filepath.Join(dir, "**", "*.log")},
+		"exclude":           []interface{}{"skip*"},
+		"sincedb_path":      "",
+		"start_position":    "beginning",
+		"discover_interval": 1,
+		"close_inactive":    1,
+	})
+	require.NoError(err)
+	conf := input.(*InputConfig)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	go input.Start(ctx, msgChan)
+
+	assert.ElementsMatch([]string{"a1", "b1"}, receiveMessages(msgChan))
+
+	// new file created while running, read from the beginning
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "sub", "c.log"), []byte("c1\n"), 0644))
+	assert.ElementsMatch([]string{"c1"}, receiveMessages(msgChan))
+
+	// idle files closed by close_inactive
+	conf.readersMutex.Lock()
+	assert.Len(conf.readers, 0)
+	conf.readersMutex.Unlock()
+
+	// closed file written again is reopened at the last offset
+	appendFile(t, filepath.Join(dir, "a.log"), "a2\n")
+	assert.ElementsMatch([]string{"a2"}, receiveMessages(msgChan))
+}
+
+func Test_input_file_module_max_open_files(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-input-file")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(ioutil.WriteFile(filepath.Join(dir, name+".log"), []byte(name+"\n"), 0644))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
+	input, err := InitHandler(ctx, &config.ConfigRaw{
+		"path":              filepath.Join(dir, "*.log"),
+		"sincedb_path":      "",
+		"start_position":    "beginning",
+		"discover_interval": 1,
+		"max_open_files":    1,
+	})
+	require.NoError(err)
+	conf := input.(*InputConfig)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	go input.Start(ctx, msgChan)
+
+	messages := []string{}
+	for len(messages) < 3 {
+		select {
+		case event := <-msgChan:
+			messages = append(messages, event.Message)
+			conf.readersMutex.Lock()
+			assert.True(len(conf.readers) <= 1)
+			conf.readersMutex.Unlock()
+		case <-time.After(5 * time.Second):
+			require.Fail("timeout", "received: %v", messages)
+		}
+	}
+	assert.ElementsMatch([]string{"a", "b", "c"}, messages)
+}
+
+func Test_input_file_module_rotate(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-input-file")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "app.log")
+	require.NoError(ioutil.WriteFile(fpath, []byte("a1\n"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
+	input, err := InitHandler(ctx, &config.ConfigRaw{
+		"path":              fpath + "*",
+		"sincedb_path":      "",
+		"start_position":    "beginning",
+		"discover_interval": 1,
+	})
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	go input.Start(ctx, msgChan)
+	assert.Equal([]string{"a1"}, receiveMessages(msgChan))
+
+	// rename and create, data written to the renamed file before the new file is read
+	require.NoError(os.Rename(fpath, fpath+".1"))
+	appendFile(t, fpath+".1", "a2\n")
+	require.NoError(ioutil.WriteFile(fpath, []byte("b1\n"), 0644))
+	assert.Equal([]string{"a2", "b1"}, receiveMessages(msgChan))
+
+	// renamed file written again is discovered and resumed
+	appendFile(t, fpath+".1", "a3\n")
+	assert.Equal([]string{"a3"}, receiveMessages(msgChan))
+}
+
+func Test_input_file_module_copytruncate(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-input-file")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// long enough for a full size fingerprint
+	content := strings.Repeat(strings.Repeat("x", 99)+"\n", 20)
+	fpath := filepath.Join(dir, "app.log")
+	require.NoError(ioutil.WriteFile(fpath, []byte(content), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
+	input, err := InitHandler(ctx, &config.ConfigRaw{
+		"path":              fpath + "*",
+		"sincedb_path":      "",
+		"start_position":    "beginning",
+		"discover_interval": 1,
+	})
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 100)
+	go input.Start(ctx, msgChan)
+	assert.Len(receiveMessages(msgChan), 20)
+
+	// copy and truncate, the copy is not read again
+	tmpfile := filepath.Join(dir, "copy.tmp")
+	require.NoError(ioutil.WriteFile(tmpfile, []byte(content), 0644))
+	require.NoError(os.Rename(tmpfile, fpath+".1"))
+	require.NoError(os.Truncate(fpath, 0))
+	appendFile(t, fpath, "b1\n")
+	assert.Equal([]string{"b1"}, receiveMessages(msgChan))
+}
+
+func Test_input_file_sincedb(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-input-file")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "app.log")
+	require.NoError(ioutil.WriteFile(fpath, []byte("line1\nline2\n"), 0644))
+
+	ctx := context.Background()
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
+	input, err := InitHandler(ctx, &config.ConfigRaw{
+		"path":                fpath,
+		"sincedb_path":        filepath.Join(dir, "sincedb.json"),
+		"sincedb_clean_after": "1h",
+	})
+	require.NoError(err)
+	conf := input.(*InputConfig)
+	conf.readingKeys = map[string]bool{}
+
+	// entry of older versions keyed by path is migrated to device and inode
+	require.NoError(ioutil.WriteFile(conf.SinceDBPath, []byte(`{"`+fpath+`":{"offset":6}}`), 0644))
+	require.NoError(conf.LoadSinceDBInfos())
+
+	fp, err := os.Open(fpath)
+	require.NoError(err)
+	defer fp.Close()
+	since, key, found, err := conf.lookupSinceDBInfo(fpath, fp)
+	require.NoError(err)
+	assert.True(found)
+	assert.EqualValues(6, since.Offset)
+	assert.Equal(fpath, since.Path)
+	assert.NotEmpty(since.Fingerprint)
+	assert.EqualValues(12, since.FingerprintSize)
+	_, legacy := conf.hasSinceDBInfo(fpath)
+	assert.Equal(key == fpath, legacy)
+
+	// same inode with other content is a new file
+	require.NoError(ioutil.WriteFile(fpath, []byte("other\n"), 0644))
+	since, _, found, err = conf.lookupSinceDBInfo(fpath, fp)
+	require.NoError(err)
+	assert.False(found)
+	assert.EqualValues(0, since.Offset)
+
+	// expired entries are removed unless being read
+	conf.SinceDBInfos["expired"] = &SinceDBInfo{LastActive: time.Now().Add(-2 * time.Hour)}
+	conf.SinceDBInfos["reading"] = &SinceDBInfo{LastActive: time.Now().Add(-2 * time.Hour)}
+	conf.readingKeys["reading"] = true
+	conf.cleanSinceDBInfos()
+	_, ok := conf.hasSinceDBInfo("expired")
+	assert.False(ok)
+	_, ok = conf.hasSinceDBInfo("reading")
+	assert.True(ok)
+	_, ok = conf.hasSinceDBInfo(key)
+	assert.True(ok)
+}
+
+func receiveMessages(msgChan chan logevent.LogEvent) (messages []string) {
+	for {
+		select {
+		case event := <-msgChan:
+			messages = append(messages, event.Message)
+		case <-time.After(2500 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func appendFile(t *testing.T, fpath string, data string) {
+	fp, err := os.OpenFile(fpath, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = fp.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, fp.Close())
+}
+
+func Test_input_file_module_read(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-input-file")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	datadir := filepath.Join(dir, "data")
+	require.NoError(os.MkdirAll(datadir, 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(datadir, "a.log"), []byte("a1\na2"), 0644))
+	data, err := config.Compress([]byte("b1\n"), config.CompressionGzip, 0)
+	require.NoError(err)
+	require.NoError(ioutil.WriteFile(filepath.Join(datadir, "b.log.gz"), data, 0644))
+
+	raw := config.ConfigRaw{
+		"mode":                    "read",
+		"path":                    filepath.Join(datadir, "*"),
+		"sincedb_path":            filepath.Join(dir, "sincedb.json"),
+		"file_completed_action":   "log",
+		"file_completed_log_path": filepath.Join(dir, "completed.log"),
+	}
+	run := func(raw config.ConfigRaw) []string {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		input, err := InitHandler(ctx, &raw)
+		require.NoError(err)
+		msgChan := make(chan logevent.LogEvent, 10)
+		go input.Start(ctx, msgChan)
+		return receiveMessages(msgChan)
+	}
+
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
+	assert.ElementsMatch([]string{"a1", "a2", "b1"}, run(raw))
+	completed, err := ioutil.ReadFile(filepath.Join(dir, "completed.log"))
+	require.NoError(err)
+	assert.ElementsMatch([]string{filepath.Join(datadir, "a.log"), filepath.Join(datadir, "b.log.gz")},
+		strings.Fields(string(completed)))
+
+	// completed files are not read again after restart
+	assert.Empty(run(raw))
+
+	raw["file_completed_action"] = "archive"
+	raw["file_completed_archive_dir"] = filepath.Join(dir, "archive")
+	require.NoError(ioutil.WriteFile(filepath.Join(datadir, "c.log"), []byte("c1\n"), 0644))
+	assert.Equal([]string{"c1"}, run(raw))
+	assert.FileExists(filepath.Join(dir, "archive", "c.log"))
+	_, err = os.Stat(filepath.Join(datadir, "c.log"))
+	assert.True(os.IsNotExist(err))
+
+	raw["file_completed_action"] = "delete"
+	require.NoError(ioutil.WriteFile(filepath.Join(datadir, "d.log"), []byte("d1\n"), 0644))
+	assert.Equal([]string{"d1"}, run(raw))
+	_, err = os.Stat(filepath.Join(datadir, "d.log"))
+	assert.True(os.IsNotExist(err))
+
+	// sincedb entry of a removed file is dropped, a new file at the same path
+	// with the same content, possibly reusing the inode, is read again
+	sincedb, err := ioutil.ReadFile(filepath.Join(dir, "sincedb.json"))
+	require.NoError(err)
+	assert.NotContains(string(sincedb), filepath.Join(datadir, "d.log"))
+	require.NoError(ioutil.WriteFile(filepath.Join(datadir, "d.log"), []byte("d1\n"), 0644))
+	assert.Equal([]string{"d1"}, run(raw))
+
+	_, err = InitHandler(context.Background(), &config.ConfigRaw{
+		"mode":                  "read",
+		"file_completed_action": "log",
+	})
+	assert.Error(err)
+}
+
+func Test_input_file_module_keep_symlink_path(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-input-file")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(os.MkdirAll(filepath.Join(dir, "target"), 0755))
+	require.NoError(os.MkdirAll(filepath.Join(dir, "links"), 0755))
+	target := filepath.Join(dir, "target", "app.log")
+	require.NoError(ioutil.WriteFile(target, []byte("line1\n"), 0644))
+	link := filepath.Join(dir, "links", "app.log")
+	require.NoError(os.Symlink(target, link))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
+	input, err := InitHandler(ctx, &config.ConfigRaw{
+		"path":              filepath.Join(dir, "links", "*.log"),
+		"sincedb_path":      "",
+		"start_position":    "beginning",
+		"keep_symlink_path": true,
+	})
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	go input.Start(ctx, msgChan)
+
+	select {
+	case event := <-msgChan:
+		assert.Equal("line1", event.Message)
+		assert.Equal(link, event.Extra["path"])
+	case <-time.After(2 * time.Second):
+		require.Fail("timeout")
+	}
+}
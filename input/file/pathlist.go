@@ -0,0 +1,33 @@
+package inputfile
+
+import (
+	"encoding/json"
+
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// PathList is a list of glob patterns, config accepts a single string or a list of strings
+type PathList []string
+
+// UnmarshalJSON decode json string or string array to PathList
+func (t *PathList) UnmarshalJSON(b []byte) (err error) {
+	var path string
+	if err = json.Unmarshal(b, &path); err == nil {
+		if path == "" {
+			*t = nil
+		} else {
+			*t = PathList{logevent.FormatWithEnv(path)}
+		}
+		return nil
+	}
+
+	var paths []string
+	if err = json.Unmarshal(b, &paths); err != nil {
+		return err
+	}
+	for i := range paths {
+		paths[i] = logevent.FormatWithEnv(paths[i])
+	}
+	*t = paths
+	return nil
+}
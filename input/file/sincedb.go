@@ -3,17 +3,270 @@ package inputfile
 import (
 	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"strings"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	log "github.com/sirupsen/logrus"
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
 	"github.com/viethqc/gogstash/KDGoLib/futil"
 )
 
+// FingerprintSize is the number of leading bytes used to identify file content
+const FingerprintSize = 1024
+
+// prefix of sincedb keys which keep the state of truncated files, for their copies to resume from
+const fingerprintKeyPrefix = "fingerprint:"
+
+// SinceDBInfo is the read state of a file, keyed by device and inode in sincedb
 type SinceDBInfo struct {
-	Offset int64 `json:"offset,omitempty"`
+	Offset          int64     `json:"offset,omitempty"`
+	Path            string    `json:"path,omitempty"` // last known path
+	Device          uint64    `json:"device,omitempty"`
+	Inode           uint64    `json:"inode,omitempty"`
+	Fingerprint     string    `json:"fingerprint,omitempty"` // hex encoded sha1 of the first FingerprintSize bytes
+	FingerprintSize int64     `json:"fingerprint_size,omitempty"`
+	LastActive      time.Time `json:"last_active"`
+	Completed       bool      `json:"completed,omitempty"` // read to the end in read mode
+}
+
+// sinceDBKey returns the sincedb key of file, device and inode if available, otherwise the path
+func sinceDBKey(fpath string, fi os.FileInfo) string {
+	if device, inode, ok := fileIdentity(fi); ok {
+		return fmt.Sprintf("%d:%d", device, inode)
+	}
+	return fpath
+}
+
+// fileFingerprint returns hex encoded sha1 of the first size bytes of fp, empty if the file is shorter
+func fileFingerprint(fp *os.File, size int64) (string, error) {
+	if size <= 0 {
+		return "", nil
+	}
+	buf := make([]byte, size)
+	n, err := fp.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if int64(n) < size {
+		return "", nil
+	}
+	sum := sha1.Sum(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lookupSinceDBInfo returns the sincedb info of opened file fp, found reports whether it existed.
+// The info is matched by device and inode with the same fingerprint, then by legacy path key,
+// then by full size fingerprint for copies of truncated files, e.g. by logrotate copytruncate.
+func (t *InputConfig) lookupSinceDBInfo(fpath string, fp *os.File) (since *SinceDBInfo, key string, found bool, err error) {
+	fi, err := fp.Stat()
+	if err != nil {
+		return nil, "", false, errutil.New("stat file failed: "+fpath, err)
+	}
+	key = sinceDBKey(fpath, fi)
+	size := fi.Size()
+	fingerprintSize := size
+	if fingerprintSize > FingerprintSize {
+		fingerprintSize = FingerprintSize
+	}
+	fingerprint, err := fileFingerprint(fp, fingerprintSize)
+	if err != nil {
+		return nil, "", false, errutil.New("read fingerprint failed: "+fpath, err)
+	}
+
+	t.sinceDBMutex.Lock()
+	defer t.sinceDBMutex.Unlock()
+
+	device, inode, _ := fileIdentity(fi)
+	defer func() {
+		if since != nil {
+			since.Path = fpath
+			since.Device = device
+			since.Inode = inode
+			since.LastActive = time.Now()
+		}
+	}()
+
+	if since, found = t.SinceDBInfos[key]; found {
+		if since.FingerprintSize > 0 {
+			prefix := fingerprint
+			if since.FingerprintSize != fingerprintSize {
+				if prefix, err = fileFingerprint(fp, since.FingerprintSize); err != nil {
+					return nil, "", false, errutil.New("read fingerprint failed: "+fpath, err)
+				}
+			}
+			if prefix != since.Fingerprint {
+				// inode reused by another file
+				found = false
+			}
+		}
+		if found {
+			if since.FingerprintSize < fingerprintSize {
+				since.Fingerprint, since.FingerprintSize = fingerprint, fingerprintSize
+			}
+			return
+		}
+	} else if since, found = t.SinceDBInfos[fpath]; found && since.Inode == 0 && key != fpath {
+		// migrate entry of sincedb written by older versions, keyed by path
+		delete(t.SinceDBInfos, fpath)
+		t.SinceDBInfos[key] = since
+		since.Fingerprint, since.FingerprintSize = fingerprint, fingerprintSize
+		return
+	}
+
+	since = &SinceDBInfo{
+		Fingerprint:     fingerprint,
+		FingerprintSize: fingerprintSize,
+	}
+	found = false
+	if fingerprintSize == FingerprintSize {
+		if copied, copiedKey := t.findFingerprint(key, fingerprint); copied != nil {
+			since.Offset = copied.Offset
+			if since.Offset > size {
+				since.Offset = size
+			}
+			if strings.HasPrefix(copiedKey, fingerprintKeyPrefix) {
+				delete(t.SinceDBInfos, copiedKey)
+			}
+			found = true
+		}
+	}
+	t.SinceDBInfos[key] = since
+	return
+}
+
+// findFingerprint returns the info of another file with the same full size fingerprint,
+// state of truncated files is preferred
+func (t *InputConfig) findFingerprint(key string, fingerprint string) (match *SinceDBInfo, matchKey string) {
+	if since, ok := t.SinceDBInfos[fingerprintKeyPrefix+fingerprint]; ok {
+		return since, fingerprintKeyPrefix + fingerprint
+	}
+	for k, since := range t.SinceDBInfos {
+		if k != key && since.FingerprintSize == FingerprintSize && since.Fingerprint == fingerprint {
+			return since, k
+		}
+	}
+	return nil, ""
+}
+
+// resetTruncatedSinceDBInfo keeps the state of a truncated file for its copy and resets since
+func (t *InputConfig) resetTruncatedSinceDBInfo(since *SinceDBInfo, fp *os.File) (err error) {
+	t.sinceDBMutex.Lock()
+	defer t.sinceDBMutex.Unlock()
+
+	if since.FingerprintSize == FingerprintSize {
+		t.SinceDBInfos[fingerprintKeyPrefix+since.Fingerprint] = &SinceDBInfo{
+			Offset:          since.Offset,
+			Path:            since.Path,
+			Fingerprint:     since.Fingerprint,
+			FingerprintSize: since.FingerprintSize,
+			LastActive:      time.Now(),
+		}
+	}
+
+	since.Offset = 0
+	since.Fingerprint, since.FingerprintSize = "", 0
+	return t.updateFingerprint(since, fp)
+}
+
+// updateFingerprint extends the fingerprint of a growing file up to FingerprintSize, sinceDBMutex must be held
+func (t *InputConfig) updateFingerprint(since *SinceDBInfo, fp *os.File) (err error) {
+	if since.FingerprintSize >= FingerprintSize {
+		return nil
+	}
+	fi, err := fp.Stat()
+	if err != nil {
+		return errutil.New("stat file failed: "+fp.Name(), err)
+	}
+	size := fi.Size()
+	if size > FingerprintSize {
+		size = FingerprintSize
+	}
+	if size <= since.FingerprintSize {
+		return nil
+	}
+	if since.Fingerprint, err = fileFingerprint(fp, size); err != nil {
+		return errutil.New("read fingerprint failed: "+fp.Name(), err)
+	}
+	since.FingerprintSize = size
+	return nil
+}
+
+// isContentChanged reports whether the leading bytes of fp differ from the fingerprint of since,
+// e.g. truncated and rewritten beyond the read offset
+func (t *InputConfig) isContentChanged(since *SinceDBInfo, fp *os.File) (changed bool, err error) {
+	t.sinceDBMutex.Lock()
+	fingerprint, fingerprintSize := since.Fingerprint, since.FingerprintSize
+	t.sinceDBMutex.Unlock()
+
+	if fingerprintSize == 0 {
+		return false, nil
+	}
+	prefix, err := fileFingerprint(fp, fingerprintSize)
+	if err != nil {
+		return false, errutil.New("read fingerprint failed: "+fp.Name(), err)
+	}
+	return prefix != fingerprint, nil
+}
+
+// touchSinceDBInfo updates the active time and fingerprint of since
+func (t *InputConfig) touchSinceDBInfo(since *SinceDBInfo, fp *os.File) (err error) {
+	t.sinceDBMutex.Lock()
+	defer t.sinceDBMutex.Unlock()
+	since.LastActive = time.Now()
+	return t.updateFingerprint(since, fp)
+}
+
+// setSinceDBOffset updates the read offset of since, which is saved by another goroutine
+func (t *InputConfig) setSinceDBOffset(since *SinceDBInfo, offset int64) {
+	t.sinceDBMutex.Lock()
+	defer t.sinceDBMutex.Unlock()
+	since.Offset = offset
+}
+
+// hasSinceDBInfo returns the sincedb info of key if exists
+func (t *InputConfig) hasSinceDBInfo(key string) (since *SinceDBInfo, ok bool) {
+	t.sinceDBMutex.Lock()
+	defer t.sinceDBMutex.Unlock()
+	since, ok = t.SinceDBInfos[key]
+	return
+}
+
+func (t *InputConfig) deleteSinceDBInfo(key string) {
+	t.sinceDBMutex.Lock()
+	defer t.sinceDBMutex.Unlock()
+	delete(t.SinceDBInfos, key)
+}
+
+// cleanSinceDBInfos removes entries of files not active within sincedb_clean_after and not being read,
+// entries of completed files are kept until the file is removed
+func (t *InputConfig) cleanSinceDBInfos() {
+	if t.sinceDBCleanAfter <= 0 {
+		return
+	}
+	expire := time.Now().Add(-t.sinceDBCleanAfter)
+
+	t.sinceDBMutex.Lock()
+	defer t.sinceDBMutex.Unlock()
+	for key, since := range t.SinceDBInfos {
+		if since.Completed {
+			// keep completed files from being read again while they exist
+			if fi, err := os.Stat(since.Path); err == nil && sinceDBKey(since.Path, fi) == key {
+				continue
+			}
+		}
+		if since.LastActive.Before(expire) && !t.isKeyReading(key) {
+			log.Debugf("sincedb entry expired: %q %q", key, since.Path)
+			delete(t.SinceDBInfos, key)
+		}
+	}
 }
 
 func (self *InputConfig) LoadSinceDBInfos() (err error) {
@@ -43,6 +296,16 @@ func (self *InputConfig) LoadSinceDBInfos() (err error) {
 		return
 	}
 
+	// entries of older versions have no active time, start expiring them from now
+	now := time.Now()
+	for key, since := range self.SinceDBInfos {
+		if since == nil {
+			delete(self.SinceDBInfos, key)
+		} else if since.LastActive.IsZero() {
+			since.LastActive = now
+		}
+	}
+
 	return
 }
 
@@ -58,7 +321,10 @@ func (self *InputConfig) SaveSinceDBInfos() (err error) {
 		return
 	}
 
-	if raw, err = json.Marshal(self.SinceDBInfos); err != nil {
+	self.sinceDBMutex.Lock()
+	raw, err = json.Marshal(self.SinceDBInfos)
+	self.sinceDBMutex.Unlock()
+	if err != nil {
 		log.Errorf("Marshal sincedb failed: %s", err)
 		return
 	}
@@ -77,7 +343,10 @@ func (t *InputConfig) CheckSaveSinceDBInfos() (err error) {
 		raw []byte
 	)
 	if time.Since(t.SinceDBLastSaveTime) > time.Duration(t.SinceDBWriteInterval)*time.Second {
-		if raw, err = json.Marshal(t.SinceDBInfos); err != nil {
+		t.sinceDBMutex.Lock()
+		raw, err = json.Marshal(t.SinceDBInfos)
+		t.sinceDBMutex.Unlock()
+		if err != nil {
 			log.Errorf("Marshal sincedb failed: %s", err)
 			return
 		}
@@ -97,6 +366,7 @@ func (t *InputConfig) CheckSaveSinceDBInfosLoop(ctx context.Context) (err error)
 		case <-ctx.Done():
 			return nil
 		case <-ticker.C:
+			t.cleanSinceDBInfos()
 			if err = t.CheckSaveSinceDBInfos(); err != nil {
 				return
 			}
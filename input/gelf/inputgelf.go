@@ -0,0 +1,293 @@
+package inputgelf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	reuse "github.com/libp2p/go-reuseport"
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	codecgelf "github.com/viethqc/gogstash/codec/gelf"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+	"golang.org/x/sync/errgroup"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "gelf"
+
+// ErrorTag tag added to event when process module failed
+const ErrorTag = "gogstash_input_gelf_error"
+
+// InputConfig holds the configuration json fields and internal objects
+type InputConfig struct {
+	config.InputConfig
+	Socket       string `json:"socket"`        // Type of socket, must be one of ["udp", "tcp"], default: "udp"
+	Address      string `json:"address"`       // Address to listen, default: "0.0.0.0:12201"
+	ReusePort    bool   `json:"reuseport"`     // SO_REUSEPORT applied or not, default: false
+	BufferSize   int    `json:"buffer_size"`   // UDP read buffer size, default: 65536
+	ChunkTimeout int    `json:"chunk_timeout"` // Drop incomplete chunked messages after seconds, default: 5
+}
+
+// DefaultInputConfig returns an InputConfig struct with default values
+func DefaultInputConfig() InputConfig {
+	return InputConfig{
+		InputConfig: config.InputConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		Socket:       "udp",
+		Address:      "0.0.0.0:12201",
+		BufferSize:   65536,
+		ChunkTimeout: 5,
+	}
+}
+
+// errors
+var (
+	ErrorUnknownSocketType1 = errutil.NewFactory("%q is not a valid socket type")
+	ErrorSocketAccept       = errutil.NewFactory("socket accept error")
+	ErrorInvalidChunk2      = errutil.NewFactory("invalid chunk %d of %d")
+)
+
+// InitHandler initialize the input plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputConfig, error) {
+	conf := DefaultInputConfig()
+	err := config.ReflectConfig(raw, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	switch conf.Socket {
+	case "udp", "tcp":
+	default:
+		return nil, ErrorUnknownSocketType1.New(nil, conf.Socket)
+	}
+
+	conf.Codec, err = config.GetCodecDefault(ctx, *raw, codecgelf.ModuleName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conf, nil
+}
+
+// Start wraps the actual function starting the plugin
+func (i *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
+	logger := goglog.Logger
+	logger.Debugf("listen %q on %q", i.Socket, i.Address)
+
+	if i.Socket == "udp" {
+		var conn net.PacketConn
+		var err error
+		if i.ReusePort {
+			conn, err = reuse.ListenPacket(i.Socket, i.Address)
+		} else {
+			conn, err = net.ListenPacket(i.Socket, i.Address)
+		}
+		if err != nil {
+			return err
+		}
+		return i.handleUDP(ctx, conn, msgChan)
+	}
+
+	var l net.Listener
+	var err error
+	if i.ReusePort {
+		l, err = reuse.Listen(i.Socket, i.Address)
+	} else {
+		l, err = net.Listen(i.Socket, i.Address)
+	}
+	if err != nil {
+		return err
+	}
+	return i.handleTCP(ctx, l, msgChan)
+}
+
+func (i *InputConfig) handleUDP(ctx context.Context, conn net.PacketConn, msgChan chan<- logevent.LogEvent) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	chunks := newChunkAssembler(time.Duration(i.ChunkTimeout) * time.Second)
+
+	eg.Go(func() error {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return conn.Close()
+			case now := <-ticker.C:
+				chunks.expire(now)
+			}
+		}
+	})
+
+	eg.Go(func() error {
+		b := make([]byte, i.BufferSize) // read buf
+		for {
+			n, _, err := conn.ReadFrom(b)
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return nil
+				default:
+				}
+				return err
+			}
+
+			packet := b[:n]
+			if !bytes.HasPrefix(packet, codecgelf.ChunkMagic) {
+				i.Codec.Decode(ctx, append([]byte(nil), packet...), nil, msgChan)
+				continue
+			}
+
+			message, complete, err := chunks.add(packet, time.Now())
+			if err != nil {
+				goglog.Logger.Warn(err)
+				continue
+			}
+			if complete {
+				i.Codec.Decode(ctx, message, nil, msgChan)
+			}
+		}
+	})
+
+	return eg.Wait()
+}
+
+func (i *InputConfig) handleTCP(ctx context.Context, l net.Listener, msgChan chan<- logevent.LogEvent) error {
+	eg, ctx := errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		select {
+		case <-ctx.Done():
+			return l.Close()
+		}
+	})
+
+	eg.Go(func() error {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return nil
+				default:
+				}
+				return ErrorSocketAccept.New(err)
+			}
+			func(conn net.Conn) {
+				eg.Go(func() error {
+					defer conn.Close()
+					i.parse(ctx, conn, msgChan)
+					return nil
+				})
+			}(conn)
+		}
+	})
+
+	return eg.Wait()
+}
+
+// parse decodes null byte delimited messages from r
+func (i *InputConfig) parse(ctx context.Context, r io.Reader, msgChan chan<- logevent.LogEvent) {
+	b := bufio.NewReader(r)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		message, err := b.ReadBytes(0)
+		if message = bytes.TrimRight(message, "\x00\r\n"); len(message) > 0 {
+			i.Codec.Decode(ctx, message, nil, msgChan)
+		}
+		if err != nil {
+			// EOF
+			return
+		}
+	}
+}
+
+type chunkedMessage struct {
+	chunks   [][]byte
+	received int
+	size     int
+	first    time.Time
+}
+
+// chunkAssembler reassembles chunked GELF datagrams by message id
+type chunkAssembler struct {
+	timeout  time.Duration
+	mutex    sync.Mutex
+	messages map[string]*chunkedMessage
+}
+
+func newChunkAssembler(timeout time.Duration) *chunkAssembler {
+	return &chunkAssembler{
+		timeout:  timeout,
+		messages: map[string]*chunkedMessage{},
+	}
+}
+
+// add stores a chunk, returns the whole message when all chunks are received
+func (a *chunkAssembler) add(packet []byte, now time.Time) (message []byte, complete bool, err error) {
+	if len(packet) < codecgelf.ChunkHeaderSize {
+		return nil, false, ErrorInvalidChunk2.New(nil, -1, -1)
+	}
+	id := string(packet[2:10])
+	seq, count := int(packet[10]), int(packet[11])
+	if count < 1 || count > codecgelf.MaxChunks || seq >= count {
+		return nil, false, ErrorInvalidChunk2.New(nil, seq, count)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	msg, ok := a.messages[id]
+	if !ok {
+		msg = &chunkedMessage{
+			chunks: make([][]byte, count),
+			first:  now,
+		}
+		a.messages[id] = msg
+	}
+	if len(msg.chunks) != count {
+		return nil, false, ErrorInvalidChunk2.New(nil, seq, count)
+	}
+	if msg.chunks[seq] != nil {
+		// duplicated chunk
+		return nil, false, nil
+	}
+	msg.chunks[seq] = append([]byte(nil), packet[codecgelf.ChunkHeaderSize:]...)
+	msg.received++
+	msg.size += len(msg.chunks[seq])
+	if msg.received < count {
+		return nil, false, nil
+	}
+
+	delete(a.messages, id)
+	message = make([]byte, 0, msg.size)
+	for _, chunk := range msg.chunks {
+		message = append(message, chunk...)
+	}
+	return message, true, nil
+}
+
+// expire drops incomplete messages older than timeout
+func (a *chunkAssembler) expire(now time.Time) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for id, msg := range a.messages {
+		if now.Sub(msg.first) > a.timeout {
+			goglog.Logger.Warnf("drop incomplete GELF message, %d of %d chunks received", msg.received, len(msg.chunks))
+			delete(a.messages, id)
+		}
+	}
+}
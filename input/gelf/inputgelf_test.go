@@ -0,0 +1,147 @@
+package inputgelf
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	codecgelf "github.com/viethqc/gogstash/codec/gelf"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+	config.RegistCodecHandler(codecgelf.ModuleName, codecgelf.InitHandler)
+}
+
+func chunk(id string, seq int, count int, data string) []byte {
+	packet := append([]byte(nil), codecgelf.ChunkMagic...)
+	packet = append(packet, id...)
+	packet = append(packet, byte(seq), byte(count))
+	return append(packet, data...)
+}
+
+func startInput(t *testing.T, raw config.ConfigRaw) (context.CancelFunc, chan logevent.LogEvent) {
+	require := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	conf, err := InitHandler(ctx, &raw)
+	require.NoError(err)
+	msgChan := make(chan logevent.LogEvent, 10)
+	go conf.Start(ctx, msgChan)
+	time.Sleep(200 * time.Millisecond)
+	return cancel, msgChan
+}
+
+func Test_input_gelf_module_udp(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	cancel, msgChan := startInput(t, config.ConfigRaw{"address": "127.0.0.1:12211"})
+	defer cancel()
+
+	conn, err := net.Dial("udp", "127.0.0.1:12211")
+	require.NoError(err)
+	defer conn.Close()
+
+	// plain datagram
+	_, err = conn.Write([]byte(`{"version":"1.1","host":"h","short_message":"plain"}`))
+	require.NoError(err)
+	select {
+	case event := <-msgChan:
+		assert.Equal("plain", event.Message)
+	case <-time.After(time.Second):
+		assert.Fail("timeout")
+	}
+
+	// chunked and compressed datagrams, sent out of order
+	data, err := codecgelf.Compress([]byte(`{"version":"1.1","host":"h","short_message":"chunked","_a":"b"}`), codecgelf.CompressionGzip, -1)
+	require.NoError(err)
+	half := len(data) / 2
+	_, err = conn.Write(chunk("abcdefgh", 1, 2, string(data[half:])))
+	require.NoError(err)
+	_, err = conn.Write(chunk("abcdefgh", 0, 2, string(data[:half])))
+	require.NoError(err)
+	select {
+	case event := <-msgChan:
+		assert.Equal("chunked", event.Message)
+		assert.Equal("b", event.Extra["a"])
+	case <-time.After(time.Second):
+		assert.Fail("timeout")
+	}
+}
+
+func Test_input_gelf_module_tcp(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	cancel, msgChan := startInput(t, config.ConfigRaw{"socket": "tcp", "address": "127.0.0.1:12212"})
+	defer cancel()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:12212")
+	require.NoError(err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("{\"short_message\":\"one\"}\x00{\"short_message\":\"two\"}\x00"))
+	require.NoError(err)
+	for _, expected := range []string{"one", "two"} {
+		select {
+		case event := <-msgChan:
+			assert.Equal(expected, event.Message)
+		case <-time.After(time.Second):
+			assert.Fail("timeout")
+		}
+	}
+}
+
+func TestChunkAssembler(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	now := time.Now()
+	a := newChunkAssembler(5 * time.Second)
+
+	_, complete, err := a.add(chunk("11111111", 0, 3, "a"), now)
+	require.NoError(err)
+	assert.False(complete)
+	// duplicated chunk is ignored
+	_, complete, err = a.add(chunk("11111111", 0, 3, "x"), now)
+	require.NoError(err)
+	assert.False(complete)
+	_, complete, err = a.add(chunk("11111111", 2, 3, "c"), now)
+	require.NoError(err)
+	assert.False(complete)
+	message, complete, err := a.add(chunk("11111111", 1, 3, "b"), now)
+	require.NoError(err)
+	assert.True(complete)
+	assert.Equal("abc", string(message))
+	assert.Len(a.messages, 0)
+
+	// invalid chunks
+	_, _, err = a.add(chunk("22222222", 3, 3, "a"), now)
+	require.Error(err)
+	_, _, err = a.add(chunk("22222222", 0, 129, "a"), now)
+	require.Error(err)
+	_, _, err = a.add([]byte{0x1e, 0x0f, 1}, now)
+	require.Error(err)
+
+	// incomplete message expired
+	_, complete, err = a.add(chunk("33333333", 0, 2, "a"), now)
+	require.NoError(err)
+	assert.False(complete)
+	a.expire(now.Add(time.Second))
+	assert.Len(a.messages, 1)
+	a.expire(now.Add(6 * time.Second))
+	assert.Len(a.messages, 0)
+}
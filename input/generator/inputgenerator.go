@@ -0,0 +1,218 @@
+package inputgenerator
+
+import (
+	"bufio"
+	"context"
+	"math/rand"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "generator"
+
+// orders of lines
+const (
+	OrderSequential = "sequential"
+	OrderRandom     = "random"
+)
+
+// SequenceField is the event field of sequence number, starts from 0
+const SequenceField = "sequence"
+
+// InputConfig holds the configuration json fields and internal objects
+type InputConfig struct {
+	config.InputConfig
+	Path             string   `json:"path,omitempty"`              // sample file, one message per line
+	Lines            []string `json:"lines,omitempty"`             // messages, used if path is empty
+	Order            string   `json:"order,omitempty"`             // one of ["sequential", "random"], default: "sequential"
+	Rate             float64  `json:"rate,omitempty"`              // events per second, 0 for unlimited, default: 0
+	RampUp           string   `json:"ramp_up,omitempty"`           // duration to increase rate from 0 to rate, default: "0"
+	Count            int64    `json:"count,omitempty"`             // number of events to send, 0 for unlimited, default: 0
+	Duration         string   `json:"duration,omitempty"`          // duration to send events, 0 for unlimited, default: "0"
+	RewriteTimestamp bool     `json:"rewrite_timestamp,omitempty"` // set event timestamp to now after decoding, default: false
+	TimestampRegexp  string   `json:"timestamp_regexp,omitempty"`  // timestamp text in lines replaced by now
+	TimestampLayout  string   `json:"timestamp_layout,omitempty"`  // golang time layout of replaced timestamp text
+
+	pacer     pacer
+	duration  time.Duration
+	timestamp *regexp.Regexp
+}
+
+// DefaultInputConfig returns an InputConfig struct with default values
+func DefaultInputConfig() InputConfig {
+	return InputConfig{
+		InputConfig: config.InputConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		Order:    OrderSequential,
+		RampUp:   "0",
+		Duration: "0",
+	}
+}
+
+// errors
+var (
+	ErrorNoLines               = errutil.NewFactory("no lines to generate events")
+	ErrorUnknownOrder1         = errutil.NewFactory("unknown order %q")
+	ErrorTimestampLayoutNeeded = errutil.NewFactory("timestamp_layout is required by timestamp_regexp")
+)
+
+// InitHandler initialize the input plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputConfig, error) {
+	conf := DefaultInputConfig()
+	err := config.ReflectConfig(raw, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.Path != "" {
+		if conf.Lines, err = readLines(conf.Path); err != nil {
+			return nil, err
+		}
+	}
+	if len(conf.Lines) < 1 {
+		return nil, ErrorNoLines.New(nil)
+	}
+
+	switch conf.Order {
+	case OrderSequential, OrderRandom:
+	default:
+		return nil, ErrorUnknownOrder1.New(nil, conf.Order)
+	}
+
+	conf.pacer.rate = conf.Rate
+	if conf.pacer.rampUp, err = time.ParseDuration(conf.RampUp); err != nil {
+		return nil, err
+	}
+	if conf.duration, err = time.ParseDuration(conf.Duration); err != nil {
+		return nil, err
+	}
+
+	if conf.TimestampRegexp != "" {
+		if conf.TimestampLayout == "" {
+			return nil, ErrorTimestampLayoutNeeded.New(nil)
+		}
+		if conf.timestamp, err = regexp.Compile(conf.TimestampRegexp); err != nil {
+			return nil, err
+		}
+	}
+
+	conf.Codec, err = config.GetCodec(ctx, *raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conf, nil
+}
+
+// readLines returns non-empty lines of file
+func readLines(path string) (lines []string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// Start wraps the actual function starting the plugin
+func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) (err error) {
+	logger := goglog.Logger
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	start := time.Now()
+	var stopTimer <-chan time.Time
+	if t.duration > 0 {
+		timer := time.NewTimer(t.duration)
+		defer timer.Stop()
+		stopTimer = timer.C
+	}
+
+	var sequence int64
+	for ; t.Count < 1 || sequence < t.Count; sequence++ {
+		if !wait(ctx, stopTimer, time.Until(start.Add(t.pacer.due(sequence)))) {
+			break
+		}
+
+		var line string
+		if t.Order == OrderRandom {
+			line = t.Lines[random.Intn(len(t.Lines))]
+		} else {
+			line = t.Lines[sequence%int64(len(t.Lines))]
+		}
+		t.send(ctx, line, sequence, msgChan)
+	}
+
+	logger.Infof("input generator sent %d events in %v", sequence, time.Since(start))
+	return nil
+}
+
+// wait sleeps for duration d, returns false if ctx done or stopTimer fired
+func wait(ctx context.Context, stopTimer <-chan time.Time, d time.Duration) bool {
+	if d <= 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-stopTimer:
+			return false
+		default:
+			return true
+		}
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-stopTimer:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (t *InputConfig) send(ctx context.Context, line string, sequence int64, msgChan chan<- logevent.LogEvent) {
+	now := time.Now()
+	if t.timestamp != nil {
+		if loc := t.timestamp.FindStringIndex(line); loc != nil {
+			line = line[:loc[0]] + now.Format(t.TimestampLayout) + line[loc[1]:]
+		}
+	}
+
+	extra := map[string]interface{}{
+		SequenceField: sequence,
+	}
+	if !t.RewriteTimestamp {
+		if _, err := t.Codec.Decode(ctx, line, extra, msgChan); err != nil {
+			goglog.Logger.Errorf("input generator decode error: %v", err)
+		}
+		return
+	}
+
+	_, err := config.DecodeFunc(ctx, t.Codec, line, extra, func(event logevent.LogEvent) {
+		event.Timestamp = now
+		msgChan <- event
+	})
+	if err != nil {
+		goglog.Logger.Errorf("input generator decode error: %v", err)
+	}
+}
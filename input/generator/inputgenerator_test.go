@@ -0,0 +1,156 @@
+package inputgenerator
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	codecjson "github.com/viethqc/gogstash/codec/json"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+	config.RegistInputHandler(ModuleName, InitHandler)
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
+	config.RegistCodecHandler(codecjson.ModuleName, codecjson.InitHandler)
+}
+
+func runGenerator(t *testing.T, raw config.ConfigRaw) []logevent.LogEvent {
+	require := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	input, err := InitHandler(ctx, &raw)
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 100)
+	require.NoError(input.Start(ctx, msgChan))
+	close(msgChan)
+
+	events := []logevent.LogEvent{}
+	for event := range msgChan {
+		events = append(events, event)
+	}
+	return events
+}
+
+func Test_input_generator_pacer(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	p := pacer{}
+	assert.Equal(time.Duration(0), p.due(100))
+
+	p = pacer{rate: 10}
+	assert.Equal(time.Duration(0), p.due(0))
+	assert.Equal(time.Second, p.due(10))
+
+	// 10 events during 2s ramp up, then 10 events per second
+	p = pacer{rate: 10, rampUp: 2 * time.Second}
+	assert.Equal(time.Duration(0), p.due(0))
+	assert.InDelta(float64(1414*time.Millisecond), float64(p.due(5)), float64(time.Millisecond))
+	assert.Equal(2*time.Second, p.due(10))
+	assert.Equal(3*time.Second, p.due(20))
+}
+
+func Test_input_generator_module(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	events := runGenerator(t, config.ConfigRaw{
+		"type":  ModuleName,
+		"lines": []string{"a", "b", "c"},
+		"count": 5,
+	})
+	messages := []string{}
+	for i, event := range events {
+		messages = append(messages, event.Message)
+		assert.EqualValues(i, event.Extra[SequenceField])
+	}
+	assert.Equal([]string{"a", "b", "c", "a", "b"}, messages)
+
+	events = runGenerator(t, config.ConfigRaw{
+		"type":  ModuleName,
+		"lines": []string{"a", "b", "c"},
+		"order": OrderRandom,
+		"count": 20,
+	})
+	if assert.Len(events, 20) {
+		for _, event := range events {
+			assert.Contains([]string{"a", "b", "c"}, event.Message)
+		}
+	}
+}
+
+func Test_input_generator_module_rate(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	start := time.Now()
+	events := runGenerator(t, config.ConfigRaw{
+		"type":  ModuleName,
+		"lines": []string{"a"},
+		"rate":  20,
+		"count": 11,
+	})
+	assert.Len(events, 11)
+	assert.InDelta(500*time.Millisecond, time.Since(start), float64(100*time.Millisecond))
+
+	start = time.Now()
+	events = runGenerator(t, config.ConfigRaw{
+		"type":     ModuleName,
+		"lines":    []string{"a"},
+		"rate":     20,
+		"duration": "300ms",
+	})
+	assert.InDelta(7, len(events), 1)
+	assert.InDelta(300*time.Millisecond, time.Since(start), float64(100*time.Millisecond))
+}
+
+func Test_input_generator_module_file(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	file, err := ioutil.TempFile("", "gogstash-generator")
+	require.NoError(err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("{\"@timestamp\":\"2001-02-03T04:05:06Z\",\"message\":\"json\"}\r\n\n2001-02-03 04:05:06 INFO text\n")
+	require.NoError(err)
+	require.NoError(file.Close())
+
+	start := time.Now()
+	events := runGenerator(t, config.ConfigRaw{
+		"type":              ModuleName,
+		"path":              file.Name(),
+		"count":             2,
+		"rewrite_timestamp": true,
+		"timestamp_regexp":  `^\d{4}-\d\d-\d\d \d\d:\d\d:\d\d `,
+		"timestamp_layout":  "2006-01-02 15:04:05 ",
+		"codec":             codecjson.ModuleName,
+	})
+	if assert.Len(events, 2) {
+		assert.Equal("json", events[0].Message)
+		assert.WithinDuration(start, events[0].Timestamp, time.Second)
+		assert.Contains(events[1].Tags, codecjson.ErrorTag)
+		assert.Equal(start.Format("2006-01-02")+" ", events[1].Message[:11])
+		assert.Contains(events[1].Message, " INFO text")
+		assert.NotContains(events[1].Message, "2001")
+	}
+
+	_, err = InitHandler(context.Background(), &config.ConfigRaw{"type": ModuleName})
+	assert.True(ErrorNoLines.Match(err))
+	_, err = InitHandler(context.Background(), &config.ConfigRaw{"type": ModuleName, "lines": []string{"a"}, "order": "reverse"})
+	assert.True(ErrorUnknownOrder1.Match(err))
+	_, err = InitHandler(context.Background(), &config.ConfigRaw{"type": ModuleName, "lines": []string{"a"}, "timestamp_regexp": "."})
+	assert.True(ErrorTimestampLayoutNeeded.Match(err))
+}
@@ -0,0 +1,33 @@
+package inputgenerator
+
+import (
+	"math"
+	"time"
+)
+
+// pacer computes when each event is due to reach the target rate,
+// the rate increases linearly from 0 during ramp up
+type pacer struct {
+	rate   float64 // events per second, 0 for unlimited
+	rampUp time.Duration
+}
+
+// due returns the time since start when the n-th event (0 based) should be sent
+func (p pacer) due(n int64) time.Duration {
+	if p.rate <= 0 {
+		return 0
+	}
+	count := float64(n)
+	rampUp := p.rampUp.Seconds()
+	// events sent during ramp up, area of the rate triangle
+	rampUpEvents := p.rate * rampUp / 2
+
+	var seconds float64
+	if count < rampUpEvents {
+		// count = rate * t^2 / (2 * rampUp)
+		seconds = math.Sqrt(2 * rampUp * count / p.rate)
+	} else {
+		seconds = rampUp + (count-rampUpEvents)/p.rate
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -3,14 +3,20 @@ package inputhttp
 import (
 	"bytes"
 	"context"
-	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
 	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
 	"github.com/viethqc/gogstash/config/logevent"
+	"github.com/viethqc/gogstash/config/scheduler"
 )
 
 // ModuleName is the name used in config file
@@ -19,14 +25,52 @@ const ModuleName = "http"
 // ErrorTag tag added to event when process module failed
 const ErrorTag = "gogstash_input_http_error"
 
+// DefaultURLName is the name of url configured by "url"
+const DefaultURLName = "default"
+
+// event fields
+const (
+	NameField            = "name"
+	MethodField          = "method"
+	StatusCodeField      = "status_code"
+	LatencyField         = "latency_ms"
+	ResponseHeadersField = "response_headers"
+	ErrorField           = "error"
+)
+
+// errors
+var (
+	ErrorNoURL              = errutil.NewFactory("no url configured")
+	ErrorEmptyURL1          = errutil.NewFactory("url of %q is empty")
+	ErrorUnsupportedMethod2 = errutil.NewFactory("unsupported method %q of url %q")
+)
+
 // InputConfig holds the configuration json fields and internal objects
 type InputConfig struct {
 	config.InputConfig
-	Method   string `json:"method,omitempty"` // one of ["HEAD", "GET"]
-	URL      string `json:"url"`
-	Interval int    `json:"interval,omitempty"`
+	scheduler.Config
+	Method          string               `json:"method,omitempty"` // one of ["HEAD", "GET", "POST", "PUT", "PATCH", "DELETE"]
+	URL             string               `json:"url"`
+	URLs            map[string]URLConfig `json:"urls"`
+	Body            string               `json:"body"`
+	Headers         map[string]string    `json:"headers"`
+	User            string               `json:"user"`
+	Password        string               `json:"password"`
+	BearerToken     string               `json:"bearer_token"`
+	Timeout         int                  `json:"timeout"`            // in seconds
+	Interval        int                  `json:"interval,omitempty"` // in seconds, used if neither schedule nor every is set
+	ResponseHeaders []string             `json:"response_headers"`
+
+	hostname  string
+	targets   []*target
+	scheduler *scheduler.Scheduler
+}
 
-	hostname string
+// target is a named url with resolved settings
+type target struct {
+	URLConfig
+	name   string
+	client *http.Client
 }
 
 // DefaultInputConfig returns an InputConfig struct with default values
@@ -38,6 +82,7 @@ func DefaultInputConfig() InputConfig {
 			},
 		},
 		Method:   "GET",
+		Timeout:  30,
 		Interval: 60,
 	}
 }
@@ -54,6 +99,14 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 		return nil, err
 	}
 
+	if err = conf.initTargets(); err != nil {
+		return nil, err
+	}
+
+	if conf.scheduler, err = scheduler.New(conf.Config, time.Duration(conf.Interval)*time.Second); err != nil {
+		return nil, err
+	}
+
 	conf.Codec, err = config.GetCodec(ctx, *raw)
 	if err != nil {
 		return nil, err
@@ -62,33 +115,102 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 	return &conf, nil
 }
 
-// Start wraps the actual function starting the plugin
-func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) (err error) {
-	startChan := make(chan bool, 1) // startup tick
-	ticker := time.NewTicker(time.Duration(t.Interval) * time.Second)
-	defer ticker.Stop()
-
-	startChan <- true
-
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case <-startChan:
-			t.Request(ctx, msgChan)
-		case <-ticker.C:
-			t.Request(ctx, msgChan)
+// initTargets resolves settings of named urls, sorted by name
+func (t *InputConfig) initTargets() error {
+	urls := map[string]URLConfig{}
+	for name, url := range t.URLs {
+		urls[name] = url
+	}
+	if t.URL != "" {
+		urls[DefaultURLName] = URLConfig{URL: t.URL}
+	}
+	if len(urls) < 1 {
+		return ErrorNoURL.New(nil)
+	}
+
+	for name, url := range urls {
+		if url.URL == "" {
+			return ErrorEmptyURL1.New(nil, name)
+		}
+		if url.Method == "" {
+			url.Method = t.Method
+		}
+		url.Method = strings.ToUpper(url.Method)
+		switch url.Method {
+		case http.MethodHead, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return ErrorUnsupportedMethod2.New(nil, url.Method, name)
+		}
+		if url.Body == "" {
+			url.Body = t.Body
+		}
+		headers := map[string]string{}
+		for k, v := range t.Headers {
+			headers[k] = v
+		}
+		for k, v := range url.Headers {
+			headers[k] = v
+		}
+		url.Headers = headers
+		if url.User == "" && url.BearerToken == "" {
+			url.User = t.User
+			url.Password = t.Password
+			url.BearerToken = t.BearerToken
 		}
+		if url.Timeout < 1 {
+			url.Timeout = t.Timeout
+		}
+
+		t.targets = append(t.targets, &target{
+			URLConfig: url,
+			name:      name,
+			client:    &http.Client{Timeout: time.Duration(url.Timeout) * time.Second},
+		})
 	}
+	sort.Slice(t.targets, func(i, j int) bool {
+		return t.targets[i].name < t.targets[j].name
+	})
+	return nil
+}
+
+// Start wraps the actual function starting the plugin
+func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) (err error) {
+	t.scheduler.Run(ctx, func(ctx context.Context) {
+		t.Request(ctx, msgChan)
+	})
+	return nil
 }
 
+// Request polls all urls concurrently
 func (t *InputConfig) Request(ctx context.Context, msgChan chan<- logevent.LogEvent) {
-	data, err := t.SendRequest()
+	wg := sync.WaitGroup{}
+	for _, tgt := range t.targets {
+		wg.Add(1)
+		go func(tgt *target) {
+			defer wg.Done()
+			t.poll(ctx, tgt, msgChan)
+		}(tgt)
+	}
+	wg.Wait()
+}
+
+func (t *InputConfig) poll(ctx context.Context, target *target, msgChan chan<- logevent.LogEvent) {
 	extra := map[string]interface{}{
-		"host": t.hostname,
-		"url":  t.URL,
+		"host":      t.hostname,
+		"url":       target.URL,
+		NameField:   target.name,
+		MethodField: target.Method,
 	}
+
+	start := time.Now()
+	res, data, err := t.SendRequest(ctx, target)
+	extra[LatencyField] = float64(time.Since(start)) / float64(time.Millisecond)
 	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		goglog.Logger.Warnf("input http: request %q error: %v", target.name, err)
+		extra[ErrorField] = err.Error()
 		event := logevent.LogEvent{
 			Timestamp: time.Now(),
 			Extra:     extra,
@@ -98,31 +220,51 @@ func (t *InputConfig) Request(ctx context.Context, msgChan chan<- logevent.LogEv
 		return
 	}
 
-	t.Codec.Decode(ctx, data, extra, msgChan)
+	extra[StatusCodeField] = res.StatusCode
+	if len(t.ResponseHeaders) > 0 {
+		headers := map[string]interface{}{}
+		for _, name := range t.ResponseHeaders {
+			if value := res.Header.Get(name); value != "" {
+				headers[strings.ToLower(name)] = value
+			}
+		}
+		extra[ResponseHeadersField] = headers
+	}
 
-	return
+	t.Codec.Decode(ctx, data, extra, msgChan)
 }
 
-func (t *InputConfig) SendRequest() (data []byte, err error) {
-	var (
-		res *http.Response
-		raw []byte
-	)
-	switch t.Method {
-	case "HEAD":
-		res, err = http.Head(t.URL)
-	case "GET":
-		res, err = http.Get(t.URL)
-	default:
-		err = errors.New("Unknown method")
+// SendRequest sends the request of target, returns the response and trimmed body
+func (t *InputConfig) SendRequest(ctx context.Context, target *target) (res *http.Response, data []byte, err error) {
+	var body io.Reader
+	if target.Body != "" {
+		body = strings.NewReader(target.Body)
 	}
-
+	req, err := http.NewRequest(target.Method, target.URL, body)
 	if err != nil {
 		return
 	}
+	req = req.WithContext(ctx)
+	for k, v := range target.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	if target.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+target.BearerToken)
+	} else if target.User != "" {
+		req.SetBasicAuth(target.User, target.Password)
+	}
 
+	if res, err = target.client.Do(req); err != nil {
+		return
+	}
 	defer res.Body.Close()
-	if raw, err = ioutil.ReadAll(res.Body); err != nil {
+
+	raw, err := ioutil.ReadAll(res.Body)
+	if err != nil {
 		return
 	}
 	data = bytes.TrimSpace(raw)
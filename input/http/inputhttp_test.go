@@ -2,7 +2,9 @@ package inputhttp
 
 import (
 	"context"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
@@ -13,6 +15,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+	"github.com/viethqc/gogstash/config/scheduler"
 )
 
 func init() {
@@ -62,3 +66,110 @@ input:
 		assert.Equal("foo", event.Message)
 	}
 }
+
+func Test_input_http_module_urls(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/status":
+			user, pass, _ := req.BasicAuth()
+			rw.Header().Set("X-Version", "1.2")
+			rw.Write([]byte(req.Method + " " + req.Header.Get("X-Env") + " " + user + ":" + pass))
+		case "/submit":
+			body, _ := ioutil.ReadAll(req.Body)
+			rw.WriteHeader(http.StatusCreated)
+			rw.Write([]byte(req.Method + " " + req.Header.Get("Authorization") + " " + string(body)))
+		case "/slow":
+			time.Sleep(2 * time.Second)
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conf, err := config.LoadFromYAML([]byte(strings.TrimSpace(`
+input:
+  - type: http
+    headers:
+      X-Env: prod
+    user: user
+    password: pass
+    response_headers: ["X-Version"]
+    urls:
+      status: "` + server.URL + `/status"
+      submit:
+        method: post
+        url: "` + server.URL + `/submit"
+        body: "ping"
+        bearer_token: "secret"
+      slow:
+        url: "` + server.URL + `/slow"
+        timeout: 1
+    codec:
+      type: "default"
+	`)))
+	require.NoError(err)
+	input, err := InitHandler(ctx, &conf.InputRaw[0])
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 3)
+	go input.Start(ctx, msgChan)
+
+	events := map[string]logevent.LogEvent{}
+	for i := 0; i < 3; i++ {
+		select {
+		case event := <-msgChan:
+			events[event.GetString(NameField)] = event
+		case <-time.After(3 * time.Second):
+			require.FailNow("timeout waiting for event")
+		}
+	}
+
+	event := events["status"]
+	assert.Equal("GET prod user:pass", event.Message)
+	assert.Equal(http.StatusOK, event.Extra[StatusCodeField])
+	assert.Equal(map[string]interface{}{"x-version": "1.2"}, event.Extra[ResponseHeadersField])
+	assert.IsType(float64(0), event.Extra[LatencyField])
+
+	event = events["submit"]
+	assert.Equal("POST Bearer secret ping", event.Message)
+	assert.Equal(http.StatusCreated, event.Extra[StatusCodeField])
+	assert.Equal("POST", event.Extra[MethodField])
+
+	event = events["slow"]
+	assert.Contains(event.Tags, ErrorTag)
+	assert.NotEmpty(event.Extra[ErrorField])
+	assert.NotContains(event.Extra, StatusCodeField)
+}
+
+func Test_input_http_module_config(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	ctx := context.Background()
+	for raw, factory := range map[string]interface{ Match(error) bool }{
+		`type: http`:                  ErrorNoURL,
+		`{type: http, urls: {a: ""}}`: ErrorEmptyURL1,
+		`{type: http, url: "http://127.0.0.1/", method: CONNECT}`:   ErrorUnsupportedMethod2,
+		`{type: http, url: "http://127.0.0.1/", schedule: "* * *"}`: scheduler.ErrorInvalidSchedule2,
+	} {
+		conf, err := config.LoadFromYAML([]byte("input:\n  - " + raw))
+		if assert.NoError(err) {
+			_, err = InitHandler(ctx, &conf.InputRaw[0])
+			assert.True(factory.Match(err), raw)
+		}
+	}
+
+	conf, err := config.LoadFromYAML([]byte(`input: [{type: http, url: "http://127.0.0.1/", schedule: "*/5 * * * *"}]`))
+	if assert.NoError(err) {
+		input, err := InitHandler(ctx, &conf.InputRaw[0])
+		if assert.NoError(err) {
+			next := input.(*InputConfig).scheduler.Next(time.Date(2021, 1, 1, 0, 1, 0, 0, time.UTC))
+			assert.Equal(time.Date(2021, 1, 1, 0, 5, 0, 0, time.UTC), next)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package inputhttp
+
+import (
+	"encoding/json"
+)
+
+// URLConfig is a request of a named url, empty fields are inherited from the input config
+type URLConfig struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	Body        string            `json:"body"`
+	Headers     map[string]string `json:"headers"`
+	User        string            `json:"user"`
+	Password    string            `json:"password"`
+	BearerToken string            `json:"bearer_token"`
+	Timeout     int               `json:"timeout"` // in seconds
+}
+
+// UnmarshalJSON decode json string as url only or object of request settings to URLConfig
+func (t *URLConfig) UnmarshalJSON(b []byte) (err error) {
+	var url string
+	if err = json.Unmarshal(b, &url); err == nil {
+		*t = URLConfig{URL: url}
+		return nil
+	}
+
+	type urlConfig URLConfig
+	conf := urlConfig{}
+	if err = json.Unmarshal(b, &conf); err != nil {
+		return err
+	}
+	*t = URLConfig(conf)
+	return nil
+}
@@ -0,0 +1,127 @@
+package inputhttplisten
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+)
+
+// modes to split request body into events
+const (
+	SplitAuto  = "auto"  // "lines" for NDJSON content type, "array" for JSON array body, otherwise "none"
+	SplitNone  = "none"  // the whole body is one payload
+	SplitLines = "lines" // each non-empty line is one payload
+	SplitArray = "array" // each element of JSON array is one payload
+)
+
+// content types of newline delimited JSON
+var ndjsonContentTypes = map[string]bool{
+	"application/x-ndjson":     true,
+	"application/ndjson":       true,
+	"application/jsonlines":    true,
+	"application/x-jsonlines":  true,
+	"application/x-json-lines": true,
+}
+
+// errors
+var (
+	ErrorInvalidSplit1         = errutil.NewFactory("%q is not a valid split mode")
+	ErrorBodyTooLarge1         = errutil.NewFactory("request body exceeds max_body_size %d")
+	ErrorUnsupportedEncoding1  = errutil.NewFactory("unsupported Content-Encoding %q")
+	ErrorInvalidJSONArrayBody1 = errutil.NewFactory("invalid JSON array body: %v")
+)
+
+func checkSplit(split string) error {
+	switch split {
+	case SplitAuto, SplitNone, SplitLines, SplitArray:
+		return nil
+	default:
+		return ErrorInvalidSplit1.New(nil, split)
+	}
+}
+
+// ReadBody reads the request body, decompressed if gzip encoded, up to maxSize bytes if positive,
+// returns the response status on error
+func ReadBody(req *http.Request, maxSize int64) ([]byte, int, error) {
+	var body io.Reader = req.Body
+	switch encoding := req.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return nil, http.StatusUnsupportedMediaType, ErrorUnsupportedEncoding1.New(nil, encoding)
+	}
+	if maxSize > 0 {
+		body = io.LimitReader(body, maxSize+1)
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return nil, http.StatusRequestEntityTooLarge, ErrorBodyTooLarge1.New(nil, maxSize)
+	}
+	return data, http.StatusOK, nil
+}
+
+// readPayloads reads the request body and splits it into payloads, returns the response status on error
+func (i *InputConfig) readPayloads(req *http.Request) ([][]byte, int, error) {
+	data, status, err := ReadBody(req, i.MaxBodySize)
+	if err != nil {
+		return nil, status, err
+	}
+
+	split := i.Split
+	if split == SplitAuto {
+		mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		trimmed := bytes.TrimSpace(data)
+		switch {
+		case ndjsonContentTypes[mediaType]:
+			split = SplitLines
+		case len(trimmed) > 0 && trimmed[0] == '[' && json.Valid(trimmed):
+			split = SplitArray
+		default:
+			split = SplitNone
+		}
+	}
+
+	switch split {
+	case SplitLines:
+		return splitLines(data), http.StatusOK, nil
+	case SplitArray:
+		var elements []json.RawMessage
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return nil, http.StatusBadRequest, ErrorInvalidJSONArrayBody1.New(err, err)
+		}
+		payloads := make([][]byte, len(elements))
+		for j, element := range elements {
+			payloads[j] = element
+		}
+		return payloads, http.StatusOK, nil
+	default:
+		return [][]byte{data}, http.StatusOK, nil
+	}
+}
+
+// splitLines returns non-empty lines of data
+func splitLines(data []byte) [][]byte {
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if line = bytes.TrimRight(line, "\r"); len(bytes.TrimSpace(line)) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
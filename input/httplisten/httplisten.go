@@ -2,10 +2,17 @@ package inputhttplisten
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
 	codecjson "github.com/viethqc/gogstash/codec/json"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/goglog"
@@ -18,6 +25,31 @@ const ModuleName = "httplisten"
 const invalidMethodError = "Method not allowed: '%v'"
 const invalidRequestError = "Invalid request received on HTTP listener. Decoder error: %+v"
 const invalidAccessToken = "Invalid access token. Access denied."
+const queueFullError = "Pipeline queue is full, retry later."
+const pipelinePausedError = "Pipeline is paused, retry later."
+
+// time to wait for the pipeline queue before answering 429
+const queueWait = time.Second
+
+// fields of request metadata added to events
+const (
+	RemoteAddrField = "remote_addr"
+	HeadersField    = "headers"
+)
+
+// client certificate verification modes
+const (
+	VerifyModeNone      = "none"
+	VerifyModePeer      = "peer"
+	VerifyModeForcePeer = "force_peer"
+)
+
+// headers never added to events by "*" of include_headers
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
 
 // InputConfig holds the configuration json fields and internal objects
 type InputConfig struct {
@@ -26,7 +58,22 @@ type InputConfig struct {
 	Path          string   `json:"path"`    // The path to accept json HTTP POST requests on
 	ServerCert    string   `json:"cert"`
 	ServerKey     string   `json:"key"`
-	RequireHeader []string `json:"require_header"` // Require this header to be present to accept the POST ("X-Access-Token: Potato")
+	RequireHeader []string `json:"require_header"` // Require these header name and value pairs to be present to accept the POST (["X-Access-Token", "Potato"])
+
+	// CA files to verify client certificates, and verification mode, one of ["none", "peer", "force_peer"]
+	SSLCertificateAuthorities []string `json:"ssl_certificate_authorities"`
+	SSLVerifyMode             string   `json:"ssl_verify_mode"`
+
+	BasicAuthUsername string `json:"basic_auth_username"`
+	BasicAuthPassword string `json:"basic_auth_password"`
+	BearerToken       string `json:"bearer_token"`
+
+	Split          string   `json:"split"`           // how to split body into events, one of ["auto", "none", "lines", "array"]
+	MaxBodySize    int64    `json:"max_body_size"`   // maximum size of decompressed request body in bytes
+	IncludeHeaders []string `json:"include_headers"` // request headers added to events, "*" for all but credentials
+	RetryAfter     int      `json:"retry_after"`     // seconds of Retry-After header when the pipeline is busy
+
+	tlsConfig *tls.Config
 }
 
 // DefaultInputConfig returns an InputConfig struct with default values
@@ -40,9 +87,21 @@ func DefaultInputConfig() InputConfig {
 		Address:       "0.0.0.0:8080",
 		Path:          "/",
 		RequireHeader: []string{},
+		SSLVerifyMode: VerifyModeNone,
+		Split:         SplitAuto,
+		MaxBodySize:   10485760,
+		RetryAfter:    1,
 	}
 }
 
+// errors
+var (
+	ErrorInvalidRequireHeader = errutil.NewFactory("require_header must be pairs of header name and value")
+	ErrorInvalidVerifyMode1   = errutil.NewFactory("%q is not a valid ssl_verify_mode")
+	ErrorNoCertificate1       = errutil.NewFactory("no certificate found in %q")
+	ErrorClientAuthWithoutTLS = errutil.NewFactory("ssl_verify_mode and ssl_certificate_authorities require cert and key")
+)
+
 // InitHandler initialize the input plugin
 func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputConfig, error) {
 	conf := DefaultInputConfig()
@@ -51,6 +110,16 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 		return nil, err
 	}
 
+	if len(conf.RequireHeader)%2 != 0 {
+		return nil, ErrorInvalidRequireHeader.New(nil)
+	}
+	if err = checkSplit(conf.Split); err != nil {
+		return nil, err
+	}
+	if conf.tlsConfig, err = conf.loadTLSConfig(); err != nil {
+		return nil, err
+	}
+
 	conf.Codec, err = config.GetCodecDefault(ctx, *raw, codecjson.ModuleName)
 	if err != nil {
 		return nil, err
@@ -59,10 +128,47 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 	return &conf, nil
 }
 
+func (i *InputConfig) loadTLSConfig() (*tls.Config, error) {
+	// client certificates are only verified in TLS mode, never fall back to plain HTTP silently
+	if (i.SSLVerifyMode != VerifyModeNone || len(i.SSLCertificateAuthorities) > 0) && !i.isTLS() {
+		return nil, ErrorClientAuthWithoutTLS.New(nil)
+	}
+	tlsConfig := &tls.Config{}
+	switch i.SSLVerifyMode {
+	case VerifyModeNone:
+		tlsConfig.ClientAuth = tls.NoClientCert
+	case VerifyModePeer:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case VerifyModeForcePeer:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, ErrorInvalidVerifyMode1.New(nil, i.SSLVerifyMode)
+	}
+	if len(i.SSLCertificateAuthorities) > 0 {
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		for _, path := range i.SSLCertificateAuthorities {
+			pem, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+				return nil, ErrorNoCertificate1.New(nil, path)
+			}
+		}
+	}
+	return tlsConfig, nil
+}
+
+// isTLS returns true if the server starts in TLS mode
+func (i *InputConfig) isTLS() bool {
+	return i.ServerCert != "" && i.ServerKey != ""
+}
+
 // Start wraps the actual function starting the plugin
 func (i *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) (err error) {
 	logger := goglog.Logger
-	http.HandleFunc(i.Path, func(rw http.ResponseWriter, req *http.Request) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(i.Path, func(rw http.ResponseWriter, req *http.Request) {
 		// Only allow POST requests (for now).
 		if req.Method != http.MethodPost {
 			logger.Warnf(invalidMethodError, req.Method)
@@ -70,56 +176,168 @@ func (i *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEven
 			rw.Write([]byte(fmt.Sprintf(invalidMethodError, req.Method)))
 			return
 		}
-		// Check for header
-		if len(i.RequireHeader) == 2 {
-			// get returns empty string if header not found
-			if req.Header.Get(i.RequireHeader[0]) != i.RequireHeader[1] {
-				logger.Warn(invalidAccessToken)
-				rw.WriteHeader(http.StatusForbidden)
-				rw.Write([]byte(invalidAccessToken))
-				return
-			}
+		if !i.authorize(rw, req) {
+			return
 		}
-		i.postHandler(msgChan, rw, req)
+		i.postHandler(ctx, msgChan, rw, req)
 	})
+	server := &http.Server{
+		Addr:      i.Address,
+		Handler:   mux,
+		TLSConfig: i.tlsConfig,
+	}
+
+	errChan := make(chan error, 1)
 	go func() {
 		logger.Infof("accepting POST requests to %s%s", i.Address, i.Path)
-		if i.ServerCert != "" && i.ServerKey != "" {
-			err = http.ListenAndServeTLS(i.Address, i.ServerCert, i.ServerKey, nil)
+		if i.isTLS() {
+			errChan <- server.ListenAndServeTLS(i.ServerCert, i.ServerKey)
 		} else {
-			err = http.ListenAndServe(i.Address, nil)
-		}
-		if err != nil {
-			logger.Fatal(err)
+			errChan <- server.ListenAndServe()
 		}
 	}()
-	return nil
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		logger.Info("input httplisten stopped")
+		return nil
+	case err = <-errChan:
+		return err
+	}
+}
+
+// authorize checks require_header, basic auth and bearer token, writes the error response if failed
+func (i *InputConfig) authorize(rw http.ResponseWriter, req *http.Request) bool {
+	logger := goglog.Logger
+	// Check for header
+	for j := 0; j+1 < len(i.RequireHeader); j += 2 {
+		// get returns empty string if header not found
+		if req.Header.Get(i.RequireHeader[j]) != i.RequireHeader[j+1] {
+			logger.Warn(invalidAccessToken)
+			rw.WriteHeader(http.StatusForbidden)
+			rw.Write([]byte(invalidAccessToken))
+			return false
+		}
+	}
+
+	if i.BasicAuthUsername == "" && i.BearerToken == "" {
+		return true
+	}
+	if username, password, ok := req.BasicAuth(); ok && i.BasicAuthUsername != "" &&
+		secureEqual(username, i.BasicAuthUsername) && secureEqual(password, i.BasicAuthPassword) {
+		return true
+	}
+	if auth := req.Header.Get("Authorization"); i.BearerToken != "" && strings.HasPrefix(auth, "Bearer ") &&
+		secureEqual(strings.TrimPrefix(auth, "Bearer "), i.BearerToken) {
+		return true
+	}
+
+	logger.Warn(invalidAccessToken)
+	if i.BasicAuthUsername != "" {
+		rw.Header().Set("WWW-Authenticate", `Basic realm="gogstash"`)
+	} else {
+		rw.Header().Set("WWW-Authenticate", `Bearer realm="gogstash"`)
+	}
+	rw.WriteHeader(http.StatusUnauthorized)
+	rw.Write([]byte(invalidAccessToken))
+	return false
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
 }
 
 // Handle HTTP POST requests
-func (i *InputConfig) postHandler(msgChan chan<- logevent.LogEvent, rw http.ResponseWriter, req *http.Request) {
+func (i *InputConfig) postHandler(ctx context.Context, msgChan chan<- logevent.LogEvent, rw http.ResponseWriter, req *http.Request) {
 	logger := goglog.Logger
 	logger.Debugf("Received request")
 
-	data, err := ioutil.ReadAll(req.Body)
+	if config.GetMutexInstance().GetPause() {
+		i.retryLater(rw, http.StatusServiceUnavailable, pipelinePausedError)
+		return
+	}
+
+	payloads, status, err := i.readPayloads(req)
 	if err != nil {
 		logger.Errorf("read request body error: %v", err)
+		rw.WriteHeader(status)
+		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	ok, err := i.Codec.Decode(context.TODO(), data, nil, msgChan)
-	if err != nil {
-		logger.Errorf("decode request body error: %v", err)
+	extra := i.requestExtra(req)
+	var events []logevent.LogEvent
+	var decodeErr error
+	for _, payload := range payloads {
+		decoded, err := i.decode(ctx, payload, extra)
+		if err != nil {
+			logger.Errorf("decode request body error: %v", err)
+			decodeErr = err
+		}
+		events = append(events, decoded...)
 	}
-	if !ok {
+	if len(events) < 1 && decodeErr != nil {
 		// event not sent to msgChan
 		rw.WriteHeader(http.StatusInternalServerError)
-		if err != nil {
-			rw.Write([]byte(err.Error()))
-		}
-	} else if err != nil {
+		rw.Write([]byte(decodeErr.Error()))
+		return
+	}
+
+	// none of events are accepted if the queue stays full, so the client can retry the whole request
+	if !config.SendEvents(ctx, msgChan, events, queueWait) {
+		i.retryLater(rw, http.StatusTooManyRequests, queueFullError)
+		return
+	}
+
+	if decodeErr != nil {
 		// event sent to msgChan
 		rw.WriteHeader(http.StatusBadRequest)
-		rw.Write([]byte(fmt.Sprintf(invalidRequestError, err)))
+		rw.Write([]byte(fmt.Sprintf(invalidRequestError, decodeErr)))
+	}
+}
+
+func (i *InputConfig) retryLater(rw http.ResponseWriter, status int, message string) {
+	goglog.Logger.Warn(message)
+	rw.Header().Set("Retry-After", strconv.Itoa(i.RetryAfter))
+	rw.WriteHeader(status)
+	rw.Write([]byte(message))
+}
+
+// decode returns events decoded from payload by codec, each payload gets its own copy of extra
+func (i *InputConfig) decode(ctx context.Context, payload []byte, extra map[string]interface{}) ([]logevent.LogEvent, error) {
+	eventExtra := make(map[string]interface{}, len(extra))
+	for k, v := range extra {
+		eventExtra[k] = v
+	}
+	return config.DecodeEvents(ctx, i.Codec, payload, eventExtra)
+}
+
+// requestExtra returns client address and headers of request
+func (i *InputConfig) requestExtra(req *http.Request) map[string]interface{} {
+	extra := map[string]interface{}{
+		RemoteAddrField: req.RemoteAddr,
+	}
+	if len(i.IncludeHeaders) < 1 {
+		return extra
+	}
+
+	headers := map[string]interface{}{}
+	for _, name := range i.IncludeHeaders {
+		if name == "*" {
+			for key, values := range req.Header {
+				if !sensitiveHeaders[key] && len(values) > 0 {
+					headers[strings.ToLower(key)] = values[0]
+				}
+			}
+		} else if value := req.Header.Get(name); value != "" {
+			headers[strings.ToLower(name)] = value
+		}
+	}
+	if len(headers) > 0 {
+		extra[HeadersField] = headers
 	}
+	return extra
 }
@@ -2,9 +2,11 @@ package inputhttplisten
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -17,6 +19,7 @@ import (
 	codecjson "github.com/viethqc/gogstash/codec/json"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
 )
 
 func init() {
@@ -54,7 +57,7 @@ input:
 	assert.Equal([]byte{}, data)
 
 	if event, err := conf.TestGetOutputEvent(100 * time.Millisecond); assert.NoError(err) {
-		assert.Equal(map[string]interface{}{"foo": "bar"}, event.Extra)
+		assert.Equal("bar", event.Extra["foo"])
 	}
 }
 
@@ -98,6 +101,162 @@ input:
 	assert.Equal([]byte{}, data)
 
 	if event, err := conf.TestGetOutputEvent(100 * time.Millisecond); assert.NoError(err) {
-		assert.Equal(map[string]interface{}{"foo": "bar"}, event.Extra)
+		assert.Equal("bar", event.Extra["foo"])
 	}
 }
+
+func Test_input_httplisten_module_bulk(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conf, err := config.LoadFromYAML([]byte(strings.TrimSpace(`
+input:
+  - type: httplisten
+    address: "127.0.0.1:8090"
+    path: "/bulk"
+    require_header: ["X-Tenant", "a", "X-Env", "prod"]
+    bearer_token: "secret"
+    basic_auth_username: "user"
+    basic_auth_password: "pass"
+    include_headers: ["*"]
+    retry_after: 5
+	`)))
+	require.NoError(err)
+	input, err := InitHandler(ctx, &conf.InputRaw[0])
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 3)
+	done := make(chan error, 1)
+	go func() {
+		done <- input.Start(ctx, msgChan)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	post := func(body []byte, headers map[string]string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:8090/bulk", bytes.NewReader(body))
+		require.NoError(err)
+		req.Header.Set("X-Tenant", "a")
+		req.Header.Set("X-Env", "prod")
+		req.Header.Set("Authorization", "Bearer secret")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(err)
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		require.NoError(err)
+		return resp, string(data)
+	}
+	receive := func(count int) (messages []string) {
+		for j := 0; j < count; j++ {
+			select {
+			case event := <-msgChan:
+				messages = append(messages, event.Message)
+				assert.Contains(event.Extra[RemoteAddrField], "127.0.0.1:")
+				if headers, ok := event.Extra[HeadersField].(map[string]interface{}); assert.True(ok) {
+					assert.Equal("a", headers["x-tenant"])
+					assert.NotContains(headers, "authorization")
+				}
+			case <-time.After(time.Second):
+				require.FailNow("timeout waiting for event")
+			}
+		}
+		return
+	}
+
+	// NDJSON
+	resp, _ := post([]byte("{\"message\":\"a\"}\n\n{\"message\":\"b\"}\r\n"), map[string]string{"Content-Type": "application/x-ndjson"})
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal([]string{"a", "b"}, receive(2))
+
+	// gzip JSON array with basic auth
+	gzBody := bytes.Buffer{}
+	gz := gzip.NewWriter(&gzBody)
+	gz.Write([]byte(`[{"message":"c"}, {"message":"d"}]`))
+	gz.Close()
+	headers := map[string]string{
+		"Content-Encoding": "gzip",
+		"Authorization":    "Basic dXNlcjpwYXNz", // user:pass
+	}
+	resp, _ = post(gzBody.Bytes(), headers)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal([]string{"c", "d"}, receive(2))
+
+	// auth failures
+	resp, _ = post([]byte(`{"message":"e"}`), map[string]string{"Authorization": "Bearer wrong"})
+	assert.Equal(http.StatusUnauthorized, resp.StatusCode)
+	assert.NotEmpty(resp.Header.Get("WWW-Authenticate"))
+	resp, _ = post([]byte(`{"message":"e"}`), map[string]string{"X-Env": "dev"})
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+
+	// queue full, none of events accepted
+	for j := 0; j < cap(msgChan); j++ {
+		msgChan <- logevent.LogEvent{}
+	}
+	resp, body := post([]byte(`[{"message":"f"}, {"message":"g"}]`), nil)
+	assert.Equal(http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal("5", resp.Header.Get("Retry-After"))
+	assert.Equal(queueFullError, body)
+	assert.Len(msgChan, cap(msgChan))
+	for j := 0; j < cap(msgChan); j++ {
+		<-msgChan
+	}
+
+	// more events than the queue capacity are accepted while the queue drains
+	ndjson := bytes.Buffer{}
+	expected := []string{}
+	for j := 0; j < 10; j++ {
+		message := fmt.Sprintf("m%d", j)
+		expected = append(expected, message)
+		ndjson.WriteString(`{"message":"` + message + `"}` + "\n")
+	}
+	received := make(chan []string, 1)
+	go func() {
+		received <- receive(len(expected))
+	}()
+	resp, _ = post(ndjson.Bytes(), map[string]string{"Content-Type": "application/x-ndjson"})
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(expected, <-received)
+
+	cancel()
+	select {
+	case err = <-done:
+		assert.NoError(err)
+	case <-time.After(2 * time.Second):
+		assert.Fail("input not stopped")
+	}
+
+	// listen error is returned
+	input, err = InitHandler(context.Background(), &config.ConfigRaw{"address": "127.0.0.1:-1"})
+	require.NoError(err)
+	assert.Error(input.Start(context.Background(), msgChan))
+
+	_, err = InitHandler(context.Background(), &config.ConfigRaw{"require_header": []string{"X-Token"}})
+	assert.Error(err)
+	_, err = InitHandler(context.Background(), &config.ConfigRaw{"split": "csv"})
+	assert.Error(err)
+	_, err = InitHandler(context.Background(), &config.ConfigRaw{"ssl_verify_mode": "always"})
+	assert.Error(err)
+	_, err = InitHandler(context.Background(), &config.ConfigRaw{
+		"cert":                        "./leaf.pem",
+		"key":                         "./leaf.key",
+		"ssl_certificate_authorities": []string{"./root.pem"},
+		"ssl_verify_mode":             "force_peer",
+	})
+	assert.NoError(err)
+	// client certificates can not be verified without TLS
+	_, err = InitHandler(context.Background(), &config.ConfigRaw{"ssl_verify_mode": "force_peer"})
+	assert.Error(err)
+	_, err = InitHandler(context.Background(), &config.ConfigRaw{"ssl_certificate_authorities": []string{"./root.pem"}})
+	assert.Error(err)
+	_, err = InitHandler(context.Background(), &config.ConfigRaw{
+		"cert":            "./leaf.pem",
+		"ssl_verify_mode": "peer",
+	})
+	assert.Error(err)
+}
@@ -0,0 +1,110 @@
+package inputjournald
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+)
+
+// maximum size of a binary field, protects from reading garbage lengths
+const maxFieldSize = 64 * 1024 * 1024
+
+// errors
+var (
+	ErrorInvalidField1   = errutil.NewFactory("invalid journal export field %q")
+	ErrorFieldTooLarge2  = errutil.NewFactory("journal export field %q too large: %d bytes")
+	ErrorFieldNotEnded1  = errutil.NewFactory("journal export field %q not ended by newline")
+	ErrorTruncatedEntry1 = errutil.NewFactory("journal export entry truncated in field %q")
+)
+
+// exportReader parses the journal export format, see
+// https://systemd.io/JOURNAL_EXPORT_FORMATS/
+//
+// Entries are separated by an empty line, each field is either a
+// "KEY=value" line, or for binary safe values a "KEY" line followed by
+// the value size as 64 bit little endian, the value and a newline.
+type exportReader struct {
+	reader *bufio.Reader
+}
+
+func newExportReader(r io.Reader) *exportReader {
+	return &exportReader{reader: bufio.NewReader(r)}
+}
+
+// Next returns fields of the next entry, io.EOF at the end of stream
+func (r *exportReader) Next() (map[string]string, error) {
+	entry := map[string]string{}
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil {
+			if err != io.EOF {
+				return nil, err
+			}
+			// last field without trailing newline
+			if len(line) > 0 {
+				if err = r.addLine(entry, line); err != nil {
+					return nil, err
+				}
+			}
+			if len(entry) > 0 {
+				return entry, nil
+			}
+			return nil, io.EOF
+		}
+		line = line[:len(line)-1]
+
+		if len(line) == 0 {
+			if len(entry) > 0 {
+				return entry, nil
+			}
+			// skip extra empty lines between entries
+			continue
+		}
+
+		if bytes.IndexByte(line, '=') >= 0 {
+			if err = r.addLine(entry, line); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err = r.readBinary(entry, string(line)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *exportReader) addLine(entry map[string]string, line []byte) error {
+	idx := bytes.IndexByte(line, '=')
+	if idx <= 0 {
+		return ErrorInvalidField1.New(nil, string(line))
+	}
+	entry[string(line[:idx])] = string(line[idx+1:])
+	return nil
+}
+
+func (r *exportReader) readBinary(entry map[string]string, key string) error {
+	var size uint64
+	if err := binary.Read(r.reader, binary.LittleEndian, &size); err != nil {
+		return ErrorTruncatedEntry1.New(err, key)
+	}
+	if size > maxFieldSize {
+		return ErrorFieldTooLarge2.New(nil, key, size)
+	}
+	value := make([]byte, size)
+	if _, err := io.ReadFull(r.reader, value); err != nil {
+		return ErrorTruncatedEntry1.New(err, key)
+	}
+	end, err := r.reader.ReadByte()
+	if err != nil {
+		return ErrorTruncatedEntry1.New(err, key)
+	}
+	if end != '\n' {
+		return ErrorFieldNotEnded1.New(nil, key)
+	}
+	entry[key] = string(value)
+	return nil
+}
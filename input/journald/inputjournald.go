@@ -0,0 +1,287 @@
+package inputjournald
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "journald"
+
+// ErrorTag tag added to event when process module failed
+const ErrorTag = "gogstash_input_journald_error"
+
+// journal fields of special meaning
+const (
+	MessageField  = "MESSAGE"
+	CursorField   = "__CURSOR"
+	RealtimeField = "__REALTIME_TIMESTAMP"
+)
+
+// positions to start reading when no cursor saved in sincedb
+const (
+	SeekHead = "head"
+	SeekTail = "tail"
+)
+
+// InputConfig holds the configuration json fields and internal objects
+type InputConfig struct {
+	config.InputConfig
+	Path                 string   `json:"path,omitempty"`            // read export format from the file, "-" for stdin, instead of running journalctl
+	JournalctlPath       string   `json:"journalctl_path,omitempty"` // default: "journalctl"
+	Directory            string   `json:"directory,omitempty"`       // journal directory, default: system journal
+	Units                []string `json:"units,omitempty"`           // only entries of the systemd units
+	Matches              []string `json:"matches,omitempty"`         // journalctl matches, e.g. "_TRANSPORT=kernel"
+	Seek                 string   `json:"seek,omitempty"`            // one of ["head", "tail"] without saved cursor, default: "tail"
+	RestartDelay         int      `json:"restart_delay"`             // seconds to restart journalctl when it exits
+	SinceDBPath          string   `json:"sincedb_path,omitempty"`
+	SinceDBWriteInterval int      `json:"sincedb_write_interval,omitempty"`
+
+	cursor      string
+	savedCursor string
+	cursorMutex sync.Mutex
+}
+
+// DefaultInputConfig returns an InputConfig struct with default values
+func DefaultInputConfig() InputConfig {
+	return InputConfig{
+		InputConfig: config.InputConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		JournalctlPath:       "journalctl",
+		Seek:                 SeekTail,
+		RestartDelay:         5,
+		SinceDBPath:          ".sincedb_journald.json",
+		SinceDBWriteInterval: 15,
+	}
+}
+
+// errors
+var (
+	ErrorUnknownSeek1 = errutil.NewFactory("unknown seek %q")
+)
+
+// InitHandler initialize the input plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputConfig, error) {
+	conf := DefaultInputConfig()
+	err := config.ReflectConfig(raw, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	switch conf.Seek {
+	case SeekHead, SeekTail:
+	default:
+		return nil, ErrorUnknownSeek1.New(nil, conf.Seek)
+	}
+
+	if err = conf.loadSinceDB(); err != nil {
+		return nil, err
+	}
+
+	conf.Codec, err = config.GetCodec(ctx, *raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conf, nil
+}
+
+// Start wraps the actual function starting the plugin,
+// returns at the end of stream when reading from path
+func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) (err error) {
+	saveCtx, cancel := context.WithCancel(ctx)
+	saveDone := make(chan struct{})
+	go func() {
+		defer close(saveDone)
+		t.saveSinceDBLoop(saveCtx)
+	}()
+	defer func() {
+		cancel()
+		<-saveDone
+		if saveErr := t.saveSinceDB(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}()
+
+	if t.Path != "" {
+		return t.readPath(ctx, msgChan)
+	}
+	return t.follow(ctx, msgChan)
+}
+
+// readPath reads export format from path to the end, skipping entries up to the saved cursor
+func (t *InputConfig) readPath(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
+	var r io.Reader = os.Stdin
+	if t.Path != "-" {
+		fp, err := os.Open(t.Path)
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		r = fp
+	}
+
+	return t.readEntries(ctx, r, t.getCursor(), msgChan)
+}
+
+// follow runs journalctl until ctx done, restarts it after the saved cursor when it exits
+func (t *InputConfig) follow(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
+	logger := goglog.Logger
+	delay := time.Duration(t.RestartDelay) * time.Second
+	for {
+		err := t.runJournalctl(ctx, msgChan)
+		select {
+		case <-ctx.Done():
+			logger.Info("input journald stopped")
+			return nil
+		default:
+		}
+
+		if err != nil {
+			logger.Warnf("input journald: journalctl exited: %v, restart in %v", err, delay)
+		} else {
+			logger.Warnf("input journald: journalctl exited, restart in %v", delay)
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info("input journald stopped")
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (t *InputConfig) runJournalctl(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
+	cmd := exec.CommandContext(ctx, t.JournalctlPath, t.journalctlArgs(t.getCursor())...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	readErr := t.readEntries(ctx, stdout, "", msgChan)
+	if readErr != nil {
+		// unblock journalctl writing to the pipe
+		cmd.Process.Kill()
+	}
+	if err = cmd.Wait(); readErr != nil {
+		return readErr
+	}
+	return err
+}
+
+// journalctlArgs returns arguments of journalctl following the journal after cursor
+func (t *InputConfig) journalctlArgs(cursor string) []string {
+	args := []string{"--output=export", "--follow"}
+	if t.Directory != "" {
+		args = append(args, "--directory="+t.Directory)
+	}
+	switch {
+	case cursor != "":
+		args = append(args, "--after-cursor="+cursor)
+	case t.Seek == SeekHead:
+		args = append(args, "--no-tail")
+	default:
+		args = append(args, "--lines=0")
+	}
+	for _, unit := range t.Units {
+		args = append(args, "--unit="+unit)
+	}
+	return append(args, t.Matches...)
+}
+
+// readEntries sends entries of export format stream r as events, entries up to cursor skipTo are skipped,
+// skipped entries are sent at the end of stream if skipTo is not found, e.g. a cursor of another journal
+func (t *InputConfig) readEntries(ctx context.Context, r io.Reader, skipTo string, msgChan chan<- logevent.LogEvent) error {
+	reader := newExportReader(r)
+	var skipped []map[string]string
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			if skipTo != "" {
+				goglog.Logger.Warnf("input journald: cursor %q not found, sending %d entries skipped", skipTo, len(skipped))
+				for _, entry := range skipped {
+					if !t.sendEntry(ctx, entry, msgChan) {
+						return nil
+					}
+				}
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if skipTo != "" {
+			if entry[CursorField] == skipTo {
+				skipTo, skipped = "", nil
+			} else {
+				skipped = append(skipped, entry)
+			}
+			continue
+		}
+
+		if !t.sendEntry(ctx, entry, msgChan) {
+			return nil
+		}
+	}
+}
+
+// sendEntry sends entry as event and saves its cursor, returns false if ctx done
+func (t *InputConfig) sendEntry(ctx context.Context, entry map[string]string, msgChan chan<- logevent.LogEvent) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case msgChan <- t.newEvent(entry):
+	}
+	if cursor, ok := entry[CursorField]; ok {
+		t.setCursor(cursor)
+	}
+	return true
+}
+
+// newEvent decodes MESSAGE by codec, other fields are kept as is except
+// journal address fields prefixed by "__"
+func (t *InputConfig) newEvent(entry map[string]string) logevent.LogEvent {
+	event := logevent.LogEvent{}
+	if err := t.Codec.DecodeEvent([]byte(entry[MessageField]), &event); err != nil {
+		goglog.Logger.Errorf("input journald: decode message error: %v", err)
+		event = logevent.LogEvent{Message: entry[MessageField]}
+		event.AddTag(ErrorTag)
+	}
+
+	if usec, err := strconv.ParseInt(entry[RealtimeField], 10, 64); err == nil {
+		event.Timestamp = time.Unix(0, usec*int64(time.Microsecond)).UTC()
+	} else if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	if event.Extra == nil {
+		event.Extra = map[string]interface{}{}
+	}
+	for key, value := range entry {
+		if key == MessageField || strings.HasPrefix(key, "__") {
+			continue
+		}
+		if _, exists := event.Extra[key]; !exists {
+			event.Extra[key] = value
+		}
+	}
+	return event
+}
@@ -0,0 +1,290 @@
+package inputjournald
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	codecjson "github.com/viethqc/gogstash/codec/json"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+	config.RegistInputHandler(ModuleName, InitHandler)
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
+	config.RegistCodecHandler(codecjson.ModuleName, codecjson.InitHandler)
+}
+
+// binaryField returns a field in binary safe export format
+func binaryField(key string, value string) string {
+	buf := bytes.Buffer{}
+	buf.WriteString(key + "\n")
+	binary.Write(&buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+	return buf.String()
+}
+
+func exportEntry(cursor string, usec string, message string) string {
+	return "__CURSOR=" + cursor + "\n" +
+		"__REALTIME_TIMESTAMP=" + usec + "\n" +
+		"__MONOTONIC_TIMESTAMP=1234\n" +
+		"_SYSTEMD_UNIT=nginx.service\n" +
+		"PRIORITY=6\n" +
+		"_PID=42\n" +
+		"MESSAGE=" + message + "\n" +
+		"\n"
+}
+
+func Test_input_journald_export(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	data := exportEntry("s=1", "1546563336000000", "first") +
+		"\n" +
+		"__CURSOR=s=2\n" +
+		binaryField("MESSAGE", "multi\nline\x00binary") +
+		"PRIORITY=3\n" +
+		"\n" +
+		"__CURSOR=s=3\n" +
+		"MESSAGE=no trailing newline"
+
+	reader := newExportReader(strings.NewReader(data))
+
+	entry, err := reader.Next()
+	require.NoError(err)
+	assert.Equal("s=1", entry[CursorField])
+	assert.Equal("nginx.service", entry["_SYSTEMD_UNIT"])
+	assert.Equal("6", entry["PRIORITY"])
+	assert.Equal("42", entry["_PID"])
+	assert.Equal("first", entry[MessageField])
+
+	entry, err = reader.Next()
+	require.NoError(err)
+	assert.Equal("s=2", entry[CursorField])
+	assert.Equal("multi\nline\x00binary", entry[MessageField])
+	assert.Equal("3", entry["PRIORITY"])
+
+	entry, err = reader.Next()
+	require.NoError(err)
+	assert.Equal("s=3", entry[CursorField])
+	assert.Equal("no trailing newline", entry[MessageField])
+
+	_, err = reader.Next()
+	assert.Equal(io.EOF, err)
+
+	// binary field truncated
+	reader = newExportReader(strings.NewReader("MESSAGE\n\x10\x00\x00\x00\x00\x00\x00\x00short"))
+	_, err = reader.Next()
+	assert.Error(err)
+
+	// field without value
+	reader = newExportReader(strings.NewReader("=value\n\n"))
+	_, err = reader.Next()
+	assert.Error(err)
+}
+
+func readJournald(t *testing.T, raw config.ConfigRaw) []logevent.LogEvent {
+	require := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	input, err := InitHandler(ctx, &raw)
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 100)
+	require.NoError(input.Start(ctx, msgChan))
+	close(msgChan)
+
+	events := []logevent.LogEvent{}
+	for event := range msgChan {
+		events = append(events, event)
+	}
+	return events
+}
+
+func Test_input_journald_path(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-journald")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "journal.export")
+	sincedb := filepath.Join(dir, "sincedb.json")
+	data := exportEntry("s=1", "1546563336000000", "first") +
+		exportEntry("s=2", "1546563337500000", "second")
+	require.NoError(ioutil.WriteFile(path, []byte(data), 0644))
+
+	events := readJournald(t, config.ConfigRaw{
+		"path":         path,
+		"sincedb_path": sincedb,
+	})
+	require.Len(events, 2)
+	assert.Equal("first", events[0].Message)
+	assert.Equal(time.Date(2019, time.January, 4, 0, 55, 36, 0, time.UTC), events[0].Timestamp)
+	assert.Equal("nginx.service", events[0].GetString("_SYSTEMD_UNIT"))
+	assert.Equal("6", events[0].GetString("PRIORITY"))
+	assert.Equal("42", events[0].GetString("_PID"))
+	assert.NotContains(events[0].Extra, MessageField)
+	assert.NotContains(events[0].Extra, CursorField)
+	assert.NotContains(events[0].Extra, "__MONOTONIC_TIMESTAMP")
+	assert.Equal("second", events[1].Message)
+	assert.Equal(time.Date(2019, time.January, 4, 0, 55, 37, 500000000, time.UTC), events[1].Timestamp)
+
+	saved, err := ioutil.ReadFile(sincedb)
+	require.NoError(err)
+	assert.Contains(string(saved), `"cursor":"s=2"`)
+
+	// resume after the saved cursor
+	fp, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(err)
+	_, err = fp.WriteString(exportEntry("s=3", "1546563338000000", "third"))
+	require.NoError(err)
+	require.NoError(fp.Close())
+
+	events = readJournald(t, config.ConfigRaw{
+		"path":         path,
+		"sincedb_path": sincedb,
+	})
+	require.Len(events, 1)
+	assert.Equal("third", events[0].Message)
+
+	// stale cursor not in the stream, e.g. of another journal, all entries are sent
+	require.NoError(ioutil.WriteFile(sincedb, []byte(`{"cursor":"s=other"}`), 0644))
+	events = readJournald(t, config.ConfigRaw{
+		"path":         path,
+		"sincedb_path": sincedb,
+	})
+	require.Len(events, 3)
+	assert.Equal("first", events[0].Message)
+	assert.Equal("third", events[2].Message)
+	saved, err = ioutil.ReadFile(sincedb)
+	require.NoError(err)
+	assert.Contains(string(saved), `"cursor":"s=3"`)
+}
+
+func Test_input_journald_codec(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-journald")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "journal.export")
+	data := exportEntry("s=1", "1546563336000000", `{"message":"json message","PRIORITY":"from message"}`)
+	require.NoError(ioutil.WriteFile(path, []byte(data), 0644))
+
+	events := readJournald(t, config.ConfigRaw{
+		"path":         path,
+		"sincedb_path": "/dev/null",
+		"codec":        "json",
+	})
+	require.Len(events, 1)
+	assert.Equal("json message", events[0].Message)
+	assert.Equal("from message", events[0].GetString("PRIORITY"))
+	assert.Equal("nginx.service", events[0].GetString("_SYSTEMD_UNIT"))
+}
+
+func Test_input_journald_journalctl_args(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	conf := DefaultInputConfig()
+	assert.Equal([]string{"--output=export", "--follow", "--lines=0"}, conf.journalctlArgs(""))
+
+	conf.Seek = SeekHead
+	conf.Directory = "/var/log/journal"
+	conf.Units = []string{"nginx.service", "sshd.service"}
+	conf.Matches = []string{"_TRANSPORT=syslog"}
+	assert.Equal([]string{
+		"--output=export", "--follow", "--directory=/var/log/journal", "--no-tail",
+		"--unit=nginx.service", "--unit=sshd.service", "_TRANSPORT=syslog",
+	}, conf.journalctlArgs(""))
+	assert.Equal([]string{
+		"--output=export", "--follow", "--directory=/var/log/journal", "--after-cursor=s=1",
+		"--unit=nginx.service", "--unit=sshd.service", "_TRANSPORT=syslog",
+	}, conf.journalctlArgs("s=1"))
+
+	raw := config.ConfigRaw{"seek": "middle"}
+	_, err := InitHandler(context.Background(), &raw)
+	assert.Error(err)
+}
+
+func Test_input_journald_journalctl(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-journald")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// fake journalctl records its arguments and prints the export file
+	export := filepath.Join(dir, "journal.export")
+	argsFile := filepath.Join(dir, "args")
+	journalctl := filepath.Join(dir, "journalctl")
+	require.NoError(ioutil.WriteFile(export, []byte(exportEntry("s=2", "1546563337000000", "second")), 0644))
+	require.NoError(ioutil.WriteFile(journalctl, []byte("#!/bin/sh\n"+
+		"echo \"$@\" > "+argsFile+"\n"+
+		"cat "+export+"\n"+
+		"sleep 10\n"), 0755))
+	sincedb := filepath.Join(dir, "sincedb.json")
+	require.NoError(ioutil.WriteFile(sincedb, []byte(`{"cursor":"s=1"}`), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	raw := config.ConfigRaw{
+		"journalctl_path": journalctl,
+		"sincedb_path":    sincedb,
+		"units":           []interface{}{"nginx.service"},
+	}
+	input, err := InitHandler(ctx, &raw)
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- input.Start(ctx, msgChan)
+	}()
+
+	select {
+	case event := <-msgChan:
+		assert.Equal("second", event.Message)
+		assert.Equal("nginx.service", event.GetString("_SYSTEMD_UNIT"))
+	case <-time.After(5 * time.Second):
+		require.Fail("no event from journalctl")
+	}
+
+	cancel()
+	require.NoError(<-done)
+
+	args, err := ioutil.ReadFile(argsFile)
+	require.NoError(err)
+	assert.Equal("--output=export --follow --after-cursor=s=1 --unit=nginx.service\n", string(args))
+
+	saved, err := ioutil.ReadFile(sincedb)
+	require.NoError(err)
+	assert.Contains(string(saved), `"cursor":"s=2"`)
+}
@@ -0,0 +1,104 @@
+package inputjournald
+
+import (
+	"context"
+	"io/ioutil"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/viethqc/gogstash/KDGoLib/futil"
+	"github.com/viethqc/gogstash/config/goglog"
+)
+
+// SinceDBInfo is the read state of the journal
+type SinceDBInfo struct {
+	Cursor     string    `json:"cursor"`
+	LastActive time.Time `json:"last_active"`
+}
+
+func (t *InputConfig) getCursor() string {
+	t.cursorMutex.Lock()
+	defer t.cursorMutex.Unlock()
+	return t.cursor
+}
+
+func (t *InputConfig) setCursor(cursor string) {
+	t.cursorMutex.Lock()
+	defer t.cursorMutex.Unlock()
+	t.cursor = cursor
+}
+
+func (t *InputConfig) isSinceDBDisabled() bool {
+	return t.SinceDBPath == "" || t.SinceDBPath == "/dev/null"
+}
+
+func (t *InputConfig) loadSinceDB() (err error) {
+	if t.isSinceDBDisabled() || !futil.IsExist(t.SinceDBPath) {
+		return nil
+	}
+
+	raw, err := ioutil.ReadFile(t.SinceDBPath)
+	if err != nil {
+		return err
+	}
+	since := SinceDBInfo{}
+	if err = jsoniter.Unmarshal(raw, &since); err != nil {
+		return err
+	}
+
+	t.cursorMutex.Lock()
+	defer t.cursorMutex.Unlock()
+	t.cursor = since.Cursor
+	t.savedCursor = since.Cursor
+	return nil
+}
+
+// saveSinceDB writes the cursor of the last event sent if changed
+func (t *InputConfig) saveSinceDB() (err error) {
+	if t.isSinceDBDisabled() {
+		return nil
+	}
+
+	t.cursorMutex.Lock()
+	cursor, changed := t.cursor, t.cursor != t.savedCursor
+	t.cursorMutex.Unlock()
+	if !changed {
+		return nil
+	}
+
+	raw, err := jsoniter.Marshal(SinceDBInfo{
+		Cursor:     cursor,
+		LastActive: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(t.SinceDBPath, raw, 0664); err != nil {
+		goglog.Logger.Errorf("input journald: write sincedb failed: %q\n%s", t.SinceDBPath, err)
+		return err
+	}
+
+	t.cursorMutex.Lock()
+	t.savedCursor = cursor
+	t.cursorMutex.Unlock()
+	return nil
+}
+
+func (t *InputConfig) saveSinceDBLoop(ctx context.Context) {
+	if t.SinceDBWriteInterval <= 0 {
+		// saved only when stopped
+		<-ctx.Done()
+		return
+	}
+	ticker := time.NewTicker(time.Duration(t.SinceDBWriteInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.saveSinceDB()
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package inputkubernetes
+
+import (
+	"context"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/logevent"
+	inputfile "github.com/viethqc/gogstash/input/file"
+	"golang.org/x/sync/errgroup"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "kubernetes"
+
+// ErrorTag tag added to event when process module failed
+const ErrorTag = "gogstash_input_kubernetes_error"
+
+// formats of container log files
+const (
+	FormatAuto   = "auto"   // detect by each line
+	FormatCRI    = "cri"    // containerd, cri-o: "<time> <stream> <P|F> <content>"
+	FormatDocker = "docker" // docker json-file: {"log":"...","stream":"stdout","time":"..."}
+)
+
+// default file input config of container logs
+const (
+	DefaultPath        = "/var/log/pods/*/*/*.log"
+	DefaultSinceDBPath = ".sincedb-kubernetes.json"
+)
+
+// InputConfig holds the configuration json fields and internal objects,
+// options of file input are supported to tail log files
+type InputConfig struct {
+	config.InputConfig
+	Format                     string `json:"format,omitempty"`       // one of ["auto", "cri", "docker"]
+	MetadataURL                string `json:"metadata_url,omitempty"` // kubelet "/pods" or API server "/api/v1/pods" compatible endpoint
+	MetadataTokenPath          string `json:"metadata_token_path,omitempty"`
+	MetadataCAPath             string `json:"metadata_ca_path,omitempty"`
+	MetadataInsecureSkipVerify bool   `json:"metadata_insecure_skip_verify,omitempty"`
+	MetadataRefreshInterval    int    `json:"metadata_refresh_interval,omitempty"` // in seconds
+
+	file *inputfile.InputConfig
+	pods *podMetadata
+}
+
+// DefaultInputConfig returns an InputConfig struct with default values
+func DefaultInputConfig() InputConfig {
+	return InputConfig{
+		InputConfig: config.InputConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		Format:                  FormatAuto,
+		MetadataRefreshInterval: 60,
+	}
+}
+
+// errors
+var (
+	ErrorUnknownFormat1 = errutil.NewFactory("unknown container log format: %q")
+)
+
+// InitHandler initialize the input plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputConfig, error) {
+	conf := DefaultInputConfig()
+	err := config.ReflectConfig(raw, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	switch conf.Format {
+	case FormatAuto, FormatCRI, FormatDocker:
+	default:
+		return nil, ErrorUnknownFormat1.New(nil, conf.Format)
+	}
+
+	if conf.MetadataURL != "" {
+		if conf.pods, err = newPodMetadata(conf); err != nil {
+			return nil, err
+		}
+	}
+
+	fileRaw := config.ConfigRaw{
+		"path":              DefaultPath,
+		"sincedb_path":      DefaultSinceDBPath,
+		"keep_symlink_path": true,
+	}
+	for k, v := range *raw {
+		fileRaw[k] = v
+	}
+	fileInput, err := inputfile.InitHandler(ctx, &fileRaw)
+	if err != nil {
+		return nil, err
+	}
+	conf.file = fileInput.(*inputfile.InputConfig)
+	conf.file.Codec = newLogCodec(conf.file.Codec, conf.Format, conf.pods)
+	conf.Codec = conf.file.Codec
+
+	return &conf, nil
+}
+
+// Start wraps the actual function starting the plugin
+func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
+	eg, ctx := errgroup.WithContext(ctx)
+
+	if t.pods != nil {
+		eg.Go(func() error {
+			return t.pods.refreshLoop(ctx)
+		})
+	}
+
+	eg.Go(func() error {
+		return t.file.Start(ctx, msgChan)
+	})
+
+	return eg.Wait()
+}
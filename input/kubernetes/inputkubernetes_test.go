@@ -0,0 +1,147 @@
+package inputkubernetes
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+	config.RegistInputHandler(ModuleName, InitHandler)
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
+}
+
+func Test_input_kubernetes_parse(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	l, err := parseCRI("2019-01-02T03:04:05.123456789Z stderr P hello world")
+	require.NoError(err)
+	assert.Equal(time.Date(2019, 1, 2, 3, 4, 5, 123456789, time.UTC), l.time)
+	assert.Equal("stderr", l.stream)
+	assert.True(l.partial)
+	assert.Equal("hello world", l.content)
+
+	l, err = parseCRI("2019-01-02T03:04:05.123456789+08:00 stdout F")
+	require.NoError(err)
+	assert.False(l.partial)
+	assert.Equal("", l.content)
+
+	_, err = parseCRI("plain text line")
+	assert.Error(err)
+
+	l, err = parseDocker(`{"log":"hello\n","stream":"stdout","time":"2019-01-02T03:04:05.123456789Z"}`)
+	require.NoError(err)
+	assert.Equal("hello", l.content)
+	assert.False(l.partial)
+	assert.Equal("stdout", l.stream)
+
+	l, err = parseDocker(`{"log":"hel","stream":"stdout","time":"2019-01-02T03:04:05.123456789Z"}`)
+	require.NoError(err)
+	assert.True(l.partial)
+
+	assert.Equal(podInfo{
+		Namespace:     "default",
+		PodName:       "web-5d8f9c7b6-x2x4z",
+		PodUID:        "0c3f8a2e-1b2c-4d5e-8f90-123456789abc",
+		ContainerName: "nginx",
+	}, parseLogPath("/var/log/pods/default_web-5d8f9c7b6-x2x4z_0c3f8a2e-1b2c-4d5e-8f90-123456789abc/nginx/0.log"))
+
+	id := "8f1d3e7a9b2c4d6e8f1a3b5c7d9e1f2a4b6c8d0e2f4a6b8c0d2e4f6a8b0c2d4e"
+	assert.Equal(podInfo{
+		Namespace:     "kube-system",
+		PodName:       "coredns-abc",
+		ContainerName: "coredns",
+		ContainerID:   id,
+	}, parseLogPath("/var/log/containers/coredns-abc_kube-system_coredns-"+id+".log"))
+
+	assert.Equal(podInfo{}, parseLogPath("/var/log/syslog"))
+}
+
+func Test_input_kubernetes_module(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-input-kubernetes")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	poddir := filepath.Join(dir, "default_web_0c3f8a2e-uid")
+	require.NoError(os.MkdirAll(filepath.Join(poddir, "nginx"), 0755))
+	require.NoError(os.MkdirAll(filepath.Join(poddir, "sidecar"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(poddir, "nginx", "0.log"), []byte(
+		"2019-01-02T03:04:05.000000001Z stdout P part1 \n"+
+			"2019-01-02T03:04:05.000000002Z stderr F error line\n"+
+			"2019-01-02T03:04:05.000000003Z stdout F part2\n"), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(poddir, "sidecar", "0.log"), []byte(
+		`{"log":"{\"message\":\"js","stream":"stdout","time":"2019-01-02T03:04:06.000000001Z"}`+"\n"+
+			`{"log":"on\"}\n","stream":"stdout","time":"2019-01-02T03:04:06.000000002Z"}`+"\n"), 0644))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("Bearer secret", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"kind":"PodList","items":[{"metadata":{"name":"web","namespace":"default","uid":"0c3f8a2e-uid","labels":{"app":"web"}}}]}`))
+	}))
+	defer server.Close()
+	tokenPath := filepath.Join(dir, "token")
+	require.NoError(ioutil.WriteFile(tokenPath, []byte("secret\n"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input, err := InitHandler(ctx, &config.ConfigRaw{
+		"path":                filepath.Join(dir, "*", "*", "*.log"),
+		"sincedb_path":        "",
+		"start_position":      "beginning",
+		"metadata_url":        server.URL,
+		"metadata_token_path": tokenPath,
+	})
+	require.NoError(err)
+	conf := input.(*InputConfig)
+	require.NoError(conf.pods.refresh(ctx))
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	go input.Start(ctx, msgChan)
+
+	events := map[string]logevent.LogEvent{}
+	for i := 0; i < 3; i++ {
+		select {
+		case event := <-msgChan:
+			events[event.Message] = event
+		case <-time.After(3 * time.Second):
+			require.FailNow("timeout", "received: %v", events)
+		}
+	}
+
+	if event, ok := events["part1 part2"]; assert.True(ok) {
+		assert.Equal(time.Date(2019, 1, 2, 3, 4, 5, 1, time.UTC), event.Timestamp)
+		assert.Equal("stdout", event.Extra["stream"])
+		assert.Equal("default", event.GetString("kubernetes.namespace"))
+		assert.Equal("web", event.GetString("kubernetes.pod_name"))
+		assert.Equal("0c3f8a2e-uid", event.GetString("kubernetes.pod_uid"))
+		assert.Equal("nginx", event.GetString("kubernetes.container_name"))
+		assert.Equal("web", event.GetString("kubernetes.labels.app"))
+	}
+	if event, ok := events["error line"]; assert.True(ok) {
+		assert.Equal("stderr", event.Extra["stream"])
+	}
+	if event, ok := events[`{"message":"json"}`]; assert.True(ok) {
+		assert.Equal(time.Date(2019, 1, 2, 3, 4, 6, 1, time.UTC), event.Timestamp)
+		assert.Equal("sidecar", event.GetString("kubernetes.container_name"))
+	}
+}
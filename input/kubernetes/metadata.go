@@ -0,0 +1,219 @@
+package inputkubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config/goglog"
+)
+
+// errors
+var (
+	ErrorMetadataRequestFailed2 = errutil.NewFactory("request pod metadata failed: %d %s")
+	ErrorInvalidCA1             = errutil.NewFactory("no certificate found in %q")
+)
+
+// podInfo is the pod metadata derived from log file path
+type podInfo struct {
+	Namespace     string
+	PodName       string
+	PodUID        string
+	ContainerName string
+	ContainerID   string
+}
+
+var reContainerLogName = regexp.MustCompile(`^([^_]+)_([^_]+)_(.+)-([0-9a-f]{64})\.log`)
+
+// parseLogPath returns the pod info of container log paths:
+// /var/log/pods/<namespace>_<pod>_<uid>/<container>/<restart>.log
+// /var/log/containers/<pod>_<namespace>_<container>-<container id>.log
+func parseLogPath(fpath string) (info podInfo) {
+	name := filepath.Base(fpath)
+	if match := reContainerLogName.FindStringSubmatch(name); match != nil {
+		return podInfo{
+			PodName:       match[1],
+			Namespace:     match[2],
+			ContainerName: match[3],
+			ContainerID:   match[4],
+		}
+	}
+
+	containerDir := filepath.Dir(fpath)
+	parts := strings.Split(filepath.Base(filepath.Dir(containerDir)), "_")
+	if len(parts) != 3 || !strings.Contains(name, ".log") {
+		return info
+	}
+	return podInfo{
+		Namespace:     parts[0],
+		PodName:       parts[1],
+		PodUID:        parts[2],
+		ContainerName: filepath.Base(containerDir),
+	}
+}
+
+// podMetadata caches pod labels from a kubelet or API server compatible endpoint
+type podMetadata struct {
+	url       string
+	tokenPath string
+	interval  time.Duration
+	client    *http.Client
+
+	mutex  sync.RWMutex
+	byUID  map[string]*podMeta
+	byName map[string]*podMeta // by namespace and name
+}
+
+type podMeta struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	UID       string            `json:"uid"`
+	Labels    map[string]string `json:"labels"`
+}
+
+func newPodMetadata(conf InputConfig) (*podMetadata, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: conf.MetadataInsecureSkipVerify}
+	if conf.MetadataCAPath != "" {
+		ca, err := ioutil.ReadFile(conf.MetadataCAPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, ErrorInvalidCA1.New(nil, conf.MetadataCAPath)
+		}
+	}
+
+	return &podMetadata{
+		url:       conf.MetadataURL,
+		tokenPath: conf.MetadataTokenPath,
+		interval:  time.Duration(conf.MetadataRefreshInterval) * time.Second,
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		byUID:  map[string]*podMeta{},
+		byName: map[string]*podMeta{},
+	}, nil
+}
+
+func (t *podMetadata) refreshLoop(ctx context.Context) error {
+	if err := t.refresh(ctx); err != nil {
+		goglog.Logger.Errorf("refresh pod metadata failed: %v", err)
+	}
+	if t.interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := t.refresh(ctx); err != nil {
+				goglog.Logger.Errorf("refresh pod metadata failed: %v", err)
+			}
+		}
+	}
+}
+
+func (t *podMetadata) refresh(ctx context.Context) (err error) {
+	req, err := http.NewRequest(http.MethodGet, t.url, nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	if t.tokenPath != "" {
+		// service account tokens are rotated, read for each request
+		token, err := ioutil.ReadFile(t.tokenPath)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ErrorMetadataRequestFailed2.New(nil, resp.StatusCode, string(body))
+	}
+
+	podList := struct {
+		Items []struct {
+			Metadata podMeta `json:"metadata"`
+		} `json:"items"`
+	}{}
+	if err = jsoniter.Unmarshal(body, &podList); err != nil {
+		return
+	}
+
+	byUID := make(map[string]*podMeta, len(podList.Items))
+	byName := make(map[string]*podMeta, len(podList.Items))
+	for i := range podList.Items {
+		meta := &podList.Items[i].Metadata
+		byUID[meta.UID] = meta
+		byName[meta.Namespace+"/"+meta.Name] = meta
+	}
+
+	t.mutex.Lock()
+	t.byUID, t.byName = byUID, byName
+	t.mutex.Unlock()
+	return nil
+}
+
+// lookup returns the "kubernetes" field of events from pod info and cached labels
+func (t *podMetadata) lookup(info podInfo) map[string]interface{} {
+	fields := map[string]interface{}{}
+	set := func(key string, value string) {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	set("namespace", info.Namespace)
+	set("pod_name", info.PodName)
+	set("pod_uid", info.PodUID)
+	set("container_name", info.ContainerName)
+	set("container_id", info.ContainerID)
+
+	if t == nil || info.PodName == "" {
+		return fields
+	}
+
+	t.mutex.RLock()
+	meta, ok := t.byUID[info.PodUID]
+	if !ok {
+		meta, ok = t.byName[info.Namespace+"/"+info.PodName]
+	}
+	t.mutex.RUnlock()
+	if !ok {
+		return fields
+	}
+
+	set("pod_uid", meta.UID)
+	if len(meta.Labels) > 0 {
+		labels := make(map[string]interface{}, len(meta.Labels))
+		for k, v := range meta.Labels {
+			labels[k] = v
+		}
+		fields["labels"] = labels
+	}
+	return fields
+}
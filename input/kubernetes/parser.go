@@ -0,0 +1,180 @@
+package inputkubernetes
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// errors of parsing container log lines
+var (
+	errInvalidCRILine = errors.New("invalid CRI log line")
+	errInvalidStream  = errors.New("invalid log stream")
+)
+
+// logLine is a parsed line of container log file
+type logLine struct {
+	time    time.Time
+	stream  string
+	partial bool // the message continues in next line
+	content string
+}
+
+// parseCRI parses line of CRI format, e.g. "2016-10-06T00:17:09.669794202Z stdout F log content"
+func parseCRI(line string) (l logLine, err error) {
+	parts := strings.SplitN(line, " ", 4)
+	if len(parts) < 3 {
+		return l, errInvalidCRILine
+	}
+	if l.time, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return l, err
+	}
+	if l.stream = parts[1]; l.stream != "stdout" && l.stream != "stderr" {
+		return l, errInvalidStream
+	}
+	// tags are separated by ":", the first one is "P" for partial or "F" for full
+	switch strings.SplitN(parts[2], ":", 2)[0] {
+	case "P":
+		l.partial = true
+	case "F":
+	default:
+		return l, errInvalidCRILine
+	}
+	if len(parts) > 3 {
+		l.content = parts[3]
+	}
+	return l, nil
+}
+
+// parseDocker parses line of docker json-file format, messages over 16KB are split without trailing newline
+func parseDocker(line string) (l logLine, err error) {
+	entry := struct {
+		Log    string    `json:"log"`
+		Stream string    `json:"stream"`
+		Time   time.Time `json:"time"`
+	}{}
+	if err = jsoniter.UnmarshalFromString(line, &entry); err != nil {
+		return l, err
+	}
+	l.time = entry.Time
+	l.stream = entry.Stream
+	l.partial = !strings.HasSuffix(entry.Log, "\n")
+	l.content = strings.TrimSuffix(strings.TrimSuffix(entry.Log, "\n"), "\r")
+	return l, nil
+}
+
+// logCodec parses container log lines before decoding the content with the wrapped codec
+type logCodec struct {
+	config.TypeCodecConfig
+	format string
+	pods   *podMetadata
+
+	partials      map[string]*partialLine // by path and stream
+	partialsMutex sync.Mutex
+}
+
+type partialLine struct {
+	time    time.Time
+	content strings.Builder
+}
+
+func newLogCodec(codec config.TypeCodecConfig, format string, pods *podMetadata) *logCodec {
+	return &logCodec{
+		TypeCodecConfig: codec,
+		format:          format,
+		pods:            pods,
+		partials:        map[string]*partialLine{},
+	}
+}
+
+func (c *logCodec) parse(line string) (logLine, error) {
+	switch c.format {
+	case FormatCRI:
+		return parseCRI(line)
+	case FormatDocker:
+		return parseDocker(line)
+	default:
+		if strings.HasPrefix(line, "{") {
+			return parseDocker(line)
+		}
+		return parseCRI(line)
+	}
+}
+
+// Decode parses a line of container log file, partial lines are buffered until the full line
+func (c *logCodec) Decode(ctx context.Context, data interface{},
+	eventExtra map[string]interface{},
+	msgChan chan<- logevent.LogEvent) (ok bool, err error) {
+
+	var line string
+	switch v := data.(type) {
+	case string:
+		line = v
+	case []byte:
+		line = string(v)
+	default:
+		return c.TypeCodecConfig.Decode(ctx, data, eventExtra, msgChan)
+	}
+
+	path, _ := eventExtra["path"].(string)
+	extra := make(map[string]interface{}, len(eventExtra)+2)
+	for k, v := range eventExtra {
+		extra[k] = v
+	}
+	if meta := c.pods.lookup(parseLogPath(path)); len(meta) > 0 {
+		extra["kubernetes"] = meta
+	}
+
+	l, err := c.parse(line)
+	if err != nil {
+		// unknown format, decode the line as is
+		return c.decode(ctx, line, time.Time{}, extra, true, msgChan)
+	}
+	extra["stream"] = l.stream
+
+	key := path + "\x00" + l.stream
+	c.partialsMutex.Lock()
+	partial := c.partials[key]
+	if l.partial {
+		if partial == nil {
+			partial = &partialLine{time: l.time}
+			c.partials[key] = partial
+		}
+		partial.content.WriteString(l.content)
+		c.partialsMutex.Unlock()
+		return false, nil
+	}
+	if partial != nil {
+		partial.content.WriteString(l.content)
+		l.content = partial.content.String()
+		l.time = partial.time
+		delete(c.partials, key)
+	}
+	c.partialsMutex.Unlock()
+
+	return c.decode(ctx, l.content, l.time, extra, false, msgChan)
+}
+
+// decode decodes content by the wrapped codec, events keep the timestamp of log line
+// unless the codec parsed one from content
+func (c *logCodec) decode(ctx context.Context, content string, logTime time.Time,
+	extra map[string]interface{}, parseError bool,
+	msgChan chan<- logevent.LogEvent) (ok bool, err error) {
+
+	before := time.Now()
+	return config.DecodeFunc(ctx, c.TypeCodecConfig, content, extra, func(event logevent.LogEvent) {
+		if !logTime.IsZero() && !event.Timestamp.Before(before) {
+			event.Timestamp = logTime
+		}
+		if parseError {
+			event.AddTag(ErrorTag)
+		}
+		msgChan <- event
+	})
+}
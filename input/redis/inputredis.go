@@ -23,7 +23,8 @@ const ErrorTag = "gogstash_input_redis_error"
 type InputConfig struct {
 	config.InputConfig
 	Host        string `json:"host"`        // redis server host:port, default: "localhost:6379"
-	Key         string `json:"key"`         // where to get data, default: "gogstash"
+	Key         string `json:"key"`         // list, channel, channel pattern or stream to get data, default: "gogstash"
+	DataType    string `json:"data_type"`   // one of ["list", "channel", "pattern_channel", "stream"], default: "list"
 	Connections int    `json:"connections"` // maximum number of socket connections, default: 10
 	BatchCount  int    `json:"batch_count"` // The number of events to return from Redis using EVAL, default: 125
 	Password    string `json:"password,omitempty"`
@@ -34,10 +35,27 @@ type InputConfig struct {
 	BlockingTimeout string `json:"blocking_timeout,omitempty"` // automatically
 	blockingTimeout time.Duration
 
+	// Stream consumer group options
+	Group         string `json:"group,omitempty"`          // consumer group, created if not exists, default: "gogstash"
+	Consumer      string `json:"consumer,omitempty"`       // consumer name, default: hostname
+	GroupStartID  string `json:"group_start_id,omitempty"` // ID to start reading when creating the group, default: "$"
+	ClaimMinIdle  string `json:"claim_min_idle,omitempty"` // claim pending entries of other consumers idle for this duration, "0" to disable, default: "60s"
+	ClaimInterval string `json:"claim_interval,omitempty"` // interval of claiming pending entries, default: "30s"
+	claimMinIdle  time.Duration
+	claimInterval time.Duration
+
 	client         *redis.Client
 	batchScriptSha string
 }
 
+// data types of key
+const (
+	DataTypeList           = "list"
+	DataTypeChannel        = "channel"
+	DataTypePatternChannel = "pattern_channel"
+	DataTypeStream         = "stream"
+)
+
 // DefaultInputConfig returns an InputConfig struct with default values
 func DefaultInputConfig() InputConfig {
 	return InputConfig{
@@ -51,12 +69,18 @@ func DefaultInputConfig() InputConfig {
 		Connections:     10,
 		BatchCount:      125,
 		BlockingTimeout: "600s",
+		DataType:        DataTypeList,
+		Group:           "gogstash",
+		GroupStartID:    "$",
+		ClaimMinIdle:    "60s",
+		ClaimInterval:   "30s",
 	}
 }
 
 // errors
 var (
-	ErrorPingFailed = errutil.NewFactory("ping redis server failed")
+	ErrorPingFailed       = errutil.NewFactory("ping redis server failed")
+	ErrorInvalidDataType1 = errutil.NewFactory("invalid data_type: %q")
 )
 
 // InitHandler initialize the input plugin
@@ -72,19 +96,32 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 		return nil, err
 	}
 
-	conf.client = redis.NewClient(&redis.Options{
+	options := &redis.Options{
 		Addr:     conf.Host,
 		Password: conf.Password, // no password set
 		DB:       conf.Db,       // use default DB
 		PoolSize: conf.Connections,
-	})
+	}
+	switch conf.DataType {
+	case DataTypeList, DataTypeChannel, DataTypePatternChannel:
+	case DataTypeStream:
+		if err = conf.initStream(); err != nil {
+			return nil, err
+		}
+		// XREADGROUP BLOCK is not a known blocking command of the client
+		options.ReadTimeout = conf.blockingTimeout + 10*time.Second
+	default:
+		return nil, ErrorInvalidDataType1.New(nil, conf.DataType)
+	}
+
+	conf.client = redis.NewClient(options)
 	conf.client = conf.client.WithContext(ctx)
 
 	if _, err := conf.client.Ping().Result(); err != nil {
 		return nil, ErrorPingFailed.New(err)
 	}
 
-	if conf.BatchCount > 1 {
+	if conf.DataType == DataTypeList && conf.BatchCount > 1 {
 		err = conf.loadBatchScript()
 		if err != nil {
 			return nil, err
@@ -161,10 +198,55 @@ func (i *InputConfig) loadBatchScript() (err error) {
 	return
 }
 
+// subscribe receives messages of channels or channel patterns until ctx is done
+func (i *InputConfig) subscribe(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
+	var pubsub *redis.PubSub
+	var err error
+	if i.DataType == DataTypePatternChannel {
+		pubsub, err = i.client.PSubscribe(i.Key)
+	} else {
+		pubsub, err = i.client.Subscribe(i.Key)
+	}
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		pubsub.Close()
+	}()
+
+	for {
+		msg, err := pubsub.ReceiveMessage()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				goglog.Logger.Info("input redis stopped")
+				return nil
+			default:
+			}
+			return err
+		}
+		i.queueMessage(ctx, msg.Payload, msgChan)
+	}
+}
+
 // Start wraps the actual function starting the plugin
 func (i *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
 	var err error
 
+	switch i.DataType {
+	case DataTypeChannel, DataTypePatternChannel:
+		return i.subscribe(ctx, msgChan)
+	case DataTypeStream:
+		return i.readStream(ctx, msgChan)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -8,13 +8,15 @@ import (
 	"testing"
 	"time"
 
-	"github.com/alicebob/miniredis"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	codecjson "github.com/viethqc/gogstash/codec/json"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+	"gopkg.in/redis.v5"
 )
 
 var s *miniredis.Miniredis
@@ -106,3 +108,128 @@ input:
 		require.Equal("inputredis test message", event.Message)
 	}
 }
+
+func startRedisInput(t *testing.T, ctx context.Context, yaml string) chan logevent.LogEvent {
+	require := require.New(t)
+	require.NotNil(require)
+
+	conf, err := config.LoadFromYAML([]byte(strings.TrimSpace(yaml)))
+	require.NoError(err)
+	input, err := InitHandler(ctx, &conf.InputRaw[0])
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	go input.Start(ctx, msgChan)
+	time.Sleep(200 * time.Millisecond)
+	return msgChan
+}
+
+func receiveEvents(t *testing.T, msgChan chan logevent.LogEvent, count int) (messages []string) {
+	for i := 0; i < count; i++ {
+		select {
+		case event := <-msgChan:
+			messages = append(messages, event.Message)
+		case <-time.After(2 * time.Second):
+			require.FailNow(t, "timeout waiting for event")
+		}
+	}
+	select {
+	case event := <-msgChan:
+		assert.Fail(t, "unexpected event", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+	return messages
+}
+
+func Test_input_redis_module_channel(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	msgChan := startRedisInput(t, ctx, `
+input:
+  - type: redis
+    host: localhost:6380
+    key: gogstash-channel
+    data_type: channel
+	`)
+	patternChan := startRedisInput(t, ctx, `
+input:
+  - type: redis
+    host: localhost:6380
+    key: gogstash-*
+    data_type: pattern_channel
+	`)
+
+	s.Publish("gogstash-channel", `{"message":"channel message"}`)
+	s.Publish("gogstash-other", `{"message":"other message"}`)
+	s.Publish("other", `{"message":"ignored"}`)
+
+	assert.Equal([]string{"channel message"}, receiveEvents(t, msgChan, 1))
+	assert.Equal([]string{"channel message", "other message"}, receiveEvents(t, patternChan, 2))
+}
+
+func Test_input_redis_module_stream(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6380"})
+	defer client.Close()
+	intCmd := func(args ...interface{}) int64 {
+		cmd := redis.NewIntCmd(args...)
+		client.Process(cmd)
+		require.NoError(cmd.Err())
+		return cmd.Val()
+	}
+
+	_, err := s.XAdd("gogstash-stream", "*", []string{"message", "before group"})
+	require.NoError(err)
+	cmd := redis.NewStatusCmd("XGROUP", "CREATE", "gogstash-stream", "gogstash", "$")
+	client.Process(cmd)
+	require.NoError(cmd.Err())
+	_, err = s.XAdd("gogstash-stream", "*", []string{"message", "crashed"})
+	require.NoError(err)
+
+	// delivered to a crashed consumer without XACK
+	read := redis.NewSliceCmd("XREADGROUP", "GROUP", "gogstash", "crashed", "COUNT", 1, "STREAMS", "gogstash-stream", ">")
+	client.Process(read)
+	require.NoError(read.Err())
+	time.Sleep(200 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgChan := startRedisInput(t, ctx, `
+input:
+  - type: redis
+    host: localhost:6380
+    key: gogstash-stream
+    data_type: stream
+    consumer: worker
+    blocking_timeout: 1s
+    claim_min_idle: 100ms
+    claim_interval: 1s
+    batch_count: 2
+	`)
+
+	assert.Equal([]string{"crashed"}, receiveEvents(t, msgChan, 1))
+
+	for i := 0; i < 3; i++ {
+		_, err = s.XAdd("gogstash-stream", "*", []string{"message", fmt.Sprintf("entry %d", i), "field", "value"})
+		require.NoError(err)
+	}
+	assert.Equal([]string{"entry 0", "entry 1", "entry 2"}, receiveEvents(t, msgChan, 3))
+
+	// all entries are acknowledged
+	pending := redis.NewSliceCmd("XPENDING", "gogstash-stream", "gogstash")
+	client.Process(pending)
+	require.NoError(pending.Err())
+	assert.EqualValues(0, pending.Val()[0])
+	assert.EqualValues(5, intCmd("XLEN", "gogstash-stream"))
+
+	_, err = InitHandler(ctx, &config.ConfigRaw{"host": "localhost:6380", "data_type": "set"})
+	assert.Error(err)
+}
@@ -0,0 +1,232 @@
+package inputredis
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+	"gopkg.in/redis.v5"
+)
+
+// errors
+var (
+	ErrorUnexpectedReply1 = errutil.NewFactory("unexpected redis reply: %v")
+)
+
+// streamEntry is an entry of redis stream, fields is nil if the entry was deleted
+type streamEntry struct {
+	id     string
+	fields map[string]interface{}
+}
+
+func (i *InputConfig) initStream() (err error) {
+	if i.Consumer == "" {
+		if i.Consumer, err = os.Hostname(); err != nil {
+			return err
+		}
+	}
+	if i.claimMinIdle, err = time.ParseDuration(i.ClaimMinIdle); err != nil {
+		return err
+	}
+	if i.claimInterval, err = time.ParseDuration(i.ClaimInterval); err != nil {
+		return err
+	}
+	if i.claimMinIdle > 0 && i.claimInterval > 0 && i.claimInterval < i.blockingTimeout {
+		// wake up from XREADGROUP BLOCK to claim pending entries
+		i.blockingTimeout = i.claimInterval
+	}
+	return nil
+}
+
+// readStream reads the stream as a member of consumer group,
+// entries are acknowledged after sent to msgChan
+func (i *InputConfig) readStream(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
+	if err := i.createGroup(); err != nil {
+		return err
+	}
+
+	// entries delivered to this consumer but not acknowledged before restart
+	for startID := "0"; ; {
+		entries, err := i.readGroup(startID, 0)
+		if err != nil {
+			return err
+		}
+		processed, err := i.processEntries(ctx, entries, msgChan)
+		if err != nil {
+			return err
+		}
+		if len(entries) < 1 || processed < len(entries) {
+			break
+		}
+		startID = entries[len(entries)-1].id
+	}
+
+	var lastClaim time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			goglog.Logger.Info("input redis stopped")
+			return nil
+		default:
+		}
+
+		if i.claimMinIdle > 0 && time.Since(lastClaim) >= i.claimInterval {
+			lastClaim = time.Now()
+			if err := i.claimPending(ctx, msgChan); err != nil {
+				return err
+			}
+		}
+
+		entries, err := i.readGroup(">", i.blockingTimeout)
+		if err != nil {
+			return err
+		}
+		if _, err = i.processEntries(ctx, entries, msgChan); err != nil {
+			return err
+		}
+	}
+}
+
+func (i *InputConfig) createGroup() error {
+	cmd := redis.NewStatusCmd("XGROUP", "CREATE", i.Key, i.Group, i.GroupStartID, "MKSTREAM")
+	i.client.Process(cmd)
+	if err := cmd.Err(); err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (i *InputConfig) batchCount() int {
+	if i.BatchCount < 1 {
+		return 1
+	}
+	return i.BatchCount
+}
+
+// readGroup reads entries after id, ">" for new entries, block 0 to return immediately
+func (i *InputConfig) readGroup(id string, block time.Duration) ([]streamEntry, error) {
+	args := []interface{}{"XREADGROUP", "GROUP", i.Group, i.Consumer, "COUNT", i.batchCount()}
+	if block > 0 {
+		args = append(args, "BLOCK", int64(block/time.Millisecond))
+	}
+	args = append(args, "STREAMS", i.Key, id)
+	cmd := redis.NewSliceCmd(args...)
+	i.client.Process(cmd)
+	result, err := cmd.Result()
+	if err != nil {
+		if err == redis.Nil { // BLOCK timeout
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []streamEntry
+	for _, stream := range result {
+		// [key, entries]
+		reply, ok := stream.([]interface{})
+		if !ok || len(reply) != 2 {
+			return nil, ErrorUnexpectedReply1.New(nil, stream)
+		}
+		streamEntries, err := parseStreamEntries(reply[1])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, streamEntries...)
+	}
+	return entries, nil
+}
+
+// claimPending claims and processes entries pending for other consumers longer than claimMinIdle, e.g. crashed consumers
+func (i *InputConfig) claimPending(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
+	for startID := "0-0"; ; {
+		cmd := redis.NewSliceCmd("XAUTOCLAIM", i.Key, i.Group, i.Consumer,
+			int64(i.claimMinIdle/time.Millisecond), startID, "COUNT", i.batchCount())
+		i.client.Process(cmd)
+		result, err := cmd.Result()
+		if err != nil {
+			return err
+		}
+		// [next start ID, entries] and deleted IDs since redis 7
+		if len(result) < 2 {
+			return ErrorUnexpectedReply1.New(nil, result)
+		}
+		entries, err := parseStreamEntries(result[1])
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			goglog.Logger.Infof("input redis: claimed %d pending entries of %q", len(entries), i.Key)
+		}
+		processed, err := i.processEntries(ctx, entries, msgChan)
+		if err != nil {
+			return err
+		}
+
+		startID, _ = result[0].(string)
+		if startID == "" || startID == "0-0" || processed < len(entries) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+	}
+}
+
+// processEntries sends entries to msgChan and acknowledges them,
+// returns the number of acknowledged entries, others are left pending
+func (i *InputConfig) processEntries(ctx context.Context, entries []streamEntry, msgChan chan<- logevent.LogEvent) (int, error) {
+	ids := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		if entry.fields != nil {
+			if ok, _ := i.Codec.Decode(ctx, entry.fields, nil, msgChan); !ok {
+				break
+			}
+		}
+		ids = append(ids, entry.id)
+	}
+	if len(ids) < 1 {
+		return 0, nil
+	}
+
+	cmd := redis.NewIntCmd(append([]interface{}{"XACK", i.Key, i.Group}, ids...)...)
+	i.client.Process(cmd)
+	if err := cmd.Err(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// parseStreamEntries parses [[id, [field, value, ...]], ...]
+func parseStreamEntries(reply interface{}) ([]streamEntry, error) {
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, ErrorUnexpectedReply1.New(nil, reply)
+	}
+	entries := make([]streamEntry, 0, len(items))
+	for _, item := range items {
+		pair, ok := item.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, ErrorUnexpectedReply1.New(nil, item)
+		}
+		entry := streamEntry{}
+		if entry.id, ok = pair[0].(string); !ok {
+			return nil, ErrorUnexpectedReply1.New(nil, item)
+		}
+		if values, ok := pair[1].([]interface{}); ok {
+			entry.fields = make(map[string]interface{}, len(values)/2)
+			for j := 0; j+1 < len(values); j += 2 {
+				if field, ok := values[j].(string); ok {
+					entry.fields[field] = values[j+1]
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,197 @@
+package inputsocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+)
+
+// framing modes of stream and datagram data
+const (
+	FramingNewline        = "newline"
+	FramingOctetCounting  = "octet_counting"
+	FramingLengthPrefixed = "length_prefixed"
+	FramingNullByte       = "null_byte"
+	FramingNone           = "none"
+)
+
+// DefaultMaxFrameSize is the default maximum size of a frame in bytes
+const DefaultMaxFrameSize = 1048576
+
+// maximum number of digits of an octet counting frame length
+const maxOctetCountDigits = 10
+
+// errors
+var (
+	ErrorInvalidFraming1     = errutil.NewFactory("%q is not a valid framing")
+	ErrorFrameTooLarge2      = errutil.NewFactory("frame size %d exceeds max_frame_size %d")
+	ErrorInvalidFrameLength1 = errutil.NewFactory("invalid frame length %q")
+)
+
+// CheckFraming returns an error if framing is not supported
+func CheckFraming(framing string) error {
+	switch framing {
+	case FramingNewline, FramingOctetCounting, FramingLengthPrefixed, FramingNullByte, FramingNone:
+		return nil
+	default:
+		return ErrorInvalidFraming1.New(nil, framing)
+	}
+}
+
+// FrameReader splits data of a connection or a datagram into frames
+type FrameReader struct {
+	r       *bufio.Reader
+	framing string
+	maxSize int
+	eof     bool
+}
+
+// NewFrameReader returns a FrameReader of framing, maxSize 0 for no limit
+func NewFrameReader(r io.Reader, framing string, maxSize int) *FrameReader {
+	return &FrameReader{
+		r:       bufio.NewReader(r),
+		framing: framing,
+		maxSize: maxSize,
+	}
+}
+
+// ReadFrame returns the next frame, the last frame may end without delimiter,
+// io.EOF is returned when no data left
+func (f *FrameReader) ReadFrame() ([]byte, error) {
+	if f.eof {
+		return nil, io.EOF
+	}
+	switch f.framing {
+	case FramingOctetCounting:
+		return f.readOctetCounting()
+	case FramingLengthPrefixed:
+		return f.readLengthPrefixed()
+	case FramingNullByte:
+		frame, err := f.readDelimited(0)
+		return bytes.TrimSuffix(frame, []byte{0}), err
+	case FramingNone:
+		return f.readAll()
+	default:
+		// delimiter is kept for compatibility
+		return f.readDelimited('\n')
+	}
+}
+
+// readDelimited reads a frame ending with delim, frames larger than maxSize without delim are errors
+func (f *FrameReader) readDelimited(delim byte) ([]byte, error) {
+	var frame []byte
+	for {
+		chunk, err := f.r.ReadSlice(delim)
+		size := len(frame) + len(chunk)
+		if err == nil {
+			size-- // delimiter
+		}
+		if f.maxSize > 0 && size > f.maxSize {
+			return nil, ErrorFrameTooLarge2.New(nil, size, f.maxSize)
+		}
+		// chunk is only valid until the next read
+		frame = append(frame, chunk...)
+
+		switch err {
+		case nil:
+			return frame, nil
+		case bufio.ErrBufferFull:
+		case io.EOF:
+			if len(frame) > 0 {
+				f.eof = true
+				return frame, nil
+			}
+			return nil, io.EOF
+		default:
+			return frame, err
+		}
+	}
+}
+
+// readOctetCounting reads RFC 6587 "MSG-LEN SP SYSLOG-MSG" frames,
+// frames not starting with a digit fall back to newline framing
+func (f *FrameReader) readOctetCounting() ([]byte, error) {
+	first, err := f.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] < '0' || first[0] > '9' {
+		frame, err := f.readDelimited('\n')
+		return bytes.TrimRight(frame, "\r\n"), err
+	}
+
+	digits := make([]byte, 0, maxOctetCountDigits)
+	for {
+		c, err := f.r.ReadByte()
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if c == ' ' {
+			break
+		}
+		if c < '0' || c > '9' || len(digits) >= maxOctetCountDigits {
+			return nil, ErrorInvalidFrameLength1.New(nil, append(digits, c))
+		}
+		digits = append(digits, c)
+	}
+	size, err := strconv.Atoi(string(digits))
+	if err != nil {
+		return nil, ErrorInvalidFrameLength1.New(err, digits)
+	}
+	return f.readFull(size)
+}
+
+// readLengthPrefixed reads frames prefixed by a 4 bytes big endian length
+func (f *FrameReader) readLengthPrefixed() ([]byte, error) {
+	prefix := make([]byte, 4)
+	if n, err := io.ReadFull(f.r, prefix); err != nil {
+		if n == 0 {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	return f.readFull(int(binary.BigEndian.Uint32(prefix)))
+}
+
+func (f *FrameReader) readFull(size int) ([]byte, error) {
+	if size < 0 || (f.maxSize > 0 && size > f.maxSize) {
+		return nil, ErrorFrameTooLarge2.New(nil, size, f.maxSize)
+	}
+	// the frame grows with the data received, a length prefix alone never allocates more than
+	// DefaultMaxFrameSize, e.g. up to 4 GiB of length-prefixed frames when max_frame_size is 0
+	frame := bytes.Buffer{}
+	if size <= DefaultMaxFrameSize {
+		frame.Grow(size)
+	} else {
+		frame.Grow(DefaultMaxFrameSize)
+	}
+	if _, err := io.CopyN(&frame, f.r, int64(size)); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return frame.Bytes(), nil
+}
+
+// readAll reads the whole data as one frame
+func (f *FrameReader) readAll() ([]byte, error) {
+	var r io.Reader = f.r
+	if f.maxSize > 0 {
+		r = io.LimitReader(f.r, int64(f.maxSize)+1)
+	}
+	frame, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if f.maxSize > 0 && len(frame) > f.maxSize {
+		return nil, ErrorFrameTooLarge2.New(nil, len(frame), f.maxSize)
+	}
+	f.eof = true
+	if len(frame) < 1 {
+		return nil, io.EOF
+	}
+	return frame, nil
+}
@@ -1,11 +1,15 @@
 package inputsocket
 
 import (
-	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"io"
+	"io/ioutil"
 	"net"
 	"os"
+	"time"
 
 	reuse "github.com/libp2p/go-reuseport"
 	"github.com/viethqc/gogstash/KDGoLib/errutil"
@@ -31,6 +35,29 @@ type InputConfig struct {
 	Address    string `json:"address"`
 	ReusePort  bool   `json:"reuseport"`
 	BufferSize int    `json:"buffer_size"`
+
+	// SSL certificate and key files, enable TLS for stream sockets when set.
+	SSLCertificate string `json:"ssl_certificate"`
+	SSLKey         string `json:"ssl_key"`
+	// CA files to verify client certificates.
+	SSLCertificateAuthorities []string `json:"ssl_certificate_authorities"`
+	// Client certificate verification, must be one of ["none", "peer", "force_peer"].
+	SSLVerifyMode string `json:"ssl_verify_mode"`
+
+	// Framing of messages, must be one of ["newline", "octet_counting", "length_prefixed", "null_byte", "none"].
+	Framing string `json:"framing"`
+	// Maximum size of a frame in bytes, 0 for no limit.
+	MaxFrameSize int `json:"max_frame_size"`
+	// Read PROXY protocol v1 or v2 header to restore the client address.
+	ProxyProtocol bool `json:"proxy_protocol"`
+
+	// Maximum number of concurrent connections, 0 for no limit.
+	MaxConnections int `json:"max_connections"`
+	// Close connections without data for this many seconds, 0 to never close.
+	IdleTimeout int `json:"idle_timeout"`
+
+	tlsConfig *tls.Config
+	connSlots chan struct{}
 }
 
 // DefaultInputConfig returns an InputConfig struct with default values
@@ -41,14 +68,35 @@ func DefaultInputConfig() InputConfig {
 				Type: ModuleName,
 			},
 		},
-		BufferSize: 4096,
+		BufferSize:    4096,
+		SSLVerifyMode: VerifyModeNone,
+		Framing:       FramingNewline,
+		MaxFrameSize:  DefaultMaxFrameSize,
 	}
 }
 
+// client certificate verification modes
+const (
+	VerifyModeNone      = "none"
+	VerifyModePeer      = "peer"
+	VerifyModeForcePeer = "force_peer"
+)
+
+// fields of connection metadata added to events
+const (
+	RemoteAddrField       = "remote_addr"
+	LocalPortField        = "local_port"
+	TLSClientSubjectField = "tls_client_subject"
+)
+
 // errors
 var (
 	ErrorUnknownSocketType1 = errutil.NewFactory("%q is not a valid socket type")
 	ErrorSocketAccept       = errutil.NewFactory("socket accept error")
+	ErrorInvalidVerifyMode1 = errutil.NewFactory("%q is not a valid ssl_verify_mode")
+	ErrorTLSUnsupported1    = errutil.NewFactory("TLS is not supported by socket type %q")
+	ErrorSSLKeyPair         = errutil.NewFactory("ssl_certificate and ssl_key must be set together")
+	ErrorNoCertificate1     = errutil.NewFactory("no certificate found in %q")
 )
 
 // InitHandler initialize the input plugin
@@ -59,6 +107,19 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 		return nil, err
 	}
 
+	if err = CheckFraming(conf.Framing); err != nil {
+		return nil, err
+	}
+	if conf.ProxyProtocol && conf.Socket == "udp" {
+		return nil, ErrorProxyProtocolUnsupported1.New(nil, conf.Socket)
+	}
+	if conf.tlsConfig, err = conf.loadTLSConfig(); err != nil {
+		return nil, err
+	}
+	if conf.MaxConnections > 0 {
+		conf.connSlots = make(chan struct{}, conf.MaxConnections)
+	}
+
 	conf.Codec, err = config.GetCodecDefault(ctx, *raw, codecjson.ModuleName)
 	if err != nil {
 		return nil, err
@@ -67,6 +128,57 @@ func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputCo
 	return &conf, nil
 }
 
+// loadTLSConfig returns nil when TLS is not configured
+func (i *InputConfig) loadTLSConfig() (*tls.Config, error) {
+	var clientAuth tls.ClientAuthType
+	switch i.SSLVerifyMode {
+	case VerifyModeNone:
+		clientAuth = tls.NoClientCert
+	case VerifyModePeer:
+		clientAuth = tls.VerifyClientCertIfGiven
+	case VerifyModeForcePeer:
+		clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, ErrorInvalidVerifyMode1.New(nil, i.SSLVerifyMode)
+	}
+
+	if i.SSLCertificate == "" && i.SSLKey == "" {
+		return nil, nil
+	}
+	if i.SSLCertificate == "" || i.SSLKey == "" {
+		return nil, ErrorSSLKeyPair.New(nil)
+	}
+	switch i.Socket {
+	case "tcp", "unix":
+	default:
+		return nil, ErrorTLSUnsupported1.New(nil, i.Socket)
+	}
+
+	cert, err := tls.LoadX509KeyPair(i.SSLCertificate, i.SSLKey)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuth,
+	}
+
+	if len(i.SSLCertificateAuthorities) > 0 {
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		for _, path := range i.SSLCertificateAuthorities {
+			pem, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+				return nil, ErrorNoCertificate1.New(nil, path)
+			}
+		}
+	}
+
+	return tlsConfig, nil
+}
+
 // Start wraps the actual function starting the plugin
 func (i *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
 	logger := goglog.Logger
@@ -138,10 +250,16 @@ func (i *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEven
 			if err != nil {
 				return ErrorSocketAccept.New(err)
 			}
+			if !i.acquireConn() {
+				logger.Warnf("socket input: max_connections %d reached, close connection from %q", i.MaxConnections, conn.RemoteAddr())
+				conn.Close()
+				continue
+			}
 			func(conn net.Conn) {
 				eg.Go(func() error {
+					defer i.releaseConn()
 					defer conn.Close()
-					i.parse(ctx, conn, msgChan)
+					i.handleConn(ctx, conn, msgChan)
 					return nil
 				})
 			}(conn)
@@ -151,16 +269,98 @@ func (i *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEven
 	return eg.Wait()
 }
 
+func (i *InputConfig) acquireConn() bool {
+	if i.connSlots == nil {
+		return true
+	}
+	select {
+	case i.connSlots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (i *InputConfig) releaseConn() {
+	if i.connSlots != nil {
+		<-i.connSlots
+	}
+}
+
+// handleConn reads events from a stream connection until it is closed, idle or ctx is done
+func (i *InputConfig) handleConn(ctx context.Context, conn net.Conn, msgChan chan<- logevent.LogEvent) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	i.setIdleDeadline(conn)
+	if i.ProxyProtocol {
+		pconn, err := readProxyHeader(conn)
+		if err != nil {
+			goglog.Logger.Warnf("socket input: close connection from %q: %v", conn.RemoteAddr(), err)
+			return
+		}
+		conn = pconn
+	}
+
+	extra := connExtra(conn.RemoteAddr(), conn.LocalAddr())
+	if i.tlsConfig != nil {
+		tlsConn := tls.Server(conn, i.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			goglog.Logger.Warnf("socket input: TLS handshake with %q failed: %v", conn.RemoteAddr(), err)
+			return
+		}
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			extra[TLSClientSubjectField] = certs[0].Subject.String()
+		}
+		conn = tlsConn
+	}
+
+	frames := NewFrameReader(conn, i.Framing, i.MaxFrameSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		i.setIdleDeadline(conn)
+		frame, err := frames.ReadFrame()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				goglog.Logger.Debugf("socket input: close idle connection from %q", conn.RemoteAddr())
+			} else if err != io.EOF {
+				goglog.Logger.Warnf("socket input: close connection from %q: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		i.Codec.Decode(ctx, frame, copyExtra(extra), msgChan)
+	}
+}
+
+func (i *InputConfig) setIdleDeadline(conn net.Conn) {
+	if i.IdleTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(time.Duration(i.IdleTimeout) * time.Second))
+	}
+}
+
 func (i *InputConfig) handleUDP(ctx context.Context, conn net.PacketConn, msgChan chan<- logevent.LogEvent) error {
 	eg, ctx := errgroup.WithContext(ctx)
 	b := make([]byte, i.BufferSize) // read buf
-	pr, pw := io.Pipe()
-	defer pw.Close()
 
 	eg.Go(func() error {
 		select {
 		case <-ctx.Done():
-			pr.Close()
 			conn.Close()
 			return nil
 		}
@@ -168,45 +368,61 @@ func (i *InputConfig) handleUDP(ctx context.Context, conn net.PacketConn, msgCha
 
 	eg.Go(func() error {
 		for {
+			n, addr, err := conn.ReadFrom(b)
 			select {
 			case <-ctx.Done():
 				return nil
 			default:
 			}
-			n, _, err := conn.ReadFrom(b)
 			if err == io.EOF {
 				break
 			} else if err != nil {
 				return err
 			}
-			pw.Write(b[:n])
+			i.parse(ctx, bytes.NewReader(b[:n]), connExtra(addr, conn.LocalAddr()), msgChan)
 		}
 		return nil
 	})
 
-	eg.Go(func() error {
-		i.parse(ctx, pr, msgChan)
-		return nil
-	})
-
 	return eg.Wait()
 }
 
-func (i *InputConfig) parse(ctx context.Context, r io.Reader, msgChan chan<- logevent.LogEvent) {
-	b := bufio.NewReader(r)
+// parse decodes each frame of a datagram
+func (i *InputConfig) parse(ctx context.Context, r io.Reader, extra map[string]interface{}, msgChan chan<- logevent.LogEvent) {
+	frames := NewFrameReader(r, i.Framing, i.MaxFrameSize)
 	for {
-		select {
-		case <-ctx.Done():
+		frame, err := frames.ReadFrame()
+		if err != nil {
+			if err != io.EOF {
+				goglog.Logger.Warnf("socket input: drop datagram from %q: %v", extra[RemoteAddrField], err)
+			}
 			return
-		default:
 		}
+		i.Codec.Decode(ctx, frame, copyExtra(extra), msgChan)
+	}
+}
 
-		line, err := b.ReadBytes('\n')
-		if err != nil {
-			// EOF
-			return
+// connExtra returns connection metadata of remote and local addresses
+func connExtra(remote net.Addr, local net.Addr) map[string]interface{} {
+	extra := map[string]interface{}{}
+	if remote != nil {
+		if addr := remote.String(); addr != "" && addr != "<nil>" {
+			extra[RemoteAddrField] = addr
 		}
+	}
+	switch addr := local.(type) {
+	case *net.TCPAddr:
+		extra[LocalPortField] = addr.Port
+	case *net.UDPAddr:
+		extra[LocalPortField] = addr.Port
+	}
+	return extra
+}
 
-		i.Codec.Decode(ctx, line, nil, msgChan)
+func copyExtra(extra map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(extra))
+	for k, v := range extra {
+		result[k] = v
 	}
+	return result
 }
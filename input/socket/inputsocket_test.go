@@ -1,9 +1,23 @@
 package inputsocket
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
 	"net"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -14,6 +28,7 @@ import (
 	codecjson "github.com/viethqc/gogstash/codec/json"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
 )
 
 func init() {
@@ -110,7 +125,8 @@ func testWriteData(t *testing.T, conf config.Config, conn net.Conn) {
 
 	time.Sleep(200 * time.Millisecond)
 	if event, err := conf.TestGetOutputEvent(100 * time.Millisecond); assert.NoError(err) {
-		assert.Equal(map[string]interface{}{"foo": "bar"}, event.Extra)
+		assert.Equal("bar", event.Extra["foo"])
+		assert.Equal(conn.LocalAddr().String(), event.Extra[RemoteAddrField])
 	}
 
 	// malformed data
@@ -136,6 +152,371 @@ func testWriteData(t *testing.T, conf config.Config, conn net.Conn) {
 
 	time.Sleep(200 * time.Millisecond)
 	if event, err := conf.TestGetOutputEvent(100 * time.Millisecond); assert.NoError(err) {
-		assert.Equal(map[string]interface{}{"bar": "foo"}, event.Extra)
+		assert.Equal("foo", event.Extra["bar"])
 	}
 }
+
+func startSocketInput(t *testing.T, ctx context.Context, yaml string) (*InputConfig, chan logevent.LogEvent) {
+	require := require.New(t)
+	require.NotNil(require)
+
+	conf, err := config.LoadFromYAML([]byte(strings.TrimSpace(yaml)))
+	require.NoError(err)
+	require.Len(conf.InputRaw, 1)
+	input, err := InitHandler(ctx, &conf.InputRaw[0])
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	go input.Start(ctx, msgChan)
+	time.Sleep(200 * time.Millisecond)
+	return input.(*InputConfig), msgChan
+}
+
+func receiveEvent(t *testing.T, msgChan chan logevent.LogEvent) logevent.LogEvent {
+	select {
+	case event := <-msgChan:
+		return event
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "timeout waiting for event")
+	}
+	return logevent.LogEvent{}
+}
+
+func Test_input_socket_module_metadata(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, msgChan := startSocketInput(t, ctx, `
+input:
+  - type: socket
+    socket: udp
+    address: "127.0.0.1:9997"
+	`)
+
+	conn, err := net.Dial("udp", "127.0.0.1:9997")
+	require.NoError(err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("{\"foo\":\"bar\"}\n{\"bar\":\"foo\"}"))
+	require.NoError(err)
+
+	event := receiveEvent(t, msgChan)
+	assert.Equal("bar", event.Extra["foo"])
+	assert.Equal(conn.LocalAddr().String(), event.Extra[RemoteAddrField])
+	assert.Equal(9997, event.Extra[LocalPortField])
+	event = receiveEvent(t, msgChan)
+	assert.Equal("foo", event.Extra["bar"])
+	assert.Equal(conn.LocalAddr().String(), event.Extra[RemoteAddrField])
+}
+
+func Test_input_socket_module_limits(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, msgChan := startSocketInput(t, ctx, `
+input:
+  - type: socket
+    socket: tcp
+    address: "127.0.0.1:9996"
+    max_connections: 1
+    idle_timeout: 1
+	`)
+
+	conn1, err := net.Dial("tcp", "127.0.0.1:9996")
+	require.NoError(err)
+	defer conn1.Close()
+	_, err = conn1.Write([]byte("{\"conn\":1}\n"))
+	require.NoError(err)
+	event := receiveEvent(t, msgChan)
+	assert.EqualValues(1, event.Extra["conn"])
+	assert.Equal(9996, event.Extra[LocalPortField])
+
+	// over the limit, closed by server
+	conn2, err := net.Dial("tcp", "127.0.0.1:9996")
+	require.NoError(err)
+	defer conn2.Close()
+	conn2.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn2.Read(make([]byte, 1))
+	assert.Error(err)
+	assert.False(isTimeout(err))
+
+	// idle connection closed, the slot is released
+	conn1.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn1.Read(make([]byte, 1))
+	assert.Error(err)
+	assert.False(isTimeout(err))
+
+	conn3, err := net.Dial("tcp", "127.0.0.1:9996")
+	require.NoError(err)
+	defer conn3.Close()
+	_, err = conn3.Write([]byte("{\"conn\":3}\n"))
+	require.NoError(err)
+	event = receiveEvent(t, msgChan)
+	assert.EqualValues(3, event.Extra["conn"])
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+func Test_input_socket_module_tls(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-input-socket")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	caCert, caKey := generateCert(t, dir, "ca", nil, nil)
+	generateCert(t, dir, "server", caCert, caKey)
+	clientCert, clientKey := generateCert(t, dir, "client", caCert, caKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, msgChan := startSocketInput(t, ctx, `
+input:
+  - type: socket
+    socket: tcp
+    address: "127.0.0.1:9995"
+    ssl_certificate: "`+filepath.Join(dir, "server.crt")+`"
+    ssl_key: "`+filepath.Join(dir, "server.key")+`"
+    ssl_certificate_authorities: ["`+filepath.Join(dir, "ca.crt")+`"]
+    ssl_verify_mode: force_peer
+	`)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	// without client certificate
+	conn, err := tls.Dial("tcp", "127.0.0.1:9995", &tls.Config{RootCAs: roots})
+	if err == nil {
+		conn.Write([]byte("{\"foo\":\"bar\"}\n"))
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	assert.Error(err)
+
+	conn, err = tls.Dial("tcp", "127.0.0.1:9995", &tls.Config{
+		RootCAs: roots,
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{clientCert.Raw},
+			PrivateKey:  clientKey,
+		}},
+	})
+	require.NoError(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("{\"foo\":\"bar\"}\n"))
+	require.NoError(err)
+
+	event := receiveEvent(t, msgChan)
+	assert.Equal("bar", event.Extra["foo"])
+	assert.Equal("CN=client", event.Extra[TLSClientSubjectField])
+	assert.Equal(conn.LocalAddr().String(), event.Extra[RemoteAddrField])
+
+	select {
+	case event = <-msgChan:
+		assert.Fail("unexpected event", event)
+	default:
+	}
+
+	_, err = InitHandler(ctx, &config.ConfigRaw{
+		"socket":          "tcp",
+		"ssl_verify_mode": "always",
+	})
+	assert.Error(err)
+	_, err = InitHandler(ctx, &config.ConfigRaw{
+		"socket":          "udp",
+		"ssl_certificate": filepath.Join(dir, "server.crt"),
+		"ssl_key":         filepath.Join(dir, "server.key"),
+	})
+	assert.Error(err)
+}
+
+// generateCert writes name.crt and name.key to dir, self-signed when parent is nil
+func generateCert(t *testing.T, dir string, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	require := require.New(t)
+	require.NotNil(require)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(err)
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return cert, key
+}
+
+func Test_input_socket_framing(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	lengthPrefixed := func(frames ...string) string {
+		buf := bytes.Buffer{}
+		for _, frame := range frames {
+			binary.Write(&buf, binary.BigEndian, uint32(len(frame)))
+			buf.WriteString(frame)
+		}
+		return buf.String()
+	}
+
+	testCases := []struct {
+		framing string
+		data    string
+		frames  []string
+		err     bool
+	}{
+		{FramingNewline, "a\nb\nc", []string{"a\n", "b\n", "c"}, false},
+		{FramingNullByte, "a\x00b\nc\x00", []string{"a", "b\nc"}, false},
+		{FramingOctetCounting, "3 a\nb5 hello", []string{"a\nb", "hello"}, false},
+		{FramingOctetCounting, "<13>short frame\r\n4 <13>", []string{"<13>short frame", "<13>"}, false},
+		{FramingOctetCounting, "10 short", nil, true},
+		{FramingOctetCounting, "12a hello", nil, true},
+		{FramingOctetCounting, "99 too large", nil, true},
+		{FramingLengthPrefixed, lengthPrefixed("a\nb", "", "c"), []string{"a\nb", "", "c"}, false},
+		{FramingLengthPrefixed, lengthPrefixed("abc")[:5], nil, true},
+		{FramingNone, "a\nb\x00c", []string{"a\nb\x00c"}, false},
+		{FramingNone, "", nil, false},
+		{FramingNewline, "sixteen bytes ok\nseventeen bytes!!\nc", []string{"sixteen bytes ok\n"}, true},
+		{FramingNewline, strings.Repeat("x", 5000) + "\n", nil, true},
+		{FramingNullByte, "sixteen bytes ok\x00seventeen bytes!!", []string{"sixteen bytes ok"}, true},
+		{FramingOctetCounting, "<13>non transparent too large\n", nil, true},
+	}
+
+	for _, testCase := range testCases {
+		frames := NewFrameReader(strings.NewReader(testCase.data), testCase.framing, 16)
+		var result []string
+		var err error
+		for {
+			var frame []byte
+			if frame, err = frames.ReadFrame(); err != nil {
+				break
+			}
+			result = append(result, string(frame))
+		}
+		assert.Equal(testCase.frames, result, "%s %q", testCase.framing, testCase.data)
+		if testCase.err {
+			assert.NotEqual(io.EOF, err, "%s %q", testCase.framing, testCase.data)
+		} else {
+			assert.Equal(io.EOF, err, "%s %q", testCase.framing, testCase.data)
+		}
+	}
+
+	// huge frame lengths without max_frame_size allocate only for the data received
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	frames := NewFrameReader(strings.NewReader("\xff\xff\xff\xffshort"), FramingLengthPrefixed, 0)
+	_, err := frames.ReadFrame()
+	assert.Equal(io.ErrUnexpectedEOF, err)
+	frames = NewFrameReader(strings.NewReader("4294967295 short"), FramingOctetCounting, 0)
+	_, err = frames.ReadFrame()
+	assert.Equal(io.ErrUnexpectedEOF, err)
+	runtime.ReadMemStats(&after)
+	assert.True(after.TotalAlloc-before.TotalAlloc < 64*1024*1024, "allocated %d bytes", after.TotalAlloc-before.TotalAlloc)
+	frames = NewFrameReader(strings.NewReader(lengthPrefixed(strings.Repeat("x", 3*DefaultMaxFrameSize))), FramingLengthPrefixed, 0)
+	frame, err := frames.ReadFrame()
+	assert.NoError(err)
+	assert.Len(frame, 3*DefaultMaxFrameSize)
+
+	assert.NoError(CheckFraming(FramingOctetCounting))
+	assert.Error(CheckFraming("crlf"))
+}
+
+func Test_input_socket_module_proxy_protocol(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, msgChan := startSocketInput(t, ctx, `
+input:
+  - type: socket
+    socket: tcp
+    address: "127.0.0.1:9994"
+    framing: octet_counting
+    proxy_protocol: true
+	`)
+
+	send := func(data string) {
+		conn, err := net.Dial("tcp", "127.0.0.1:9994")
+		require.NoError(err)
+		defer conn.Close()
+		_, err = conn.Write([]byte(data))
+		require.NoError(err)
+	}
+
+	send("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n12 {\"foo\":\"v1\"}")
+	event := receiveEvent(t, msgChan)
+	assert.Equal("v1", event.Extra["foo"])
+	assert.Equal("192.0.2.1:56324", event.Extra[RemoteAddrField])
+	assert.Equal(9994, event.Extra[LocalPortField])
+
+	v2 := bytes.Buffer{}
+	v2.Write(proxyV2Signature)
+	v2.Write([]byte{0x21, 0x21, 0, 36})
+	v2.Write(net.ParseIP("2001:db8::1").To16())
+	v2.Write(net.ParseIP("2001:db8::2").To16())
+	binary.Write(&v2, binary.BigEndian, uint16(40000))
+	binary.Write(&v2, binary.BigEndian, uint16(443))
+	v2.WriteString("12 {\"foo\":\"v2\"}")
+	send(v2.String())
+	event = receiveEvent(t, msgChan)
+	assert.Equal("v2", event.Extra["foo"])
+	assert.Equal("[2001:db8::1]:40000", event.Extra[RemoteAddrField])
+
+	// LOCAL command keeps the proxy address
+	v2.Reset()
+	v2.Write(proxyV2Signature)
+	v2.Write([]byte{0x20, 0x00, 0, 0})
+	v2.WriteString("15 {\"foo\":\"local\"}")
+	send(v2.String())
+	event = receiveEvent(t, msgChan)
+	assert.Equal("local", event.Extra["foo"])
+	assert.Contains(event.Extra[RemoteAddrField], "127.0.0.1:")
+
+	// missing header
+	send("12 {\"foo\":\"v0\"}")
+	select {
+	case event = <-msgChan:
+		assert.Fail("unexpected event", event)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	_, err := InitHandler(ctx, &config.ConfigRaw{"socket": "tcp", "framing": "crlf"})
+	assert.Error(err)
+	_, err = InitHandler(ctx, &config.ConfigRaw{"socket": "udp", "proxy_protocol": true})
+	assert.Error(err)
+}
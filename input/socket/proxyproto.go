@@ -0,0 +1,142 @@
+package inputsocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+)
+
+// signature of PROXY protocol v2 header
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// maximum length of PROXY protocol v1 header, including CRLF
+const proxyV1MaxLength = 107
+
+// errors
+var (
+	ErrorProxyProtocolUnsupported1 = errutil.NewFactory("PROXY protocol is not supported by socket type %q")
+	ErrorInvalidProxyHeader1       = errutil.NewFactory("invalid PROXY protocol header: %s")
+)
+
+// proxyConn is a connection with the client address restored from PROXY protocol header
+type proxyConn struct {
+	net.Conn
+	r          *bufio.Reader
+	remoteAddr net.Addr
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader reads PROXY protocol v1 or v2 header of conn, connections without header are rejected,
+// the LOCAL command and UNKNOWN protocol, e.g. of proxy health checks, keep the proxy address
+func readProxyHeader(conn net.Conn) (net.Conn, error) {
+	r := bufio.NewReader(conn)
+	pconn := &proxyConn{Conn: conn, r: r}
+
+	prefix, err := r.Peek(len(proxyV2Signature))
+	if err != nil && len(prefix) < 5 {
+		return nil, ErrorInvalidProxyHeader1.New(err, "missing header")
+	}
+	switch {
+	case bytes.Equal(prefix, proxyV2Signature):
+		pconn.remoteAddr, err = readProxyV2(r)
+	case bytes.HasPrefix(prefix, []byte("PROXY")):
+		pconn.remoteAddr, err = readProxyV1(r)
+	default:
+		return nil, ErrorInvalidProxyHeader1.New(nil, "missing header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pconn, nil
+}
+
+// readProxyV1 parses "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	line := make([]byte, 0, proxyV1MaxLength)
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyV1MaxLength {
+			return nil, ErrorInvalidProxyHeader1.New(nil, "header too long")
+		}
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrorInvalidProxyHeader1.New(err, "incomplete header")
+		}
+		line = append(line, c)
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrorInvalidProxyHeader1.New(nil, strings.TrimSpace(string(line)))
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, ErrorInvalidProxyHeader1.New(err, strings.TrimSpace(string(line)))
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// readProxyV2 parses the binary header, see https://www.haproxy.org/download/2.0/doc/proxy-protocol.txt
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrorInvalidProxyHeader1.New(err, "incomplete header")
+	}
+	if header[12]>>4 != 2 {
+		return nil, ErrorInvalidProxyHeader1.New(nil, "unsupported version")
+	}
+	command := header[12] & 0x0f
+	family := header[13] >> 4
+	transport := header[13] & 0x0f
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, ErrorInvalidProxyHeader1.New(err, "incomplete header")
+	}
+
+	// LOCAL command
+	if command == 0 {
+		return nil, nil
+	}
+	if command != 1 {
+		return nil, ErrorInvalidProxyHeader1.New(nil, "unsupported command")
+	}
+
+	var ip net.IP
+	var port int
+	switch {
+	case family == 1 && len(payload) >= 12:
+		ip = net.IP(payload[0:4])
+		port = int(binary.BigEndian.Uint16(payload[8:10]))
+	case family == 2 && len(payload) >= 36:
+		ip = net.IP(payload[0:16])
+		port = int(binary.BigEndian.Uint16(payload[32:34]))
+	case family == 0 || family == 3:
+		// UNSPEC or UNIX
+		return nil, nil
+	default:
+		return nil, ErrorInvalidProxyHeader1.New(nil, "invalid address block")
+	}
+	if transport == 2 {
+		return &net.UDPAddr{IP: ip, Port: port}, nil
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
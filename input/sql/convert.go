@@ -0,0 +1,126 @@
+package inputsql
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kinds of database column types
+const (
+	kindOther = iota
+	kindInt
+	kindFloat
+	kindBool
+	kindTime
+	kindBinary
+)
+
+var columnKinds = map[string]int{
+	"INT":              kindInt,
+	"INTEGER":          kindInt,
+	"TINYINT":          kindInt,
+	"SMALLINT":         kindInt,
+	"MEDIUMINT":        kindInt,
+	"BIGINT":           kindInt,
+	"INT2":             kindInt,
+	"INT4":             kindInt,
+	"INT8":             kindInt,
+	"SERIAL":           kindInt,
+	"BIGSERIAL":        kindInt,
+	"YEAR":             kindInt,
+	"DECIMAL":          kindFloat,
+	"NUMERIC":          kindFloat,
+	"FLOAT":            kindFloat,
+	"FLOAT4":           kindFloat,
+	"FLOAT8":           kindFloat,
+	"DOUBLE":           kindFloat,
+	"DOUBLE PRECISION": kindFloat,
+	"REAL":             kindFloat,
+	"BOOL":             kindBool,
+	"BOOLEAN":          kindBool,
+	"DATE":             kindTime,
+	"DATETIME":         kindTime,
+	"TIMESTAMP":        kindTime,
+	"TIMESTAMPTZ":      kindTime,
+	"BLOB":             kindBinary,
+	"TINYBLOB":         kindBinary,
+	"MEDIUMBLOB":       kindBinary,
+	"LONGBLOB":         kindBinary,
+	"BINARY":           kindBinary,
+	"VARBINARY":        kindBinary,
+	"BYTEA":            kindBinary,
+}
+
+// layouts of time values returned as text, parsed in UTC without zone
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// columnKind returns kind of database type name, e.g. "DECIMAL(10,2)", "UNSIGNED INT"
+func columnKind(dbType string) int {
+	name := strings.ToUpper(strings.TrimSpace(dbType))
+	if idx := strings.IndexByte(name, '('); idx >= 0 {
+		name = strings.TrimSpace(name[:idx])
+	}
+	name = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(name, "UNSIGNED "), " UNSIGNED"))
+	return columnKinds[name]
+}
+
+// convertValue converts a scanned column value to the event field value by the database type,
+// drivers return numbers, booleans and times as text for some types
+func convertValue(value interface{}, dbType string) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		if columnKind(dbType) == kindBinary {
+			return v
+		}
+		return convertString(string(v), dbType)
+	case string:
+		return convertString(v, dbType)
+	case time.Time:
+		return v.UTC()
+	default:
+		return v
+	}
+}
+
+func convertString(s string, dbType string) interface{} {
+	switch columnKind(dbType) {
+	case kindInt:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return u
+		}
+	case kindFloat:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case kindBool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case kindTime:
+		if t, ok := parseTime(s); ok {
+			return t
+		}
+	}
+	return s
+}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
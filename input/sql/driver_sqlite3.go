@@ -0,0 +1,8 @@
+// +build cgo
+
+package inputsql
+
+import (
+	// sqlite3 driver requires cgo
+	_ "github.com/mattn/go-sqlite3"
+)
@@ -0,0 +1,255 @@
+package inputsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	// database drivers
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+	"github.com/viethqc/gogstash/config/scheduler"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "sql"
+
+// LastValueParameter is the statement parameter of the tracked value
+const LastValueParameter = "sql_last_value"
+
+// types of tracking column
+const (
+	TrackingNumeric   = "numeric"
+	TrackingTimestamp = "timestamp"
+)
+
+// InputConfig holds the configuration json fields and internal objects
+type InputConfig struct {
+	config.InputConfig
+	scheduler.Config
+	Driver               string                 `json:"driver"`                    // one of ["mysql", "postgres", "sqlite3"]
+	DSN                  string                 `json:"dsn"`                       // data source name of driver
+	Statement            string                 `json:"statement,omitempty"`       // query with named parameters, e.g. ":sql_last_value"
+	StatementPath        string                 `json:"statement_path,omitempty"`  // file of statement
+	Parameters           map[string]interface{} `json:"parameters,omitempty"`      // values of named parameters
+	TrackingColumn       string                 `json:"tracking_column,omitempty"` // column tracked as sql_last_value, default: time of last run
+	TrackingColumnType   string                 `json:"tracking_column_type"`      // one of ["numeric", "timestamp"], default: "numeric"
+	TimestampColumn      string                 `json:"timestamp_column,omitempty"`
+	LowercaseColumnNames bool                   `json:"lowercase_column_names"`
+	Paging               bool                   `json:"paging"`
+	PageSize             int                    `json:"page_size"`
+	SinceDBPath          string                 `json:"sincedb_path,omitempty"`
+	CleanRun             bool                   `json:"clean_run"` // ignore sql_last_value saved in sincedb
+
+	db             *sql.DB
+	scheduler      *scheduler.Scheduler
+	lastValue      interface{}
+	lastValueMutex sync.Mutex
+}
+
+// DefaultInputConfig returns an InputConfig struct with default values
+func DefaultInputConfig() InputConfig {
+	return InputConfig{
+		InputConfig: config.InputConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		TrackingColumnType:   TrackingNumeric,
+		LowercaseColumnNames: true,
+		PageSize:             100000,
+		SinceDBPath:          ".sincedb_sql.json",
+	}
+}
+
+// errors
+var (
+	ErrorNoStatement                = errutil.NewFactory("statement or statement_path is required")
+	ErrorUnknownParameter1          = errutil.NewFactory("unknown statement parameter %q")
+	ErrorUnknownTrackingColumnType1 = errutil.NewFactory("unknown tracking_column_type %q")
+	ErrorInvalidPageSize1           = errutil.NewFactory("invalid page_size %d")
+	ErrorReservedParameter1         = errutil.NewFactory("parameter %q is reserved")
+	ErrorTrackingColumnNotSelected1 = errutil.NewFactory("tracking_column %q not selected")
+)
+
+// InitHandler initialize the input plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputConfig, error) {
+	conf := DefaultInputConfig()
+	err := config.ReflectConfig(raw, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.StatementPath != "" {
+		statement, err := ioutil.ReadFile(conf.StatementPath)
+		if err != nil {
+			return nil, err
+		}
+		conf.Statement = string(statement)
+	}
+	if conf.Statement = strings.TrimRight(strings.TrimSpace(conf.Statement), ";"); conf.Statement == "" {
+		return nil, ErrorNoStatement.New(nil)
+	}
+	if _, ok := conf.Parameters[LastValueParameter]; ok {
+		return nil, ErrorReservedParameter1.New(nil, LastValueParameter)
+	}
+
+	switch conf.TrackingColumnType {
+	case TrackingNumeric, TrackingTimestamp:
+	default:
+		return nil, ErrorUnknownTrackingColumnType1.New(nil, conf.TrackingColumnType)
+	}
+	if conf.LowercaseColumnNames {
+		conf.TrackingColumn = strings.ToLower(conf.TrackingColumn)
+		conf.TimestampColumn = strings.ToLower(conf.TimestampColumn)
+	}
+	if conf.Paging && conf.PageSize <= 0 {
+		return nil, ErrorInvalidPageSize1.New(nil, conf.PageSize)
+	}
+
+	// without schedule, the statement runs once
+	if conf.Schedule != "" || conf.Every != "" {
+		if conf.scheduler, err = scheduler.New(conf.Config, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = conf.loadSinceDB(); err != nil {
+		return nil, err
+	}
+
+	if conf.db, err = sql.Open(conf.Driver, conf.DSN); err != nil {
+		return nil, err
+	}
+
+	return &conf, nil
+}
+
+// Start wraps the actual function starting the plugin,
+// returns after the first run if no schedule set
+func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) (err error) {
+	defer t.db.Close()
+
+	if t.scheduler == nil {
+		return t.run(ctx, msgChan)
+	}
+
+	t.scheduler.Run(ctx, func(ctx context.Context) {
+		if err := t.run(ctx, msgChan); err != nil {
+			goglog.Logger.Errorf("input sql: %v", err)
+		}
+	})
+	return nil
+}
+
+// run queries all pages of the statement and saves sql_last_value
+func (t *InputConfig) run(ctx context.Context, msgChan chan<- logevent.LogEvent) (err error) {
+	start := time.Now()
+	params := map[string]interface{}{}
+	for name, value := range t.Parameters {
+		params[name] = value
+	}
+	params[LastValueParameter] = t.getLastValue()
+
+	query, args, err := bindStatement(t.Statement, params, t.Driver == "postgres")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// all rows sent, the next run queries rows since this one
+		if err == nil && ctx.Err() == nil && t.TrackingColumn == "" {
+			t.setLastValue(start.UTC())
+		}
+		if saveErr := t.saveSinceDB(start); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}()
+
+	for offset := 0; ; offset += t.PageSize {
+		pageQuery := query
+		if t.Paging {
+			pageQuery = fmt.Sprintf("SELECT * FROM (%s) AS gogstash_page LIMIT %d OFFSET %d", query, t.PageSize, offset)
+		}
+		count, err := t.queryPage(ctx, pageQuery, args, msgChan)
+		if err != nil {
+			return err
+		}
+		if !t.Paging || count < t.PageSize || ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// queryPage sends rows of query as events, returns count of rows sent
+func (t *InputConfig) queryPage(ctx context.Context, query string, args []interface{}, msgChan chan<- logevent.LogEvent) (count int, err error) {
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, len(columns))
+	tracked := t.TrackingColumn == ""
+	for i, column := range columns {
+		if names[i] = column.Name(); t.LowercaseColumnNames {
+			names[i] = strings.ToLower(names[i])
+		}
+		tracked = tracked || names[i] == t.TrackingColumn
+	}
+	if !tracked {
+		return 0, ErrorTrackingColumnNotSelected1.New(nil, t.TrackingColumn)
+	}
+
+	values := make([]interface{}, len(columns))
+	dests := make([]interface{}, len(columns))
+	for i := range values {
+		dests[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(dests...); err != nil {
+			return count, err
+		}
+
+		event := logevent.LogEvent{
+			Timestamp: time.Now(),
+			Extra:     make(map[string]interface{}, len(columns)),
+		}
+		for i, column := range columns {
+			event.Extra[names[i]] = convertValue(values[i], column.DatabaseTypeName())
+		}
+		if ts, ok := event.Extra[t.TimestampColumn].(time.Time); ok {
+			event.Timestamp = ts
+		}
+
+		select {
+		case <-ctx.Done():
+			return count, nil
+		case msgChan <- event:
+		}
+		count++
+
+		if t.TrackingColumn != "" {
+			if value, ok := t.normalizeLastValue(event.Extra[t.TrackingColumn]); ok {
+				t.setLastValue(value)
+			} else {
+				goglog.Logger.Warnf("input sql: tracking_column %q value %v is not %s", t.TrackingColumn, event.Extra[t.TrackingColumn], t.TrackingColumnType)
+			}
+		}
+	}
+	return count, rows.Err()
+}
@@ -0,0 +1,292 @@
+// +build cgo
+
+package inputsql
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+	config.RegistInputHandler(ModuleName, InitHandler)
+}
+
+// prepareDB creates sqlite database of audit rows in dir
+func prepareDB(t *testing.T, dir string, count int) string {
+	require := require.New(t)
+	dsn := filepath.Join(dir, "audit.db")
+	db, err := sql.Open("sqlite3", dsn)
+	require.NoError(err)
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE audit (
+		ID INTEGER PRIMARY KEY,
+		user TEXT,
+		amount DECIMAL(10,2),
+		success BOOLEAN,
+		created_at DATETIME,
+		payload BLOB
+	)`)
+	require.NoError(err)
+
+	ts := time.Date(2019, time.January, 4, 0, 55, 36, 0, time.UTC)
+	for i := 1; i <= count; i++ {
+		_, err = db.Exec("INSERT INTO audit (id, user, amount, success, created_at, payload) VALUES (?, ?, ?, ?, ?, ?)",
+			i, "user"+string(rune('a'+i-1)), float64(i)+0.5, i%2 == 1, ts.Add(time.Duration(i)*time.Second), []byte{0, byte(i)})
+		require.NoError(err)
+	}
+	return dsn
+}
+
+func insertRow(t *testing.T, dsn string, id int) {
+	db, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("INSERT INTO audit (id, user) VALUES (?, ?)", id, "late")
+	require.NoError(t, err)
+}
+
+func runSQL(t *testing.T, raw config.ConfigRaw) []logevent.LogEvent {
+	require := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	input, err := InitHandler(ctx, &raw)
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 100)
+	require.NoError(input.Start(ctx, msgChan))
+	close(msgChan)
+
+	events := []logevent.LogEvent{}
+	for event := range msgChan {
+		events = append(events, event)
+	}
+	return events
+}
+
+func Test_input_sql_bind_statement(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	params := map[string]interface{}{
+		"sql_last_value": int64(10),
+		"user":           "root",
+		"limit":          float64(5),
+	}
+
+	query, args, err := bindStatement("SELECT * FROM audit WHERE id > :sql_last_value AND user = :user AND note != ':user' LIMIT :limit", params, false)
+	assert.NoError(err)
+	assert.Equal("SELECT * FROM audit WHERE id > ? AND user = ? AND note != ':user' LIMIT ?", query)
+	assert.Equal([]interface{}{int64(10), "root", int64(5)}, args)
+
+	query, args, err = bindStatement(`SELECT created_at::date FROM "audit:user" WHERE id > :sql_last_value AND user = :user`, params, true)
+	assert.NoError(err)
+	assert.Equal(`SELECT created_at::date FROM "audit:user" WHERE id > $1 AND user = $2`, query)
+	assert.Equal([]interface{}{int64(10), "root"}, args)
+
+	_, _, err = bindStatement("SELECT * FROM audit WHERE id > :unknown", params, false)
+	assert.Error(err)
+}
+
+func Test_input_sql_convert(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	assert.Equal(int64(42), convertValue([]byte("42"), "INT"))
+	assert.Equal(uint64(18446744073709551615), convertValue([]byte("18446744073709551615"), "UNSIGNED BIGINT"))
+	assert.Equal(1.5, convertValue([]byte("1.5"), "DECIMAL(10,2)"))
+	assert.Equal(1.5, convertValue([]byte("1.5"), "numeric"))
+	assert.Equal(true, convertValue([]byte("1"), "BOOLEAN"))
+	assert.Equal(time.Date(2019, time.January, 4, 0, 55, 36, 0, time.UTC), convertValue([]byte("2019-01-04 00:55:36"), "DATETIME"))
+	assert.Equal(time.Date(2019, time.January, 4, 0, 0, 0, 0, time.UTC), convertValue("2019-01-04", "DATE"))
+	assert.Equal([]byte{0, 1}, convertValue([]byte{0, 1}, "BYTEA"))
+	assert.Equal("text", convertValue([]byte("text"), "VARCHAR"))
+	assert.Equal("not a number", convertValue([]byte("not a number"), "INT"))
+	assert.Equal(int64(7), convertValue(int64(7), "INTEGER"))
+	assert.Nil(convertValue(nil, "INTEGER"))
+}
+
+func Test_input_sql_module(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-sql")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	dsn := prepareDB(t, dir, 3)
+	sincedb := filepath.Join(dir, "sincedb.json")
+
+	raw := config.ConfigRaw{
+		"driver":           "sqlite3",
+		"dsn":              dsn,
+		"statement":        "SELECT * FROM audit WHERE id > :sql_last_value AND user != :excluded ORDER BY id",
+		"parameters":       map[string]interface{}{"excluded": "userb"},
+		"tracking_column":  "ID",
+		"timestamp_column": "created_at",
+		"sincedb_path":     sincedb,
+	}
+	events := runSQL(t, raw)
+	require.Len(events, 2)
+
+	event := events[0]
+	assert.Equal(int64(1), event.Extra["id"])
+	assert.Equal("usera", event.Extra["user"])
+	assert.Equal(1.5, event.Extra["amount"])
+	assert.Equal(true, event.Extra["success"])
+	assert.Equal(time.Date(2019, time.January, 4, 0, 55, 37, 0, time.UTC), event.Extra["created_at"])
+	assert.Equal(time.Date(2019, time.January, 4, 0, 55, 37, 0, time.UTC), event.Timestamp)
+	assert.Equal([]byte{0, 1}, event.Extra["payload"])
+	assert.Equal(int64(3), events[1].Extra["id"])
+
+	saved, err := ioutil.ReadFile(sincedb)
+	require.NoError(err)
+	assert.Contains(string(saved), `"sql_last_value":3`)
+
+	// next run resumes after sql_last_value persisted
+	insertRow(t, dsn, 4)
+	events = runSQL(t, raw)
+	require.Len(events, 1)
+	assert.Equal(int64(4), events[0].Extra["id"])
+	assert.Nil(events[0].Extra["amount"])
+
+	// clean run ignores sql_last_value persisted
+	raw["clean_run"] = true
+	events = runSQL(t, raw)
+	assert.Len(events, 3)
+}
+
+func Test_input_sql_paging(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-sql")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	dsn := prepareDB(t, dir, 7)
+
+	events := runSQL(t, config.ConfigRaw{
+		"driver":          "sqlite3",
+		"dsn":             dsn,
+		"statement":       "SELECT id FROM audit WHERE id > :sql_last_value ORDER BY id;",
+		"tracking_column": "id",
+		"paging":          true,
+		"page_size":       3,
+		"sincedb_path":    "/dev/null",
+	})
+	require.Len(events, 7)
+	for i, event := range events {
+		assert.Equal(int64(i+1), event.Extra["id"])
+	}
+}
+
+func Test_input_sql_last_run(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-sql")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	dsn := prepareDB(t, dir, 2)
+	sincedb := filepath.Join(dir, "sincedb.json")
+
+	raw := config.ConfigRaw{
+		"driver":       "sqlite3",
+		"dsn":          dsn,
+		"statement":    "SELECT id, :sql_last_value AS since FROM audit ORDER BY id",
+		"sincedb_path": sincedb,
+	}
+	events := runSQL(t, raw)
+	require.Len(events, 2)
+	// sqlite expressions have no declared type, values bound are returned as text
+	since, ok := parseTime(events[0].GetString("since"))
+	require.True(ok)
+	assert.Equal(time.Unix(0, 0).UTC(), since)
+
+	// without tracking column, sql_last_value is the time of the last run
+	before := time.Now()
+	runSQL(t, raw)
+	events = runSQL(t, raw)
+	require.Len(events, 2)
+	since, ok = parseTime(events[0].GetString("since"))
+	require.True(ok)
+	assert.WithinDuration(before, since, 5*time.Second)
+}
+
+func Test_input_sql_schedule(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-sql")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	dsn := prepareDB(t, dir, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	raw := config.ConfigRaw{
+		"driver":          "sqlite3",
+		"dsn":             dsn,
+		"statement":       "SELECT id FROM audit WHERE id > :sql_last_value ORDER BY id",
+		"tracking_column": "id",
+		"every":           "200ms",
+		"sincedb_path":    "/dev/null",
+	}
+	input, err := InitHandler(ctx, &raw)
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- input.Start(ctx, msgChan)
+	}()
+
+	assert.Equal(int64(1), (<-msgChan).Extra["id"])
+	insertRow(t, dsn, 2)
+	select {
+	case event := <-msgChan:
+		assert.Equal(int64(2), event.Extra["id"])
+	case <-time.After(3 * time.Second):
+		assert.Fail("scheduled query not run")
+	}
+
+	cancel()
+	assert.NoError(<-done)
+}
+
+func Test_input_sql_config(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	for _, raw := range []config.ConfigRaw{
+		{"driver": "sqlite3", "dsn": ":memory:"},
+		{"driver": "sqlite3", "dsn": ":memory:", "statement": "SELECT 1", "tracking_column_type": "string"},
+		{"driver": "sqlite3", "dsn": ":memory:", "statement": "SELECT 1", "paging": true, "page_size": 0},
+		{"driver": "sqlite3", "dsn": ":memory:", "statement": "SELECT 1", "parameters": map[string]interface{}{"sql_last_value": 1}},
+		{"driver": "unknown", "dsn": ":memory:", "statement": "SELECT 1"},
+	} {
+		_, err := InitHandler(context.Background(), &raw)
+		assert.Error(err, raw)
+	}
+}
@@ -0,0 +1,119 @@
+package inputsql
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/viethqc/gogstash/KDGoLib/futil"
+	"github.com/viethqc/gogstash/config/goglog"
+)
+
+// SinceDBInfo is the tracking state of the query
+type SinceDBInfo struct {
+	SQLLastValue interface{} `json:"sql_last_value"`
+	LastRun      time.Time   `json:"last_run"`
+}
+
+// initialLastValue returns sql_last_value before the first run
+func (t *InputConfig) initialLastValue() interface{} {
+	if t.TrackingColumn != "" && t.TrackingColumnType == TrackingNumeric {
+		return int64(0)
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+// normalizeLastValue converts v to the type of tracking_column_type, ok is false if not convertible
+func (t *InputConfig) normalizeLastValue(v interface{}) (result interface{}, ok bool) {
+	if t.TrackingColumn == "" || t.TrackingColumnType == TrackingTimestamp {
+		switch value := v.(type) {
+		case time.Time:
+			return value.UTC(), true
+		case string:
+			return parseTime(value)
+		}
+		return nil, false
+	}
+
+	switch value := v.(type) {
+	case int64, uint64, float64:
+		return value, true
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i, true
+		}
+		if f, err := value.Float64(); err == nil {
+			return f, true
+		}
+	case string:
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func (t *InputConfig) getLastValue() interface{} {
+	t.lastValueMutex.Lock()
+	defer t.lastValueMutex.Unlock()
+	return t.lastValue
+}
+
+func (t *InputConfig) setLastValue(v interface{}) {
+	t.lastValueMutex.Lock()
+	defer t.lastValueMutex.Unlock()
+	t.lastValue = v
+}
+
+func (t *InputConfig) isSinceDBDisabled() bool {
+	return t.SinceDBPath == "" || t.SinceDBPath == "/dev/null"
+}
+
+func (t *InputConfig) loadSinceDB() (err error) {
+	t.lastValue = t.initialLastValue()
+	if t.CleanRun || t.isSinceDBDisabled() || !futil.IsExist(t.SinceDBPath) {
+		return nil
+	}
+
+	raw, err := ioutil.ReadFile(t.SinceDBPath)
+	if err != nil {
+		return err
+	}
+	since := SinceDBInfo{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err = decoder.Decode(&since); err != nil {
+		return err
+	}
+
+	if value, ok := t.normalizeLastValue(since.SQLLastValue); ok {
+		t.lastValue = value
+	} else {
+		goglog.Logger.Warnf("input sql: ignore sql_last_value %v of %q, not %s", since.SQLLastValue, t.SinceDBPath, t.TrackingColumnType)
+	}
+	return nil
+}
+
+func (t *InputConfig) saveSinceDB(lastRun time.Time) (err error) {
+	if t.isSinceDBDisabled() {
+		return nil
+	}
+
+	raw, err := json.Marshal(SinceDBInfo{
+		SQLLastValue: t.getLastValue(),
+		LastRun:      lastRun,
+	})
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(t.SinceDBPath, raw, 0664); err != nil {
+		goglog.Logger.Errorf("input sql: write sincedb failed: %q\n%s", t.SinceDBPath, err)
+		return err
+	}
+	return nil
+}
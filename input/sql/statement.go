@@ -0,0 +1,69 @@
+package inputsql
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"unicode"
+)
+
+// bindStatement replaces named parameters ":name" of statement with placeholders of the driver,
+// "$1", "$2", ... if dollar, otherwise "?", and returns values of them in order.
+// Parameters are not replaced in quoted strings or identifiers, nor in postgres casts "::type".
+func bindStatement(statement string, params map[string]interface{}, dollar bool) (query string, args []interface{}, err error) {
+	buf := bytes.Buffer{}
+	runes := []rune(statement)
+	var quote rune
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == ':' && i+1 < len(runes) && runes[i+1] == ':':
+			// postgres cast
+			buf.WriteString("::")
+			i++
+			continue
+		case c == ':' && i+1 < len(runes) && isParamStart(runes[i+1]):
+			end := i + 1
+			for end < len(runes) && isParamPart(runes[end]) {
+				end++
+			}
+			name := string(runes[i+1 : end])
+			value, ok := params[name]
+			if !ok {
+				return "", nil, ErrorUnknownParameter1.New(nil, name)
+			}
+			args = append(args, bindValue(value))
+			if dollar {
+				buf.WriteString("$" + strconv.Itoa(len(args)))
+			} else {
+				buf.WriteString("?")
+			}
+			i = end - 1
+			continue
+		}
+		buf.WriteRune(c)
+	}
+	return buf.String(), args, nil
+}
+
+func isParamStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
+
+func isParamPart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// bindValue converts integral numbers of config, decoded as float64, to int64
+func bindValue(value interface{}) interface{} {
+	if f, ok := value.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int64(f)
+	}
+	return value
+}
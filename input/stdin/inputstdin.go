@@ -0,0 +1,97 @@
+package inputstdin
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "stdin"
+
+// InputConfig holds the configuration json fields and internal objects
+type InputConfig struct {
+	config.InputConfig
+
+	hostname string
+	reader   io.Reader
+}
+
+// DefaultInputConfig returns an InputConfig struct with default values
+func DefaultInputConfig() InputConfig {
+	return InputConfig{
+		InputConfig: config.InputConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		reader: os.Stdin,
+	}
+}
+
+// InitHandler initialize the input plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputConfig, error) {
+	conf := DefaultInputConfig()
+	err := config.ReflectConfig(raw, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.hostname, err = os.Hostname(); err != nil {
+		return nil, err
+	}
+
+	conf.Codec, err = config.GetCodec(ctx, *raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conf, nil
+}
+
+// Start wraps the actual function starting the plugin, returns at the end of stdin
+func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) (err error) {
+	done := make(chan error, 1)
+	// reading stdin can not be interrupted, leave it blocked if ctx done
+	go func() {
+		done <- t.read(ctx, msgChan)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err = <-done:
+		return err
+	}
+}
+
+func (t *InputConfig) read(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
+	logger := goglog.Logger
+	reader := bufio.NewReader(t.reader)
+	var count int64
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			extra := map[string]interface{}{
+				"host": t.hostname,
+			}
+			if _, decodeErr := t.Codec.Decode(ctx, line, extra, msgChan); decodeErr != nil {
+				logger.Errorf("input stdin decode error: %v", decodeErr)
+			}
+			count++
+		}
+		if err == io.EOF {
+			logger.Infof("input stdin reached end of stream after %d lines", count)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package inputstdin
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	codecjson "github.com/viethqc/gogstash/codec/json"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+	outputfile "github.com/viethqc/gogstash/output/file"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+	config.RegistInputHandler(ModuleName, InitHandler)
+	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
+	config.RegistCodecHandler(codecjson.ModuleName, codecjson.InitHandler)
+	config.RegistOutputHandler(outputfile.ModuleName, outputfile.InitHandler)
+}
+
+func Test_input_stdin_module(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+	input, err := InitHandler(ctx, &config.ConfigRaw{
+		"type":  ModuleName,
+		"codec": codecjson.ModuleName,
+	})
+	require.NoError(err)
+	input.(*InputConfig).reader = strings.NewReader("{\"message\":\"a\"}\r\n\n{\"message\":\"b\"}")
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	require.NoError(input.Start(ctx, msgChan))
+	close(msgChan)
+
+	messages := []string{}
+	for event := range msgChan {
+		messages = append(messages, event.Message)
+		assert.NotEmpty(event.Extra["host"])
+	}
+	assert.Equal([]string{"a", "b"}, messages)
+}
+
+func Test_input_stdin_module_end_of_stream(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	r, w, err := os.Pipe()
+	require.NoError(err)
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() {
+		os.Stdin = stdin
+	}()
+
+	conf, err := config.LoadFromYAML([]byte(strings.TrimSpace(`
+debugch: true
+input:
+  - type: stdin
+	`)))
+	require.NoError(err)
+	require.NoError(conf.Start(context.Background()))
+
+	_, err = w.WriteString("foo\nbar\n")
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	// output loop checks events every 5 seconds
+	messages := []string{}
+	for i := 0; i < 2; i++ {
+		if event, err := conf.TestGetOutputEvent(10 * time.Second); assert.NoError(err) {
+			messages = append(messages, event.Message)
+		}
+	}
+	assert.Equal([]string{"foo", "bar"}, messages)
+
+	// pipeline drains and stops after the end of stdin
+	done := make(chan error, 1)
+	go func() {
+		done <- conf.Wait()
+	}()
+	select {
+	case err = <-done:
+		assert.NoError(err)
+	case <-time.After(15 * time.Second):
+		require.FailNow("pipeline not stopped at end of stream")
+	}
+}
+
+func Test_input_stdin_module_output_file(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "gogstash-stdin")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.log")
+
+	r, w, err := os.Pipe()
+	require.NoError(err)
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() {
+		os.Stdin = stdin
+	}()
+
+	conf, err := config.LoadFromYAML([]byte(strings.TrimSpace(`
+input:
+  - type: stdin
+output:
+  - type: file
+    path: "` + path + `"
+    codec: "%{message}"
+    flush_interval: 60
+	`)))
+	require.NoError(err)
+	require.NoError(conf.Start(context.Background()))
+
+	// more lines than output file buffers, all written when the pipeline stops
+	expected := &strings.Builder{}
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(expected, "line %d\n", i)
+	}
+	_, err = w.WriteString(expected.String())
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- conf.Wait()
+	}()
+	select {
+	case err = <-done:
+		assert.NoError(err)
+	case <-time.After(30 * time.Second):
+		require.FailNow("pipeline not stopped at end of stream")
+	}
+
+	written, err := ioutil.ReadFile(path)
+	require.NoError(err)
+	assert.Equal(expected.String(), string(written))
+}
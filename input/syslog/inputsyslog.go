@@ -0,0 +1,154 @@
+package inputsyslog
+
+import (
+	"context"
+	"time"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/logevent"
+	inputsocket "github.com/viethqc/gogstash/input/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "syslog"
+
+// ErrorTag tag added to event when process module failed
+const ErrorTag = "gogstash_input_syslog_error"
+
+// DefaultMaxMessageSize is the default maximum size of a syslog message in bytes
+const DefaultMaxMessageSize = 65536
+
+// InputConfig holds the configuration json fields and internal objects
+type InputConfig struct {
+	config.InputConfig
+
+	// Listen addresses in the form `host:port`, empty to disable the listener.
+	UDP string `json:"udp"`
+	TCP string `json:"tcp"`
+	TLS string `json:"tls"`
+
+	// TLS options, see socket input.
+	SSLCertificate            string   `json:"ssl_certificate"`
+	SSLKey                    string   `json:"ssl_key"`
+	SSLCertificateAuthorities []string `json:"ssl_certificate_authorities"`
+	SSLVerifyMode             string   `json:"ssl_verify_mode"`
+
+	// Stream connection options, see socket input.
+	MaxConnections int `json:"max_connections"`
+	IdleTimeout    int `json:"idle_timeout"`
+
+	// Maximum size of a message in bytes.
+	MaxMessageSize int `json:"max_message_size"`
+	// Time zone of RFC 3164 timestamps, defaults to local time zone.
+	Timezone string `json:"timezone"`
+
+	codec     *syslogCodec
+	listeners []config.TypeInputConfig
+}
+
+// DefaultInputConfig returns an InputConfig struct with default values
+func DefaultInputConfig() InputConfig {
+	return InputConfig{
+		InputConfig: config.InputConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		UDP:            "0.0.0.0:514",
+		TCP:            "0.0.0.0:514",
+		SSLVerifyMode:  inputsocket.VerifyModeNone,
+		MaxMessageSize: DefaultMaxMessageSize,
+	}
+}
+
+// errors
+var (
+	ErrorNoListener       = errutil.NewFactory("at least one of udp, tcp and tls must be set")
+	ErrorMissingSSLConfig = errutil.NewFactory("ssl_certificate and ssl_key are required by tls")
+)
+
+// InitHandler initialize the input plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeInputConfig, error) {
+	conf := DefaultInputConfig()
+	err := config.ReflectConfig(raw, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	location := time.Local
+	if conf.Timezone != "" {
+		if location, err = time.LoadLocation(conf.Timezone); err != nil {
+			return nil, err
+		}
+	}
+	conf.codec = newSyslogCodec(location)
+
+	listenerRaws := []config.ConfigRaw{}
+	if conf.UDP != "" {
+		listenerRaws = append(listenerRaws, config.ConfigRaw{
+			"socket":  "udp",
+			"address": conf.UDP,
+			// RFC 5426, one message per datagram
+			"framing":     inputsocket.FramingNone,
+			"buffer_size": conf.MaxMessageSize,
+		})
+	}
+	if conf.TCP != "" {
+		listenerRaws = append(listenerRaws, conf.streamListenerRaw(conf.TCP))
+	}
+	if conf.TLS != "" {
+		if conf.SSLCertificate == "" || conf.SSLKey == "" {
+			return nil, ErrorMissingSSLConfig.New(nil)
+		}
+		tlsRaw := conf.streamListenerRaw(conf.TLS)
+		tlsRaw["ssl_certificate"] = conf.SSLCertificate
+		tlsRaw["ssl_key"] = conf.SSLKey
+		tlsRaw["ssl_certificate_authorities"] = conf.SSLCertificateAuthorities
+		tlsRaw["ssl_verify_mode"] = conf.SSLVerifyMode
+		listenerRaws = append(listenerRaws, tlsRaw)
+	}
+	if len(listenerRaws) < 1 {
+		return nil, ErrorNoListener.New(nil)
+	}
+
+	for _, listenerRaw := range listenerRaws {
+		// no codec, messages are parsed by syslogCodec
+		listenerRaw["codec"] = nil
+		listener, err := inputsocket.InitHandler(ctx, &listenerRaw)
+		if err != nil {
+			return nil, err
+		}
+		listener.(*inputsocket.InputConfig).Codec = conf.codec
+		conf.listeners = append(conf.listeners, listener)
+	}
+
+	return &conf, nil
+}
+
+// streamListenerRaw returns socket input config of TCP or TLS listener,
+// RFC 6587 octet counting and non-transparent framing are detected by each message
+func (t *InputConfig) streamListenerRaw(address string) config.ConfigRaw {
+	return config.ConfigRaw{
+		"socket":          "tcp",
+		"address":         address,
+		"framing":         inputsocket.FramingOctetCounting,
+		"max_frame_size":  t.MaxMessageSize,
+		"max_connections": t.MaxConnections,
+		"idle_timeout":    t.IdleTimeout,
+	}
+}
+
+// Start wraps the actual function starting the plugin
+func (t *InputConfig) Start(ctx context.Context, msgChan chan<- logevent.LogEvent) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	for _, listener := range t.listeners {
+		func(listener config.TypeInputConfig) {
+			eg.Go(func() error {
+				return listener.Start(ctx, msgChan)
+			})
+		}(listener)
+	}
+	return eg.Wait()
+}
@@ -0,0 +1,237 @@
+package inputsyslog
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+	inputsocket "github.com/viethqc/gogstash/input/socket"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+	config.RegistInputHandler(ModuleName, InitHandler)
+}
+
+func Test_input_syslog_parse(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testCases := []struct {
+		line      string
+		timestamp time.Time
+		message   string
+		extra     map[string]interface{}
+		err       bool
+	}{
+		{
+			line:      "<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8\n",
+			timestamp: time.Date(2020, 10, 11, 22, 14, 15, 0, time.UTC),
+			message:   "'su root' failed for lonvick on /dev/pts/8",
+			extra: map[string]interface{}{
+				"priority": 34, "facility": 4, "severity": 2,
+				"facility_label": "security/authorization", "severity_label": "Critical",
+				"syslog_format": FormatRFC3164, "hostname": "mymachine", "program": "su",
+			},
+		},
+		{
+			line:      "<13>Jan  2 03:00:00 sshd[1234]: Accepted publickey",
+			timestamp: time.Date(2021, 1, 2, 3, 0, 0, 0, time.UTC),
+			message:   "Accepted publickey",
+			extra: map[string]interface{}{
+				"priority": 13, "facility": 1, "severity": 5,
+				"facility_label": "user-level", "severity_label": "Notice",
+				"syslog_format": FormatRFC3164, "program": "sshd", "pid": "1234",
+			},
+		},
+		{
+			line:      "<191>2021-01-02T01:02:03.5+02:00 host1 app: hello world",
+			timestamp: time.Date(2021, 1, 1, 23, 2, 3, 500000000, time.UTC),
+			message:   "hello world",
+			extra: map[string]interface{}{
+				"priority": 191, "facility": 23, "severity": 7,
+				"facility_label": "local7", "severity_label": "Debug",
+				"syslog_format": FormatRFC3164, "hostname": "host1", "program": "app",
+			},
+		},
+		{
+			line:      "<0>no header",
+			timestamp: now,
+			message:   "no header",
+			extra: map[string]interface{}{
+				"priority": 0, "facility": 0, "severity": 0,
+				"facility_label": "kernel", "severity_label": "Emergency",
+				"syslog_format": FormatRFC3164,
+			},
+		},
+		{
+			line:      "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut=\"3\" eventSource=\"App\\\"lication\\]\"][examplePriority@32473 class=\"high\"] \xef\xbb\xbfAn application event",
+			timestamp: time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+			message:   "An application event",
+			extra: map[string]interface{}{
+				"priority": 165, "facility": 20, "severity": 5,
+				"facility_label": "local4", "severity_label": "Notice",
+				"syslog_format": FormatRFC5424, "hostname": "mymachine.example.com", "program": "evntslog", "msgid": "ID47",
+				"structured_data": map[string]interface{}{
+					"exampleSDID@32473":     map[string]interface{}{"iut": "3", "eventSource": "App\"lication]"},
+					"examplePriority@32473": map[string]interface{}{"class": "high"},
+				},
+			},
+		},
+		{
+			line:      "<14>1 - - - 42 - -",
+			timestamp: now,
+			message:   "",
+			extra: map[string]interface{}{
+				"priority": 14, "facility": 1, "severity": 6,
+				"facility_label": "user-level", "severity_label": "Informational",
+				"syslog_format": FormatRFC5424, "pid": "42",
+			},
+		},
+		{line: "no priority", timestamp: now, message: "no priority", extra: map[string]interface{}{}, err: true},
+		{line: "<192>1 - - - - - -", timestamp: now, message: "<192>1 - - - - - -", extra: map[string]interface{}{}, err: true},
+		{line: "<14>1 - host", timestamp: now, message: "<14>1 - host", extra: map[string]interface{}{}, err: true},
+		{line: "<14>1 yesterday - - - - -", timestamp: now, message: "<14>1 yesterday - - - - -", extra: map[string]interface{}{}, err: true},
+		{line: "<14>1 - - - - - [id a=\"b] msg", timestamp: now, message: "<14>1 - - - - - [id a=\"b] msg", extra: map[string]interface{}{}, err: true},
+	}
+
+	for _, testCase := range testCases {
+		event, err := parseSyslog(testCase.line, time.UTC, now)
+		if testCase.err {
+			assert.Error(err, testCase.line)
+		} else {
+			assert.NoError(err, testCase.line)
+		}
+		assert.True(testCase.timestamp.Equal(event.Timestamp), "%s: %v", testCase.line, event.Timestamp)
+		assert.Equal(testCase.message, event.Message, testCase.line)
+		assert.Equal(testCase.extra, event.Extra, testCase.line)
+	}
+}
+
+func Test_input_syslog_module(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conf, err := config.LoadFromYAML([]byte(strings.TrimSpace(`
+input:
+  - type: syslog
+    udp: "127.0.0.1:9514"
+    tcp: "127.0.0.1:9514"
+	`)))
+	require.NoError(err)
+	input, err := InitHandler(ctx, &conf.InputRaw[0])
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	go input.Start(ctx, msgChan)
+	time.Sleep(200 * time.Millisecond)
+
+	receive := func() logevent.LogEvent {
+		select {
+		case event := <-msgChan:
+			return event
+		case <-time.After(2 * time.Second):
+			require.FailNow("timeout waiting for event")
+		}
+		return logevent.LogEvent{}
+	}
+
+	udpConn, err := net.Dial("udp", "127.0.0.1:9514")
+	require.NoError(err)
+	defer udpConn.Close()
+	_, err = udpConn.Write([]byte("<13>1 2003-10-11T22:14:15.003Z host app - - - over udp\n"))
+	require.NoError(err)
+	event := receive()
+	assert.Equal("over udp", event.Message)
+	assert.Equal("app", event.Extra["program"])
+	assert.Equal(udpConn.LocalAddr().String(), event.Extra[inputsocket.RemoteAddrField])
+
+	tcpConn, err := net.Dial("tcp", "127.0.0.1:9514")
+	require.NoError(err)
+	defer tcpConn.Close()
+	_, err = tcpConn.Write([]byte("33 <13>Oct 11 22:14:15 host app: a\nb" +
+		"<13>Oct 11 22:14:15 host app: newline\n" +
+		"malformed\n"))
+	require.NoError(err)
+
+	event = receive()
+	assert.Equal("a\nb", event.Message)
+	assert.Equal("host", event.Extra["hostname"])
+	assert.Equal(tcpConn.LocalAddr().String(), event.Extra[inputsocket.RemoteAddrField])
+	event = receive()
+	assert.Equal("newline", event.Message)
+	event = receive()
+	assert.Equal("malformed", event.Message)
+	assert.Equal([]string{ErrorTag}, event.Tags)
+	assert.Equal(tcpConn.LocalAddr().String(), event.Extra[inputsocket.RemoteAddrField])
+
+	_, err = InitHandler(ctx, &config.ConfigRaw{"udp": "", "tcp": ""})
+	assert.Error(err)
+	_, err = InitHandler(ctx, &config.ConfigRaw{"udp": "", "tcp": "", "tls": "127.0.0.1:6514"})
+	assert.Error(err)
+}
+
+func Test_input_syslog_module_max_message_size(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	input, err := InitHandler(ctx, &config.ConfigRaw{
+		"tcp":              "127.0.0.1:9515",
+		"max_message_size": 64,
+	})
+	require.NoError(err)
+
+	msgChan := make(chan logevent.LogEvent, 10)
+	go input.Start(ctx, msgChan)
+	time.Sleep(200 * time.Millisecond)
+
+	// newline framed message larger than max_message_size closes the connection
+	tcpConn, err := net.Dial("tcp", "127.0.0.1:9515")
+	require.NoError(err)
+	defer tcpConn.Close()
+	_, err = tcpConn.Write([]byte("<13>Oct 11 22:14:15 host app: " + strings.Repeat("x", 8192) + "\n" +
+		"<13>Oct 11 22:14:15 host app: after\n"))
+	require.NoError(err)
+	tcpConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = tcpConn.Read(make([]byte, 1))
+	assert.Error(err)
+	assert.False(isTimeout(err), "connection not closed")
+	assert.Len(msgChan, 0)
+
+	tcpConn, err = net.Dial("tcp", "127.0.0.1:9515")
+	require.NoError(err)
+	defer tcpConn.Close()
+	_, err = tcpConn.Write([]byte("<13>Oct 11 22:14:15 host app: small\n"))
+	require.NoError(err)
+	select {
+	case event := <-msgChan:
+		assert.Equal("small", event.Message)
+	case <-time.After(2 * time.Second):
+		assert.Fail("timeout waiting for event")
+	}
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
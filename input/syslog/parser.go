@@ -0,0 +1,283 @@
+package inputsyslog
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// syslog formats, recorded in field "syslog_format"
+const (
+	FormatRFC3164 = "rfc3164"
+	FormatRFC5424 = "rfc5424"
+)
+
+// nil value of RFC 5424 header fields
+const nilValue = "-"
+
+// layout of RFC 3164 timestamp, e.g. "Oct  9 22:33:20"
+const rfc3164TimeLayout = "Jan _2 15:04:05"
+
+var facilityLabels = []string{
+	"kernel", "user-level", "mail", "daemon", "security/authorization", "syslogd", "line printer", "network news",
+	"UUCP", "clock", "security/authorization", "FTP", "NTP", "log audit", "log alert", "clock",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severityLabels = []string{
+	"Emergency", "Alert", "Critical", "Error", "Warning", "Notice", "Informational", "Debug",
+}
+
+// errors
+var (
+	ErrorInvalidPriority1  = errutil.NewFactory("invalid syslog priority: %q")
+	ErrorInvalidHeader1    = errutil.NewFactory("invalid RFC 5424 header: %q")
+	ErrorInvalidTimestamp1 = errutil.NewFactory("invalid RFC 5424 timestamp: %q")
+	ErrorInvalidSD1        = errutil.NewFactory("invalid RFC 5424 structured data: %q")
+)
+
+// syslogCodec parses syslog messages received by socket listeners
+type syslogCodec struct {
+	config.CodecConfig
+	location *time.Location
+}
+
+func newSyslogCodec(location *time.Location) *syslogCodec {
+	return &syslogCodec{
+		CodecConfig: config.CodecConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		location: location,
+	}
+}
+
+// Decode parses a syslog message, malformed messages are sent as is with ErrorTag
+func (c *syslogCodec) Decode(ctx context.Context, data interface{},
+	eventExtra map[string]interface{},
+	msgChan chan<- logevent.LogEvent) (ok bool, err error) {
+
+	var line string
+	switch v := data.(type) {
+	case string:
+		line = v
+	case []byte:
+		line = string(v)
+	default:
+		return false, config.ErrDecodeData
+	}
+
+	event, err := parseSyslog(line, c.location, time.Now())
+	if eventExtra != nil {
+		for k, v := range event.Extra {
+			eventExtra[k] = v
+		}
+		event.Extra = eventExtra
+	}
+	if err != nil {
+		event.AddTag(ErrorTag)
+	}
+
+	msgChan <- event
+	return true, nil
+}
+
+// DecodeEvent is not supported
+func (c *syslogCodec) DecodeEvent(data []byte, v interface{}) error {
+	return config.ErrorNotImplement1.New(nil, "DecodeEvent")
+}
+
+// Encode is not supported
+func (c *syslogCodec) Encode(ctx context.Context, event logevent.LogEvent, dataChan chan<- []byte) (ok bool, err error) {
+	return false, config.ErrorNotImplement1.New(nil, "Encode")
+}
+
+// parseSyslog parses RFC 5424 or RFC 3164 message, detected by the version after priority,
+// the event has the raw message and receive time on error
+func parseSyslog(line string, location *time.Location, now time.Time) (event logevent.LogEvent, err error) {
+	line = strings.TrimRight(line, "\r\n\x00")
+	event = logevent.LogEvent{
+		Timestamp: now,
+		Message:   line,
+		Extra:     map[string]interface{}{},
+	}
+
+	priority, rest, err := parsePriority(line)
+	if err != nil {
+		return event, err
+	}
+	facility, severity := priority/8, priority%8
+	extra := map[string]interface{}{
+		"priority":       priority,
+		"facility":       facility,
+		"severity":       severity,
+		"facility_label": facilityLabels[facility],
+		"severity_label": severityLabels[severity],
+	}
+
+	if strings.HasPrefix(rest, "1 ") {
+		err = parseRFC5424(rest[2:], &event, extra)
+	} else {
+		parseRFC3164(rest, location, now, &event, extra)
+	}
+	if err != nil {
+		event.Message = line
+		return event, err
+	}
+	event.Extra = extra
+	return event, nil
+}
+
+// parsePriority parses "<PRI>" of message
+func parsePriority(line string) (priority int, rest string, err error) {
+	end := strings.IndexByte(line, '>')
+	if !strings.HasPrefix(line, "<") || end < 2 || end > 4 {
+		return 0, line, ErrorInvalidPriority1.New(nil, line)
+	}
+	if priority, err = strconv.Atoi(line[1:end]); err != nil || priority < 0 || priority > 191 {
+		return 0, line, ErrorInvalidPriority1.New(err, line[:end+1])
+	}
+	return priority, line[end+1:], nil
+}
+
+// parseRFC5424 parses "TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]"
+func parseRFC5424(rest string, event *logevent.LogEvent, extra map[string]interface{}) error {
+	header := strings.SplitN(rest, " ", 6)
+	if len(header) < 6 {
+		return ErrorInvalidHeader1.New(nil, rest)
+	}
+	extra["syslog_format"] = FormatRFC5424
+
+	if header[0] != nilValue {
+		timestamp, err := time.Parse(time.RFC3339Nano, header[0])
+		if err != nil {
+			return ErrorInvalidTimestamp1.New(err, header[0])
+		}
+		event.Timestamp = timestamp
+	}
+	for i, field := range []string{"hostname", "program", "pid", "msgid"} {
+		if value := header[i+1]; value != nilValue {
+			extra[field] = value
+		}
+	}
+
+	sd, msg, err := parseStructuredData(header[5])
+	if err != nil {
+		return err
+	}
+	if len(sd) > 0 {
+		extra["structured_data"] = sd
+	}
+	event.Message = strings.TrimPrefix(msg, "\xef\xbb\xbf")
+	return nil
+}
+
+// parseStructuredData parses `[id key="value" ...]...` or "-", returns the remaining message
+func parseStructuredData(rest string) (sd map[string]interface{}, msg string, err error) {
+	if strings.HasPrefix(rest, nilValue) {
+		return nil, strings.TrimPrefix(rest[1:], " "), nil
+	}
+
+	sd = map[string]interface{}{}
+	for strings.HasPrefix(rest, "[") {
+		end := strings.IndexAny(rest, " ]")
+		if end < 2 {
+			return nil, "", ErrorInvalidSD1.New(nil, rest)
+		}
+		params := map[string]interface{}{}
+		sd[rest[1:end]] = params
+		rest = rest[end:]
+
+		for strings.HasPrefix(rest, " ") {
+			rest = rest[1:]
+			eq := strings.Index(rest, "=\"")
+			if eq < 1 {
+				return nil, "", ErrorInvalidSD1.New(nil, rest)
+			}
+			name := rest[:eq]
+			value := strings.Builder{}
+			i := eq + 2
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				// escaped '"', '\' and ']'
+				if rest[i] == '\\' && i+1 < len(rest) && strings.IndexByte("\"\\]", rest[i+1]) >= 0 {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			if i >= len(rest) {
+				return nil, "", ErrorInvalidSD1.New(nil, rest)
+			}
+			params[name] = value.String()
+			rest = rest[i+1:]
+		}
+		if !strings.HasPrefix(rest, "]") {
+			return nil, "", ErrorInvalidSD1.New(nil, rest)
+		}
+		rest = rest[1:]
+	}
+	if rest != "" && !strings.HasPrefix(rest, " ") {
+		return nil, "", ErrorInvalidSD1.New(nil, rest)
+	}
+	return sd, strings.TrimPrefix(rest, " "), nil
+}
+
+// parseRFC3164 parses "TIMESTAMP HOSTNAME TAG[PID]: MSG" leniently,
+// timestamp, hostname and tag may be missing
+func parseRFC3164(rest string, location *time.Location, now time.Time, event *logevent.LogEvent, extra map[string]interface{}) {
+	extra["syslog_format"] = FormatRFC3164
+
+	// hostname follows timestamp
+	hasTimestamp := false
+	if len(rest) >= len(rfc3164TimeLayout) {
+		if t, err := time.Parse(rfc3164TimeLayout, rest[:len(rfc3164TimeLayout)]); err == nil {
+			// no year in timestamp, messages sent in december may be received in january
+			current := now.In(location)
+			timestamp := time.Date(current.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, location)
+			if timestamp.After(current.AddDate(0, 0, 1)) {
+				timestamp = timestamp.AddDate(-1, 0, 0)
+			}
+			event.Timestamp = timestamp
+			hasTimestamp = true
+			rest = strings.TrimPrefix(rest[len(rfc3164TimeLayout):], " ")
+		}
+	}
+	if fields := strings.SplitN(rest, " ", 2); !hasTimestamp && len(fields) == 2 {
+		// RFC 3339 timestamp sent by rsyslog and syslog-ng
+		if timestamp, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+			event.Timestamp = timestamp
+			hasTimestamp = true
+			rest = fields[1]
+		}
+	}
+
+	// the first word may be the tag if hostname is omitted
+	if fields := strings.SplitN(rest, " ", 2); hasTimestamp && len(fields) == 2 && !isTag(fields[0]) {
+		extra["hostname"] = fields[0]
+		rest = fields[1]
+	}
+
+	if fields := strings.SplitN(rest, " ", 2); isTag(fields[0]) {
+		tag := strings.TrimSuffix(fields[0], ":")
+		if start := strings.IndexByte(tag, '['); start > 0 && strings.HasSuffix(tag, "]") {
+			extra["pid"] = tag[start+1 : len(tag)-1]
+			tag = tag[:start]
+		}
+		extra["program"] = tag
+		rest = ""
+		if len(fields) == 2 {
+			rest = fields[1]
+		}
+	}
+	event.Message = rest
+}
+
+// isTag reports whether word is "program:" or "program[pid]:"
+func isTag(word string) bool {
+	return (len(word) > 1 && strings.HasSuffix(word, ":")) || (strings.HasSuffix(word, "]") && strings.IndexByte(word, '[') > 0)
+}
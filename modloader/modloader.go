@@ -1,7 +1,12 @@
 package modloader
 
 import (
+	codeccef "github.com/viethqc/gogstash/codec/cef"
+	codeccsv "github.com/viethqc/gogstash/codec/csv"
+	codecgelf "github.com/viethqc/gogstash/codec/gelf"
 	codecjson "github.com/viethqc/gogstash/codec/json"
+	codecleef "github.com/viethqc/gogstash/codec/leef"
+	codeclogfmt "github.com/viethqc/gogstash/codec/logfmt"
 	"github.com/viethqc/gogstash/config"
 	filteraddfield "github.com/viethqc/gogstash/filter/addfield"
 	filtercond "github.com/viethqc/gogstash/filter/cond"
@@ -19,18 +24,27 @@ import (
 	inputbeats "github.com/viethqc/gogstash/input/beats"
 	inputdockerlog "github.com/viethqc/gogstash/input/dockerlog"
 	inputdockerstats "github.com/viethqc/gogstash/input/dockerstats"
+	inputelasticbulk "github.com/viethqc/gogstash/input/elasticbulk"
 	inputexec "github.com/viethqc/gogstash/input/exec"
 	inputfile "github.com/viethqc/gogstash/input/file"
+	inputgelf "github.com/viethqc/gogstash/input/gelf"
+	inputgenerator "github.com/viethqc/gogstash/input/generator"
 	inputhttp "github.com/viethqc/gogstash/input/http"
 	inputhttplisten "github.com/viethqc/gogstash/input/httplisten"
+	inputjournald "github.com/viethqc/gogstash/input/journald"
+	inputkubernetes "github.com/viethqc/gogstash/input/kubernetes"
 	inputlorem "github.com/viethqc/gogstash/input/lorem"
 	inputredis "github.com/viethqc/gogstash/input/redis"
 	inputsocket "github.com/viethqc/gogstash/input/socket"
+	inputsql "github.com/viethqc/gogstash/input/sql"
+	inputstdin "github.com/viethqc/gogstash/input/stdin"
+	inputsyslog "github.com/viethqc/gogstash/input/syslog"
 	outputamqp "github.com/viethqc/gogstash/output/amqp"
 	outputcond "github.com/viethqc/gogstash/output/cond"
 	outputelastic "github.com/viethqc/gogstash/output/elastic"
 	outputemail "github.com/viethqc/gogstash/output/email"
 	outputfile "github.com/viethqc/gogstash/output/file"
+	outputgelf "github.com/viethqc/gogstash/output/gelf"
 	outputhttp "github.com/viethqc/gogstash/output/http"
 	outputprometheus "github.com/viethqc/gogstash/output/prometheus"
 	outputredis "github.com/viethqc/gogstash/output/redis"
@@ -44,13 +58,21 @@ func init() {
 	config.RegistInputHandler(inputbeats.ModuleName, inputbeats.InitHandler)
 	config.RegistInputHandler(inputdockerlog.ModuleName, inputdockerlog.InitHandler)
 	config.RegistInputHandler(inputdockerstats.ModuleName, inputdockerstats.InitHandler)
+	config.RegistInputHandler(inputelasticbulk.ModuleName, inputelasticbulk.InitHandler)
 	config.RegistInputHandler(inputexec.ModuleName, inputexec.InitHandler)
 	config.RegistInputHandler(inputfile.ModuleName, inputfile.InitHandler)
+	config.RegistInputHandler(inputgelf.ModuleName, inputgelf.InitHandler)
+	config.RegistInputHandler(inputgenerator.ModuleName, inputgenerator.InitHandler)
 	config.RegistInputHandler(inputhttp.ModuleName, inputhttp.InitHandler)
 	config.RegistInputHandler(inputhttplisten.ModuleName, inputhttplisten.InitHandler)
+	config.RegistInputHandler(inputjournald.ModuleName, inputjournald.InitHandler)
+	config.RegistInputHandler(inputkubernetes.ModuleName, inputkubernetes.InitHandler)
 	config.RegistInputHandler(inputlorem.ModuleName, inputlorem.InitHandler)
 	config.RegistInputHandler(inputredis.ModuleName, inputredis.InitHandler)
 	config.RegistInputHandler(inputsocket.ModuleName, inputsocket.InitHandler)
+	config.RegistInputHandler(inputsql.ModuleName, inputsql.InitHandler)
+	config.RegistInputHandler(inputstdin.ModuleName, inputstdin.InitHandler)
+	config.RegistInputHandler(inputsyslog.ModuleName, inputsyslog.InitHandler)
 	config.RegistInputHandler(inputrabbitmq.ModuleName, inputrabbitmq.InitHandler)
 
 	config.RegistFilterHandler(filteraddfield.ModuleName, filteraddfield.InitHandler)
@@ -77,7 +99,14 @@ func init() {
 	config.RegistOutputHandler(outputreport.ModuleName, outputreport.InitHandler)
 	config.RegistOutputHandler(outputstdout.ModuleName, outputstdout.InitHandler)
 	config.RegistOutputHandler(outputfile.ModuleName, outputfile.InitHandler)
+	config.RegistOutputHandler(outputgelf.ModuleName, outputgelf.InitHandler)
 
 	config.RegistCodecHandler(config.DefaultCodecName, config.DefaultCodecInitHandler)
 	config.RegistCodecHandler(codecjson.ModuleName, codecjson.InitHandler)
+	config.RegistCodecHandler(codeccef.ModuleName, codeccef.InitHandler)
+	config.RegistCodecHandler(codecleef.ModuleName, codecleef.InitHandler)
+	config.RegistCodecHandler(codeccsv.ModuleName, codeccsv.InitHandler)
+	config.RegistCodecHandler(codeccsv.TSVModuleName, codeccsv.InitTSVHandler)
+	config.RegistCodecHandler(codeclogfmt.ModuleName, codeclogfmt.InitHandler)
+	config.RegistCodecHandler(codecgelf.ModuleName, codecgelf.InitHandler)
 }
@@ -264,8 +264,29 @@ func (t *OutputConfig) retry(data string, errorInfo *elastic.BulkResponseItem) e
 	return nil
 }
 
+// Flush commits buffered bulk requests to Elasticsearch
+func (t *OutputConfig) Flush(ctx context.Context) (err error) {
+	return t.processor.Flush()
+}
+
 // Output event
 func (t *OutputConfig) Output(ctx context.Context, event logevent.LogEvent) (err error) {
+	if request := t.bulkRequest(event); request != nil {
+		t.processor.Add(request)
+	}
+	return
+}
+
+// document is the source of bulk requests, the Extra fields of event encoded by jsoniter
+type document map[string]interface{}
+
+// MarshalJSON streams the document without encoding/json reflection
+func (d document) MarshalJSON() ([]byte, error) {
+	return jsoniter.Marshal(map[string]interface{}(d))
+}
+
+// bulkRequest returns the bulk request of event action, nil for unknown action
+func (t *OutputConfig) bulkRequest(event logevent.LogEvent) elastic.BulkableRequest {
 	index := event.Format(t.Index)
 	// elastic index name should be lowercase
 	index = strings.ToLower(index)
@@ -275,23 +296,17 @@ func (t *OutputConfig) Output(ctx context.Context, event logevent.LogEvent) (err
 
 	switch action {
 	case "index":
-		indexRequest := elastic.NewBulkIndexRequest().
+		return elastic.NewBulkIndexRequest().
 			Index(index).
 			Type(doctype).
 			Id(id).
-			Doc(event.Extra)
-		t.processor.Add(indexRequest)
-		break
+			Doc(document(event.Extra))
 	case "update":
-		updateRequest := elastic.NewBulkUpdateRequest().
+		return elastic.NewBulkUpdateRequest().
 			Index(index).
 			Type(doctype).
 			Id(id).
-			Doc(event.Extra)
-
-		t.processor.Add(updateRequest)
-		break
+			Doc(document(event.Extra))
 	}
-
-	return
+	return nil
 }
@@ -12,9 +12,11 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	codecjson "github.com/viethqc/gogstash/codec/json"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/goglog"
 	"github.com/viethqc/gogstash/config/logevent"
+	filtergrok "github.com/viethqc/gogstash/filter/grok"
 	"gopkg.in/olivere/elastic.v6"
 )
 
@@ -162,3 +164,81 @@ output:
 	_, err = client.DeleteIndex("gogstash-index-test").Do(ctx)
 	require.NoError(err)
 }
+
+func Test_output_elastic_bulk_request(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	output := DefaultOutputConfig()
+	output.Index = "Gogstash-%{+@2006.01.02}"
+	output.DocumentType = "%{type}"
+	output.DocumentID = "%{id}"
+	output.Action = "index"
+
+	event := logevent.LogEvent{
+		Timestamp: time.Date(2017, 4, 18, 19, 53, 1, 2, time.UTC),
+		Message:   "output elastic test message",
+		Tags:      []string{"tag1"},
+		Extra: map[string]interface{}{
+			"type": "access",
+			"id":   "abc",
+		},
+	}
+
+	source, err := output.bulkRequest(event).Source()
+	require.NoError(err)
+	require.Len(source, 2)
+	assert.JSONEq(`{"index":{"_index":"gogstash-2017.04.18","_type":"access","_id":"abc"}}`, source[0])
+	assert.JSONEq(`{"type":"access","id":"abc"}`, source[1])
+
+	output.Action = "update"
+	source, err = output.bulkRequest(event).Source()
+	require.NoError(err)
+	require.Len(source, 2)
+	assert.JSONEq(`{"update":{"_index":"gogstash-2017.04.18","_type":"access","_id":"abc"}}`, source[0])
+	assert.JSONEq(`{"doc":{"type":"access","id":"abc"}}`, source[1])
+
+	output.Action = "delete"
+	assert.Nil(output.bulkRequest(event))
+}
+
+// Benchmark_Pipeline_JSON_Grok_Elastic measures the hot path of an event decoded by
+// json codec, parsed by grok filter and encoded as elastic bulk index request
+func Benchmark_Pipeline_JSON_Grok_Elastic(b *testing.B) {
+	goglog.Logger.SetLevel(logrus.WarnLevel)
+	defer goglog.Logger.SetLevel(logrus.DebugLevel)
+
+	ctx := context.Background()
+	codec, err := codecjson.InitHandler(ctx, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	filter, err := filtergrok.InitHandler(ctx, &config.ConfigRaw{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	output := DefaultOutputConfig()
+	output.Index = "gogstash-%{+@2006.01.02}"
+	output.DocumentType = "%{type}"
+	output.Action = "index"
+
+	data := []byte(`{"@timestamp":"2017-04-18T19:53:01.000000002Z","type":"access","host":"web1",` +
+		`"message":"127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] \"GET /apache_pb.gif HTTP/1.0\" 200 2326"}`)
+	msgChan := make(chan logevent.LogEvent, 1)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err = codec.Decode(ctx, data, nil, msgChan); err != nil {
+			b.Fatal(err)
+		}
+		event := filter.Event(ctx, <-msgChan)
+		if _, err = output.bulkRequest(event).Source(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "events/s")
+}
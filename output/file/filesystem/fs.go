@@ -22,6 +22,7 @@ type File interface {
 	// io.ReaderAt
 	// io.Seeker
 	Sync() error
+	Close() error
 	Stat() (os.FileInfo, error)
 	Write(b []byte) (n int, err error)
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/viethqc/gogstash/KDGoLib/errutil"
@@ -28,6 +29,7 @@ const (
 	defaultFlushInterval   = 2
 	defaultCodec           = "%{log}"
 	defaultCreateIfDeleted = true
+	encodeChanSize         = 10
 )
 
 // errors
@@ -39,6 +41,12 @@ var (
 	ErrorCreatingDir          = errutil.NewFactory("error creating directory: %s")
 )
 
+// fileWriter writes messages to a file in its own goroutine, done is closed when it stops
+type fileWriter struct {
+	messages chan string
+	done     chan struct{}
+}
+
 // osFS implements FileSystem using the local disk. this is the default implementation to use, except during when we mock
 type osFS struct{}
 
@@ -59,9 +67,12 @@ type OutputConfig struct {
 	FileMode        string `json:"file_mode"`         // File access mode to use. Example: "file_mode" => 0640
 	FlushInterval   int    `json:"flush_interval"`    // Flush interval (in seconds) for flushing writes to log files. 0 will flush on every message.
 	Path            string `json:"path"`              // The path to the file to write. Event fields can be used here, like /var/log/logstash/%{host}/%{application}
-	Codec           string `json:"codec"`             // expression to write to file. E.g. "%{log}"
+	Codec           string `json:"codec"`             // expression to write to file. E.g. "%{log}", or a codec config, e.g. {"type": "csv"}
 	WriteBehavior   string `json:"write_behavior"`    // If append, the file will be opened for appending and each new event will be written at the end of the file. If overwrite, the file will be truncated before writing and only the most recent event will appear in the file.
-	writers         map[string]chan string
+	writers         map[string]*fileWriter
+	writersMutex    sync.Mutex
+	encoder         config.TypeCodecConfig
+	header          []byte // written at the beginning of each file created, e.g. column names of csv
 	fileMode        os.FileMode
 	dirMode         os.FileMode
 	fs              fs.FileSystem
@@ -81,7 +92,7 @@ func DefaultOutputConfig() OutputConfig {
 		FlushInterval:   defaultFlushInterval,
 		WriteBehavior:   defaultWriteBehavior,
 		Codec:           defaultCodec,
-		writers:         make(map[string]chan string),
+		writers:         make(map[string]*fileWriter),
 		fs:              osFS{},
 	}
 }
@@ -89,7 +100,26 @@ func DefaultOutputConfig() OutputConfig {
 // InitHandler initialize the output plugin
 func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeOutputConfig, error) {
 	conf := DefaultOutputConfig()
-	err := config.ReflectConfig(raw, &conf)
+	var err error
+	if _, isExpression := (*raw)["codec"].(string); !isExpression && (*raw)["codec"] != nil {
+		// encode events with codec module instead of expression
+		if conf.encoder, err = config.GetCodec(ctx, *raw); err != nil {
+			return nil, err
+		}
+		if headerCodec, ok := conf.encoder.(config.TypeHeaderCodecConfig); ok {
+			if conf.header, err = headerCodec.EncodeHeader(); err != nil {
+				return nil, err
+			}
+		}
+		outputRaw := config.ConfigRaw{}
+		for key, value := range *raw {
+			if key != "codec" {
+				outputRaw[key] = value
+			}
+		}
+		raw = &outputRaw
+	}
+	err = config.ReflectConfig(raw, &conf)
 	if err != nil {
 		return nil, err
 	}
@@ -137,72 +167,137 @@ func (t *OutputConfig) createFile(path string) (fs.File, error) {
 	default:
 		return nil, ErrorInvalidWriteBehavior.New(nil, t.WriteBehavior)
 	}
-	return t.fs.OpenFile(path, flags, t.fileMode)
+	file, err := t.fs.OpenFile(path, flags, t.fileMode)
+	if err != nil {
+		return nil, err
+	}
+	// files appended to already have the header
+	if len(t.header) > 0 && (!fileExists || t.WriteBehavior == overwriteBehavior) {
+		if _, err = file.Write([]byte(fmt.Sprintf("%s\n", t.header))); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return file, nil
 }
 
 // Output event
 func (t *OutputConfig) Output(ctx context.Context, event logevent.LogEvent) (err error) {
 	path := event.Format(t.Path)
 
-	channel, alreadyWriting := t.writers[path]
+	t.writersMutex.Lock()
+	writer, alreadyWriting := t.writers[path]
 	if !alreadyWriting {
-		channel = make(chan string, 100)
-		t.writers[path] = channel
-		go func() {
-			goglog.Logger.Debugf("Starting goroutine for file %s\n", path)
-			var tick <-chan time.Time
-			if t.FlushInterval > 0 {
-				tick = time.Tick(time.Duration(t.FlushInterval) * time.Second)
-			}
-			file, err := t.createFile(path)
-			if err != nil {
-				goglog.Logger.Errorf("problems opening %s %v.\n", path, err)
+		writer = &fileWriter{
+			messages: make(chan string, 100),
+			done:     make(chan struct{}),
+		}
+		t.writers[path] = writer
+		go t.writeLoop(path, writer)
+	}
+	t.writersMutex.Unlock()
+	channel := writer.messages
+
+	if t.encoder == nil {
+		log := event.Format(t.Codec)
+		channel <- log
+		return
+	}
+
+	// the encoder may send more data than the channel buffers, relay it while encoding
+	dataChan := make(chan []byte, encodeChanSize)
+	go func() {
+		defer close(dataChan)
+		_, err = t.encoder.Encode(ctx, event, dataChan)
+	}()
+	for data := range dataChan {
+		channel <- string(data)
+	}
+	return
+}
+
+// writeLoop writes messages of writer to path until the messages channel is closed
+func (t *OutputConfig) writeLoop(path string, writer *fileWriter) {
+	defer close(writer.done)
+	goglog.Logger.Debugf("Starting goroutine for file %s\n", path)
+	var tick <-chan time.Time
+	if t.FlushInterval > 0 {
+		ticker := time.NewTicker(time.Duration(t.FlushInterval) * time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	file, err := t.createFile(path)
+	if err != nil {
+		goglog.Logger.Errorf("problems opening %s %v.\n", path, err)
+		return
+	}
+	for {
+		fileChanged := false
+		select {
+		case msg, ok := <-writer.messages:
+			if !ok {
+				// flushed, all messages written
+				syncFile(file)
+				if err := file.Close(); err != nil {
+					goglog.Logger.Errorf("problems closing %s %v.\n", path, err)
+				}
 				return
 			}
-			for {
-				fileChanged := false
-				select {
-				case msg := <-channel:
-					msgToWrite := []byte(fmt.Sprintf("%s\n", msg))
-					written, err := file.Write(msgToWrite)
-					if os.IsNotExist(err) && t.CreateIfDeleted {
-						// re-create file if it was deleted and configured as such
-						file, err = t.createFile(path)
-						if err != nil {
-							goglog.Logger.Errorf("problems re-creating file. Routine will not write anything else to file %s. %v.\n", path, err)
-							return
-						}
-						written, err = file.Write(msgToWrite)
-						if err != nil {
-							goglog.Logger.Errorf("problems writting after re-creating file. Routine will not write anything else to file %s. %v.\n", path, err)
-							return
-						}
-					}
-					if written > 0 {
-						fileChanged = true
-					}
-					if err != nil {
-						goglog.Logger.Errorf("problems writing to %s %v. Written: %d\n", path, err, written)
-						continue
-					}
-					goglog.Logger.Debugf("wrote %d bytes of %s to %s.\n", written, msg, path)
-					if t.FlushInterval == 0 {
-						sync(file)
-					}
-				case <-tick:
-					if !fileChanged {
-						continue
-					}
-					goglog.Logger.Infof("sync'ing %s\n", path)
-					sync(file)
+			msgToWrite := []byte(fmt.Sprintf("%s\n", msg))
+			written, err := file.Write(msgToWrite)
+			if os.IsNotExist(err) && t.CreateIfDeleted {
+				// re-create file if it was deleted and configured as such
+				file, err = t.createFile(path)
+				if err != nil {
+					goglog.Logger.Errorf("problems re-creating file. Routine will not write anything else to file %s. %v.\n", path, err)
+					return
+				}
+				written, err = file.Write(msgToWrite)
+				if err != nil {
+					goglog.Logger.Errorf("problems writting after re-creating file. Routine will not write anything else to file %s. %v.\n", path, err)
+					return
 				}
 			}
-		}()
+			if written > 0 {
+				fileChanged = true
+			}
+			if err != nil {
+				goglog.Logger.Errorf("problems writing to %s %v. Written: %d\n", path, err, written)
+				continue
+			}
+			goglog.Logger.Debugf("wrote %d bytes of %s to %s.\n", written, msg, path)
+			if t.FlushInterval == 0 {
+				syncFile(file)
+			}
+		case <-tick:
+			if !fileChanged {
+				continue
+			}
+			goglog.Logger.Infof("sync'ing %s\n", path)
+			syncFile(file)
+		}
 	}
+}
 
-	log := event.Format(t.Codec)
-	channel <- log
-	return
+// Flush writes messages buffered of all files, then syncs and closes the files,
+// files are opened again by events output after
+func (t *OutputConfig) Flush(ctx context.Context) (err error) {
+	t.writersMutex.Lock()
+	writers := t.writers
+	t.writers = make(map[string]*fileWriter)
+	t.writersMutex.Unlock()
+
+	for _, writer := range writers {
+		close(writer.messages)
+	}
+	for _, writer := range writers {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-writer.done:
+		}
+	}
+	return nil
 }
 
 func (t *OutputConfig) IsRunning() (bool, error) {
@@ -216,7 +311,7 @@ func (t *OutputConfig) exists(filepath string) bool {
 	return true
 }
 
-func sync(f fs.File) {
+func syncFile(f fs.File) {
 	err := f.Sync()
 	if err != nil {
 		goglog.Logger.Errorf("problems sync'ing %s: %v\n", f, err)
@@ -2,7 +2,9 @@ package outputfile
 
 import (
 	"context"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -10,12 +12,17 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	codeccsv "github.com/viethqc/gogstash/codec/csv"
 	"github.com/viethqc/gogstash/config"
 	"github.com/viethqc/gogstash/config/logevent"
 	fs "github.com/viethqc/gogstash/output/file/filesystem"
 	mocks "github.com/viethqc/gogstash/output/file/mocks"
 )
 
+func init() {
+	config.RegistCodecHandler(codeccsv.ModuleName, codeccsv.InitHandler)
+}
+
 func TestInvalidDefaultOutputConfig(t *testing.T) {
 	assert := assert.New(t)
 
@@ -301,6 +308,63 @@ output:
 
 }
 
+func TestDefaultOutputConfigCodecModule(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	path := "p"
+	fileMode := 777
+	conf, err := config.LoadFromYAML([]byte(strings.TrimSpace(`
+debugch: true
+output:
+  - type: file
+    path: ` + path + `
+    flush_interval: 1000
+    file_mode: "` + strconv.Itoa(fileMode) + `"
+    codec:
+      type: csv
+      columns: ["log", "count"]
+      include_headers: true
+	`)))
+	assert.Nil(err)
+	c, err := InitHandler(context.TODO(), &conf.OutputRaw[0])
+	assert.Nil(err)
+	config := c.(*OutputConfig)
+	assert.Equal(defaultCodec, config.Codec)
+	perm := os.FileMode(fileMode)
+	mockfs := mocks.NewMockFileSystem(ctrl)
+	config.fs = mockfs
+	event := logevent.LogEvent{}
+	event.SetValue("log", "log, value")
+	event.SetValue("count", 3)
+	// filesystem will reply with 'file does not exist'
+	mockfs.EXPECT().Stat(path).Return(nil, os.ErrNotExist)
+	mockfile := mocks.NewMockFile(ctrl)
+	// channel to prevent test from finishing before gorouting writes to file
+	done := make(chan bool)
+	// header row is written before the first event
+	gomock.InOrder(
+		mockfile.EXPECT().Write([]byte("log,count\n")).Return(10, nil),
+		mockfile.EXPECT().Write([]byte("\"log, value\",3\n")).DoAndReturn(func(b []byte) (int, error) {
+			done <- true
+			return 10, nil
+		}),
+	)
+
+	mockfs.EXPECT().OpenFile(path, createPerm, perm).DoAndReturn(func(path string, flag int, perm os.FileMode) (fs.File, error) {
+		return mockfile, nil
+	})
+	err = config.Output(context.TODO(), event)
+	assert.Nil(err)
+
+	// wait for done channel or 2 seconds delay, whatever happends first
+	select {
+	case <-done:
+	case <-time.Tick(2 * time.Second):
+	}
+
+}
+
 func TestDefaultOutputConfigCreateIfNeeded(t *testing.T) {
 	assert := assert.New(t)
 	ctrl := gomock.NewController(t)
@@ -660,3 +724,152 @@ output:
 	}
 
 }
+
+func TestDefaultOutputConfigFlush(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	path := "p"
+	conf, err := config.LoadFromYAML([]byte(strings.TrimSpace(`
+debugch: true
+output:
+  - type: file
+    path: ` + path + `
+    flush_interval: 1000
+	`)))
+	assert.Nil(err)
+	c, err := InitHandler(context.TODO(), &conf.OutputRaw[0])
+	assert.Nil(err)
+	config := c.(*OutputConfig)
+	perm := os.FileMode(640)
+	mockfs := mocks.NewMockFileSystem(ctrl)
+	config.fs = mockfs
+	// filesystem will reply with 'file exists'
+	mockfs.EXPECT().Stat(path).Return("", nil)
+	mockfile := mocks.NewMockFile(ctrl)
+	mockfs.EXPECT().OpenFile(path, appendPerm, perm).DoAndReturn(func(path string, flag int, perm os.FileMode) (fs.File, error) {
+		return mockfile, nil
+	})
+	// more events than the writer channel buffers, all written before the file is sync'd and closed
+	written := 0
+	gomock.InOrder(
+		mockfile.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			written++
+			return len(b), nil
+		}).Times(150),
+		mockfile.EXPECT().Sync().Return(nil),
+		mockfile.EXPECT().Close().Return(nil),
+	)
+
+	event := logevent.LogEvent{}
+	for i := 0; i < 150; i++ {
+		assert.Nil(config.Output(context.TODO(), event))
+	}
+	assert.Nil(config.Flush(context.TODO()))
+	assert.Equal(150, written)
+	assert.Len(config.writers, 0)
+}
+
+func TestDefaultOutputConfigCodecModuleHeaderPerFile(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "gogstash-output-file")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	existing := filepath.Join(dir, "c.csv")
+	assert.Nil(ioutil.WriteFile(existing, []byte("log,app\nold,c\n"), 0640))
+
+	conf, err := config.LoadFromYAML([]byte(strings.TrimSpace(`
+debugch: true
+output:
+  - type: file
+    path: "` + filepath.Join(dir, "%{app}.csv") + `"
+    codec:
+      type: csv
+      columns: ["log", "app"]
+      include_headers: true
+	`)))
+	assert.Nil(err)
+	c, err := InitHandler(context.TODO(), &conf.OutputRaw[0])
+	assert.Nil(err)
+	config := c.(*OutputConfig)
+
+	for _, app := range []string{"a", "b", "a", "c"} {
+		event := logevent.LogEvent{}
+		event.SetValue("log", "new")
+		event.SetValue("app", app)
+		assert.Nil(config.Output(context.TODO(), event))
+	}
+	assert.Nil(config.Flush(context.TODO()))
+
+	// each file created gets the header, files appended to keep theirs
+	for file, expected := range map[string]string{
+		"a.csv": "log,app\nnew,a\nnew,a\n",
+		"b.csv": "log,app\nnew,b\n",
+		"c.csv": "log,app\nold,c\nnew,c\n",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file))
+		assert.Nil(err)
+		assert.Equal(expected, string(data), file)
+	}
+}
+
+// linesCodec encodes each event as the given number of lines
+type linesCodec struct {
+	config.TypeCodecConfig
+	lines int
+}
+
+func (c *linesCodec) Encode(ctx context.Context, event logevent.LogEvent, dataChan chan<- []byte) (ok bool, err error) {
+	for i := 0; i < c.lines; i++ {
+		dataChan <- []byte("line" + strconv.Itoa(i))
+	}
+	return true, nil
+}
+
+func TestDefaultOutputConfigCodecModuleManyLines(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	path := "p"
+	c, err := InitHandler(context.TODO(), &config.ConfigRaw{"path": path})
+	assert.Nil(err)
+	config := c.(*OutputConfig)
+	// more lines than the encoding channel buffers
+	lines := 3 * encodeChanSize
+	config.encoder = &linesCodec{lines: lines}
+	mockfs := mocks.NewMockFileSystem(ctrl)
+	config.fs = mockfs
+	mockfs.EXPECT().Stat(path).Return(nil, os.ErrNotExist)
+	mockfile := mocks.NewMockFile(ctrl)
+	mockfile.EXPECT().Sync().Return(nil).AnyTimes()
+	mockfs.EXPECT().OpenFile(path, createPerm, gomock.Any()).Return(mockfile, nil)
+	// channel to prevent test from finishing before gorouting writes to file
+	done := make(chan bool)
+	written := []string{}
+	mockfile.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+		written = append(written, string(b))
+		if len(written) == lines {
+			done <- true
+		}
+		return len(b), nil
+	}).Times(lines)
+
+	outputErr := make(chan error, 1)
+	go func() {
+		outputErr <- config.Output(context.TODO(), logevent.LogEvent{})
+	}()
+	select {
+	case err = <-outputErr:
+		assert.Nil(err)
+	case <-time.After(2 * time.Second):
+		assert.Fail("output blocked by encoder")
+		return
+	}
+	select {
+	case <-done:
+		assert.Equal("line0\n", written[0])
+		assert.Equal("line"+strconv.Itoa(lines-1)+"\n", written[lines-1])
+	case <-time.After(2 * time.Second):
+		assert.Fail("lines not written")
+	}
+}
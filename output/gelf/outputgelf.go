@@ -0,0 +1,175 @@
+package outputgelf
+
+import (
+	"compress/flate"
+	"context"
+	"crypto/rand"
+	"net"
+	"sync"
+
+	"github.com/viethqc/gogstash/KDGoLib/errutil"
+	codecgelf "github.com/viethqc/gogstash/codec/gelf"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+// ModuleName is the name used in config file
+const ModuleName = "gelf"
+
+// ErrorTag tag added to event when process module failed
+const ErrorTag = "gogstash_output_gelf_error"
+
+// OutputConfig holds the configuration json fields and internal objects
+type OutputConfig struct {
+	config.OutputConfig
+	Socket           string `json:"socket"`            // Type of socket, must be one of ["udp", "tcp"], default: "udp"
+	Address          string `json:"address"`           // Graylog address in form `host:port`, default: "localhost:12201"
+	Compression      string `json:"compression"`       // UDP payload compression, one of ["gzip", "zlib", "none"], default: "gzip"
+	CompressionLevel int    `json:"compression_level"` // Compression level from 1 to 9, default: -1 (default level)
+	ChunkSize        int    `json:"chunk_size"`        // Maximum UDP datagram size, larger messages are chunked, default: 1420
+
+	encoder config.TypeCodecConfig
+	mutex   sync.Mutex
+	conn    net.Conn
+}
+
+// DefaultOutputConfig returns an OutputConfig struct with default values
+func DefaultOutputConfig() OutputConfig {
+	return OutputConfig{
+		OutputConfig: config.OutputConfig{
+			CommonConfig: config.CommonConfig{
+				Type: ModuleName,
+			},
+		},
+		Socket:           "udp",
+		Address:          "localhost:12201",
+		Compression:      codecgelf.CompressionGzip,
+		CompressionLevel: flate.DefaultCompression,
+		ChunkSize:        1420,
+	}
+}
+
+// errors
+var (
+	ErrorUnknownSocketType1 = errutil.NewFactory("%q is not a valid socket type")
+	ErrorInvalidChunkSize1  = errutil.NewFactory("chunk_size %d is too small")
+	ErrorTooManyChunks2     = errutil.NewFactory("message of %d bytes exceeds %d chunks")
+)
+
+// InitHandler initialize the output plugin
+func InitHandler(ctx context.Context, raw *config.ConfigRaw) (config.TypeOutputConfig, error) {
+	conf := DefaultOutputConfig()
+	err := config.ReflectConfig(raw, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	switch conf.Socket {
+	case "udp", "tcp":
+	default:
+		return nil, ErrorUnknownSocketType1.New(nil, conf.Socket)
+	}
+	if conf.ChunkSize <= codecgelf.ChunkHeaderSize {
+		return nil, ErrorInvalidChunkSize1.New(nil, conf.ChunkSize)
+	}
+	// validate compression type and level
+	if _, err = codecgelf.Compress(nil, conf.Compression, conf.CompressionLevel); err != nil {
+		return nil, err
+	}
+
+	conf.encoder, err = config.GetCodecDefault(ctx, *raw, codecgelf.ModuleName)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		conf.mutex.Lock()
+		defer conf.mutex.Unlock()
+		if conf.conn != nil {
+			conf.conn.Close()
+			conf.conn = nil
+		}
+	}()
+
+	return &conf, nil
+}
+
+// Output event
+func (t *OutputConfig) Output(ctx context.Context, event logevent.LogEvent) error {
+	dataChan := make(chan []byte, 1)
+	if _, err := t.encoder.Encode(ctx, event, dataChan); err != nil {
+		return err
+	}
+	data := <-dataChan
+
+	var packets [][]byte
+	if t.Socket == "tcp" {
+		packets = [][]byte{append(data, 0)}
+	} else {
+		payload, err := codecgelf.Compress(data, t.Compression, t.CompressionLevel)
+		if err != nil {
+			return err
+		}
+		if packets, err = t.chunk(payload); err != nil {
+			return err
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.conn == nil {
+		conn, err := net.Dial(t.Socket, t.Address)
+		if err != nil {
+			return err
+		}
+		t.conn = conn
+	}
+	for _, packet := range packets {
+		if _, err := t.conn.Write(packet); err != nil {
+			// reconnect on next event
+			t.conn.Close()
+			t.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// chunk splits payload into chunked GELF datagrams if it exceeds chunk size
+func (t *OutputConfig) chunk(payload []byte) ([][]byte, error) {
+	if len(payload) <= t.ChunkSize {
+		return [][]byte{payload}, nil
+	}
+
+	size := t.ChunkSize - codecgelf.ChunkHeaderSize
+	count := (len(payload) + size - 1) / size
+	if count > codecgelf.MaxChunks {
+		return nil, ErrorTooManyChunks2.New(nil, len(payload), codecgelf.MaxChunks)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	packets := make([][]byte, 0, count)
+	for seq := 0; seq < count; seq++ {
+		end := (seq + 1) * size
+		if end > len(payload) {
+			end = len(payload)
+		}
+		packet := make([]byte, 0, codecgelf.ChunkHeaderSize+end-seq*size)
+		packet = append(packet, codecgelf.ChunkMagic...)
+		packet = append(packet, id...)
+		packet = append(packet, byte(seq), byte(count))
+		packet = append(packet, payload[seq*size:end]...)
+		packets = append(packets, packet)
+	}
+	return packets, nil
+}
+
+func (t *OutputConfig) IsRunning() (bool, error) {
+	return true, nil
+}
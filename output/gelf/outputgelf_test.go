@@ -0,0 +1,121 @@
+package outputgelf
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	codecgelf "github.com/viethqc/gogstash/codec/gelf"
+	"github.com/viethqc/gogstash/config"
+	"github.com/viethqc/gogstash/config/goglog"
+	"github.com/viethqc/gogstash/config/logevent"
+)
+
+func init() {
+	goglog.Logger.SetLevel(logrus.DebugLevel)
+	config.RegistCodecHandler(codecgelf.ModuleName, codecgelf.InitHandler)
+}
+
+func Test_output_gelf_module_udp(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:12221")
+	require.NoError(err)
+	defer pc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	output, err := InitHandler(ctx, &config.ConfigRaw{
+		"address":     "127.0.0.1:12221",
+		"compression": "none",
+		"chunk_size":  100,
+	})
+	require.NoError(err)
+
+	long := strings.Repeat("x", 250)
+	require.NoError(output.Output(ctx, logevent.LogEvent{Timestamp: time.Now(), Message: long}))
+
+	var payload []byte
+	var id []byte
+	buf := make([]byte, 1024)
+	for seq := 0; ; seq++ {
+		pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		require.NoError(err)
+		packet := buf[:n]
+		require.True(bytes.HasPrefix(packet, codecgelf.ChunkMagic))
+		require.True(n <= 100)
+		if id == nil {
+			id = append(id, packet[2:10]...)
+		}
+		assert.Equal(id, packet[2:10])
+		assert.Equal(seq, int(packet[10]))
+		payload = append(payload, packet[codecgelf.ChunkHeaderSize:]...)
+		if seq+1 == int(packet[11]) {
+			break
+		}
+	}
+
+	var message map[string]interface{}
+	require.NoError(jsoniter.Unmarshal(payload, &message))
+	assert.Equal(long, message["short_message"])
+
+	// small message is not chunked, gzip compressed by default
+	output, err = InitHandler(ctx, &config.ConfigRaw{"address": "127.0.0.1:12221"})
+	require.NoError(err)
+	require.NoError(output.Output(ctx, logevent.LogEvent{Timestamp: time.Now(), Message: "short"}))
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(err)
+	data, err := codecgelf.Decompress(buf[:n])
+	require.NoError(err)
+	message = nil
+	require.NoError(jsoniter.Unmarshal(data, &message))
+	assert.Equal("short", message["short_message"])
+}
+
+func Test_output_gelf_module_tcp(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	l, err := net.Listen("tcp", "127.0.0.1:12222")
+	require.NoError(err)
+	defer l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	output, err := InitHandler(ctx, &config.ConfigRaw{"socket": "tcp", "address": "127.0.0.1:12222"})
+	require.NoError(err)
+	require.NoError(output.Output(ctx, logevent.LogEvent{Timestamp: time.Now(), Message: "hello"}))
+
+	conn, err := l.Accept()
+	require.NoError(err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	require.NoError(err)
+	require.Equal(byte(0), buf[n-1])
+
+	var message map[string]interface{}
+	require.NoError(jsoniter.Unmarshal(buf[:n-1], &message))
+	assert.Equal("hello", message["short_message"])
+
+	// invalid config
+	_, err = InitHandler(ctx, &config.ConfigRaw{"socket": "unix"})
+	require.Error(err)
+	_, err = InitHandler(ctx, &config.ConfigRaw{"compression": "lz4"})
+	require.Error(err)
+}